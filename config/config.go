@@ -1,14 +1,93 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/viper"
+
+	"kasir-api/model"
 )
 
 type Config struct {
 	Port   string
 	DBConn string
+
+	// ScaleWeightPrefixes and ScalePricePrefixes are the EAN-13 prefixes
+	// printed by in-store scales for weight- and price-embedded labels.
+	ScaleWeightPrefixes []string
+	ScalePricePrefixes  []string
+
+	// Store details printed on receipts. ReceiptFooter may span several
+	// lines separated by "\n".
+	StoreName         string
+	StoreAddress      string
+	StorePhone        string
+	ReceiptFooter     string
+	ReceiptPaperWidth int
+	// TaxRate is the tax included in prices, in percent, which closing
+	// reports break out of net sales.
+	TaxRate int
+
+	// Digital receipt channels. Email is enabled when SMTPHost is set and
+	// WhatsApp when ReceiptGatewayURL is set.
+	SMTPHost            string
+	SMTPPort            string
+	SMTPUsername        string
+	SMTPPassword        string
+	SMTPFrom            string
+	ReceiptGatewayURL   string
+	ReceiptGatewayToken string
+	ReceiptMaxAttempts  int
+	ReceiptRetryDelay   time.Duration
+
+	// CartReservationMinutes is how long a parked cart holds its stock when
+	// a reservation is requested without a duration.
+	CartReservationMinutes int
+	// CartMaxReservationMinutes is the longest reservation a parked cart
+	// may ask for.
+	CartMaxReservationMinutes int
+
+	// PriceSchedulePollInterval is how often due scheduled price changes
+	// are looked for, and so how late one may take effect.
+	PriceSchedulePollInterval time.Duration
+
+	// JWTSecret signs access tokens. AdminUsername and AdminPassword create
+	// the first user when the users table is empty.
+	JWTSecret       string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	AdminUsername   string
+	AdminPassword   string
+
+	// DiscountApprovalPercent is the largest discount, in percent of a
+	// line's subtotal, a user without the transactions:discount permission
+	// may give without a supervisor's approval.
+	DiscountApprovalPercent int
+
+	// RolePermissions maps each role to its permissions. PERMISSIONS_<ROLE>
+	// (e.g. PERMISSIONS_CASHIER) replaces a role's default list.
+	RolePermissions model.RolePermissions
+
+	// RateLimits maps each route group to its limit per API key, user or,
+	// for requests without a validly signed token, IP address.
+	// RATE_LIMIT_<GROUP> (e.g. RATE_LIMIT_LOGIN=10/1m) replaces a group's
+	// default; 0 disables it.
+	RateLimits map[string]model.RateLimit
+	// Failed logins for a username from one address lock it out after
+	// LoginLockoutThreshold attempts, for LoginLockoutBase doubling with
+	// each further failure up to LoginLockoutMax. Failures are forgotten
+	// LoginLockoutWindow after the last one. Invalid access tokens and API
+	// keys lock out their address the same way, and wrong approval PINs the
+	// supervisor and the cashier who entered them.
+	LoginLockoutThreshold int
+	LoginLockoutBase      time.Duration
+	LoginLockoutMax       time.Duration
+	LoginLockoutWindow    time.Duration
 }
 
 func LoadConfig() *Config {
@@ -29,8 +108,163 @@ func LoadConfig() *Config {
 		log.Fatal("DB_CONN is required in .env file")
 	}
 
+	jwtSecret := viper.GetString("JWT_SECRET")
+	if jwtSecret == "" {
+		log.Fatal("JWT_SECRET is required in .env file")
+	}
+	if len(jwtSecret) < 32 {
+		log.Printf("Warning: JWT_SECRET should be at least 32 characters")
+	}
+
+	paperWidth := getInt("RECEIPT_PAPER_WIDTH", 58)
+	if paperWidth != 58 && paperWidth != 80 {
+		log.Printf("Warning: RECEIPT_PAPER_WIDTH must be 58 or 80, using 58")
+		paperWidth = 58
+	}
+
+	taxRate := getInt("TAX_RATE_PERCENT", 0)
+	if taxRate < 0 || taxRate > 100 {
+		log.Printf("Warning: TAX_RATE_PERCENT must be between 0 and 100, using 0")
+		taxRate = 0
+	}
+
+	discountApprovalPercent := getInt("DISCOUNT_APPROVAL_PERCENT", 10)
+	if discountApprovalPercent < 0 || discountApprovalPercent > 100 {
+		log.Printf("Warning: DISCOUNT_APPROVAL_PERCENT must be between 0 and 100, using 10")
+		discountApprovalPercent = 10
+	}
+
 	return &Config{
-		Port:   port,
-		DBConn: dbConn,
+		Port:                port,
+		DBConn:              dbConn,
+		ScaleWeightPrefixes: getList("SCALE_WEIGHT_PREFIXES", "20,21,22,23,24"),
+		ScalePricePrefixes:  getList("SCALE_PRICE_PREFIXES", "25,26,27,28,29"),
+		StoreName:           getString("STORE_NAME", "Kasir API"),
+		StoreAddress:        viper.GetString("STORE_ADDRESS"),
+		StorePhone:          viper.GetString("STORE_PHONE"),
+		ReceiptFooter:       strings.ReplaceAll(getString("RECEIPT_FOOTER", "Terima kasih"), `\n`, "\n"),
+		ReceiptPaperWidth:   paperWidth,
+		TaxRate:             taxRate,
+		SMTPHost:            viper.GetString("SMTP_HOST"),
+		SMTPPort:            getString("SMTP_PORT", "587"),
+		SMTPUsername:        viper.GetString("SMTP_USERNAME"),
+		SMTPPassword:        viper.GetString("SMTP_PASSWORD"),
+		SMTPFrom:            viper.GetString("SMTP_FROM"),
+		ReceiptGatewayURL:   viper.GetString("RECEIPT_GATEWAY_URL"),
+		ReceiptGatewayToken: viper.GetString("RECEIPT_GATEWAY_TOKEN"),
+		ReceiptMaxAttempts:  getInt("RECEIPT_MAX_ATTEMPTS", 5),
+		ReceiptRetryDelay:   time.Duration(getInt("RECEIPT_RETRY_DELAY_SECONDS", 30)) * time.Second,
+
+		CartReservationMinutes:    getInt("CART_RESERVATION_MINUTES", 30),
+		CartMaxReservationMinutes: getInt("CART_MAX_RESERVATION_MINUTES", 240),
+
+		PriceSchedulePollInterval: time.Duration(getInt("PRICE_SCHEDULE_POLL_SECONDS", 30)) * time.Second,
+
+		JWTSecret:       jwtSecret,
+		AccessTokenTTL:  time.Duration(getInt("ACCESS_TOKEN_TTL_MINUTES", 15)) * time.Minute,
+		RefreshTokenTTL: time.Duration(getInt("REFRESH_TOKEN_TTL_HOURS", 168)) * time.Hour,
+		AdminUsername:   viper.GetString("ADMIN_USERNAME"),
+		AdminPassword:   viper.GetString("ADMIN_PASSWORD"),
+
+		DiscountApprovalPercent: discountApprovalPercent,
+
+		RolePermissions: rolePermissions(),
+
+		RateLimits:            rateLimits(),
+		LoginLockoutThreshold: getInt("LOGIN_LOCKOUT_THRESHOLD", 5),
+		LoginLockoutBase:      time.Duration(getInt("LOGIN_LOCKOUT_BASE_SECONDS", 30)) * time.Second,
+		LoginLockoutMax:       time.Duration(getInt("LOGIN_LOCKOUT_MAX_MINUTES", 15)) * time.Minute,
+		LoginLockoutWindow:    time.Duration(getInt("LOGIN_LOCKOUT_WINDOW_MINUTES", 60)) * time.Minute,
+	}
+}
+
+// rateLimits reads the limit of every route group, falling back to
+// model.DefaultRateLimits.
+func rateLimits() map[string]model.RateLimit {
+	limits := map[string]model.RateLimit{}
+	for _, group := range model.RateLimitGroups {
+		key := "RATE_LIMIT_" + strings.ToUpper(group)
+		limits[group] = model.DefaultRateLimits[group]
+		value := viper.GetString(key)
+		if value == "" {
+			continue
+		}
+		limit, err := parseRateLimit(value)
+		if err != nil {
+			log.Printf("Warning: invalid %s %q, using the default: %v", key, value, err)
+			continue
+		}
+		limits[group] = limit
+	}
+	return limits
+}
+
+// parseRateLimit parses "<requests>/<duration>", e.g. "300/1m", or "0".
+func parseRateLimit(value string) (model.RateLimit, error) {
+	if value == "0" {
+		return model.RateLimit{}, nil
+	}
+	requests, per, ok := strings.Cut(value, "/")
+	if !ok {
+		return model.RateLimit{}, errors.New("want <requests>/<duration>")
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(requests))
+	if err != nil || n < 0 {
+		return model.RateLimit{}, fmt.Errorf("invalid number of requests %q", requests)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(per))
+	if err != nil || d <= 0 {
+		return model.RateLimit{}, fmt.Errorf("invalid duration %q", per)
+	}
+	return model.RateLimit{Requests: n, Per: d}, nil
+}
+
+// rolePermissions reads the permission list of every role, falling back to
+// model.DefaultRolePermissions.
+func rolePermissions() model.RolePermissions {
+	permissions := model.RolePermissions{}
+	for _, role := range model.Roles {
+		def := strings.Join(model.DefaultRolePermissions[role], ",")
+		permissions[role] = getList("PERMISSIONS_"+strings.ToUpper(role), def)
+	}
+	return permissions
+}
+
+// getString reads a setting, falling back to def when unset.
+func getString(key, def string) string {
+	if value := viper.GetString(key); value != "" {
+		return value
+	}
+	return def
+}
+
+// getInt reads an integer setting, falling back to def when unset or
+// invalid.
+func getInt(key string, def int) int {
+	value := viper.GetString(key)
+	if value == "" {
+		return def
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, def)
+		return def
+	}
+	return n
+}
+
+// getList reads a comma-separated setting, falling back to def when unset.
+func getList(key, def string) []string {
+	value := viper.GetString(key)
+	if value == "" {
+		value = def
+	}
+
+	var list []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
 	}
+	return list
 }
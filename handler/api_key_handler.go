@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type APIKeyHandler struct {
+	service service.APIKeyService
+}
+
+func NewAPIKeyHandler(service service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{service: service}
+}
+
+// HandleAPIKeys lists API keys and creates one on POST, e.g.
+// {"name": "Accounting sync", "permissions": ["reports:read"],
+// "allowed_ips": ["203.0.113.0/24"]}. The response to POST is the only one
+// that carries the key itself.
+func (h *APIKeyHandler) HandleAPIKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getAll(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleAPIKeyByID serves DELETE /api/api-keys/{id}, which revokes the key.
+// Revoked keys stay listed.
+func (h *APIKeyHandler) HandleAPIKeyByID(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/api-keys/"))
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid API key ID"))
+		return
+	}
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r, http.MethodDelete)
+		return
+	}
+
+	if err := h.service.Revoke(id); err != nil {
+		writeError(w, r, orNotFound(err, errAPIKeyNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *APIKeyHandler) getAll(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.GetAll()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if keys == nil {
+		keys = []model.APIKey{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keys)
+}
+
+func (h *APIKeyHandler) create(w http.ResponseWriter, r *http.Request) {
+	var req model.APIKeyRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	key, err := h.service.Create(req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(key)
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type ApprovalHandler struct {
+	service service.ApprovalService
+}
+
+func NewApprovalHandler(service service.ApprovalService) *ApprovalHandler {
+	return &ApprovalHandler{service: service}
+}
+
+var errPINLocked = &APIError{
+	Status:  http.StatusTooManyRequests,
+	Code:    CodePINLocked,
+	Message: "Too many wrong PINs; retry later",
+}
+
+// HandleApprove serves POST /api/approvals/{challenge_id}/approve. It is
+// called from the session that was challenged, with the supervisor's
+// username and PIN entered on that terminal.
+func (h *ApprovalHandler) HandleApprove(w http.ResponseWriter, r *http.Request) {
+	challengeID, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/approvals/"), "/")
+	if challengeID == "" || action != "approve" {
+		NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req model.ApprovalRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	approval, err := h.service.Approve(challengeID, actorFromRequest(r), req)
+	var locked *service.PINLockedError
+	if errors.As(err, &locked) {
+		writeTooManyRequests(w, r, locked.RetryAfter, errPINLocked)
+		return
+	}
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(approval)
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type AuditHandler struct {
+	service service.AuditService
+}
+
+func NewAuditHandler(service service.AuditService) *AuditHandler {
+	return &AuditHandler{service: service}
+}
+
+// HandleAuditLog lists audit entries, newest first. They can be filtered
+// with ?entity_type=, ?entity_id=, ?actor_id=, and ?from= and ?to= (RFC
+// 3339 times, or YYYY-MM-DD for whole days), and paged with ?limit= and
+// ?offset=.
+func (h *AuditHandler) HandleAuditLog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := model.AuditFilter{EntityType: query.Get("entity_type")}
+	ints := map[string]*int{
+		"entity_id": &filter.EntityID,
+		"actor_id":  &filter.ActorID,
+		"limit":     &filter.Limit,
+		"offset":    &filter.Offset,
+	}
+	for name, dst := range ints {
+		if s := query.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				writeError(w, r, invalidParameter("Invalid "+name))
+				return
+			}
+			*dst = n
+		}
+	}
+
+	var err error
+	if filter.From, err = timeParam(r, "from", false); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if filter.To, err = timeParam(r, "to", true); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	entries, err := h.service.GetAll(filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if entries == nil {
+		entries = []model.AuditEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// timeParam reads an optional RFC 3339 time or YYYY-MM-DD date. A date
+// stands for the start of the day, or with endOfDay for the start of the
+// next one, so that it can bound a range exclusively.
+func timeParam(r *http.Request, name string, endOfDay bool) (*time.Time, error) {
+	s := r.URL.Query().Get(name)
+	if s == "" {
+		return nil, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return &t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return nil, invalidParameter("Invalid " + name + " format. Use RFC 3339 or YYYY-MM-DD")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return &t, nil
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+// publicPaths are served without an access token.
+var publicPaths = map[string]bool{
+	"/health":           true,
+	"/api/auth/login":   true,
+	"/api/auth/refresh": true,
+}
+
+var errUnauthenticated = &APIError{
+	Status:  http.StatusUnauthorized,
+	Code:    CodeUnauthenticated,
+	Message: "Authentication required",
+}
+
+// userOnlyPaths are the route prefixes API keys may not use: those acting on
+// a login session or a cashier's drawer, and account administration.
+var userOnlyPaths = []string{"/api/auth/", "/api/approvals", "/api/shifts", "/api/users", "/api/api-keys"}
+
+var errUserOnly = &APIError{
+	Status:  http.StatusForbidden,
+	Code:    CodeForbidden,
+	Message: "This route cannot be used with an API key",
+}
+
+var errAuthLocked = &APIError{
+	Status:  http.StatusTooManyRequests,
+	Code:    CodeAuthLocked,
+	Message: "Too many invalid tokens or API keys; retry later",
+}
+
+// Authenticate requires a valid access token, sent as
+// "Authorization: Bearer <token>", on every path except publicPaths. The
+// user it belongs to is available to handlers through UserFromContext.
+// Integrations may instead send an API key, either as the bearer token or
+// in an X-API-Key header; the key is available through APIKeyFromContext.
+//
+// Tokens this server did not sign and unknown API keys count as failures
+// against the client's address, which lockout locks out like a login
+// before any more credentials from it are looked up.
+func Authenticate(auth service.AuthService, apiKeys service.APIKeyService, lockout service.Lockout, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, key := credentials(r)
+		if token == "" && key == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kasir-api"`)
+			writeError(w, r, errUnauthenticated)
+			return
+		}
+
+		// Only failures are counted and a success does not forget them, so
+		// that a caller holding one valid token cannot reset its guesses.
+		lockoutKey := "auth@" + clientIP(r)
+		if wait := lockout.Check(lockoutKey); wait > 0 {
+			writeTooManyRequests(w, r, wait, errAuthLocked)
+			return
+		}
+
+		if key != "" {
+			authenticateAPIKey(apiKeys, lockout, lockoutKey, key, w, r, next)
+			return
+		}
+
+		user, sessionID, err := auth.Authenticate(token)
+		if err != nil {
+			if errors.Is(err, service.ErrForgedToken) {
+				lockout.Fail(lockoutKey)
+			}
+			if errors.Is(err, service.ErrInvalidToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="kasir-api", error="invalid_token"`)
+			}
+			writeError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userKey, user)
+		ctx = context.WithValue(ctx, sessionKey, sessionID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// credentials returns the bearer access token or the API key the request
+// carries, at most one of them.
+func credentials(r *http.Request) (token, apiKey string) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if !strings.EqualFold(scheme, "Bearer") {
+		token = ""
+	}
+	if key := strings.TrimSpace(r.Header.Get("X-API-Key")); key != "" {
+		return "", key
+	}
+	if service.IsAPIKey(token) {
+		return "", token
+	}
+	return token, ""
+}
+
+func authenticateAPIKey(apiKeys service.APIKeyService, lockout service.Lockout, lockoutKey, key string,
+	w http.ResponseWriter, r *http.Request, next http.Handler) {
+	for _, prefix := range userOnlyPaths {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			writeError(w, r, errUserOnly)
+			return
+		}
+	}
+
+	apiKey, err := apiKeys.Authenticate(key, clientIP(r))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAPIKey) {
+			lockout.Fail(lockoutKey)
+			w.Header().Set("WWW-Authenticate", `Bearer realm="kasir-api", error="invalid_token"`)
+		}
+		writeError(w, r, err)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), apiKeyKey, apiKey)))
+}
+
+// UserFromContext returns the user authenticated by Authenticate, or nil.
+func UserFromContext(ctx context.Context) *model.User {
+	user, _ := ctx.Value(userKey).(*model.User)
+	return user
+}
+
+func sessionFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(sessionKey).(int)
+	return id
+}
+
+// APIKeyFromContext returns the API key authenticated by Authenticate, or
+// nil.
+func APIKeyFromContext(ctx context.Context) *model.APIKey {
+	key, _ := ctx.Value(apiKeyKey).(*model.APIKey)
+	return key
+}
@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type AuthHandler struct {
+	service service.AuthService
+	lockout service.Lockout
+}
+
+func NewAuthHandler(service service.AuthService, lockout service.Lockout) *AuthHandler {
+	return &AuthHandler{service: service, lockout: lockout}
+}
+
+var errLoginLocked = &APIError{
+	Status:  http.StatusTooManyRequests,
+	Code:    CodeLoginLocked,
+	Message: "Too many failed logins; retry later",
+}
+
+// HandleLogin exchanges a username and password for a token pair. Repeated
+// failures for a username from one address lock it out for a while.
+func (h *AuthHandler) HandleLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req model.LoginRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Keyed by address as well, so that guessing at a username from one
+	// place does not lock its owner out everywhere.
+	key := "login:" + strings.ToLower(strings.TrimSpace(req.Username)) + "@" + clientIP(r)
+	if wait := h.lockout.Check(key); wait > 0 {
+		writeTooManyRequests(w, r, wait, errLoginLocked)
+		return
+	}
+
+	tokens, err := h.service.Login(req)
+	switch {
+	case errors.Is(err, service.ErrInvalidCredentials):
+		h.lockout.Fail(key)
+	case err == nil:
+		h.lockout.Succeed(key)
+	}
+	writeTokens(w, r, tokens, err)
+}
+
+func (h *AuthHandler) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req model.RefreshRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	tokens, err := h.service.Refresh(req.RefreshToken)
+	writeTokens(w, r, tokens, err)
+}
+
+// HandleLogout ends the session of the access token used to call it.
+func (h *AuthHandler) HandleLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if err := h.service.Logout(sessionFromContext(r.Context())); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleMe returns the authenticated user.
+func (h *AuthHandler) HandleMe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(UserFromContext(r.Context()))
+}
+
+func writeTokens(w http.ResponseWriter, r *http.Request, tokens *model.TokenPair, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	// Tokens must not end up in shared caches.
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tokens)
+}
@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"kasir-api/model"
+)
+
+// routePermissions lists the permission each group of routes requires:
+// read for GET and HEAD, write for every other method. The first matching
+// prefix applies, so narrower prefixes come first. Paths not listed,
+// such as /api/auth/me, only require authentication. Actions a supervisor
+// can approve, such as voids, are checked by the service instead so that
+// users without the permission get an approval challenge.
+var routePermissions = []struct {
+	prefix string
+	read   string
+	write  string
+}{
+	{"/api/products", model.PermProductsRead, model.PermProductsWrite},
+	{"/api/parent-products", model.PermProductsRead, model.PermProductsWrite},
+	{"/api/outlets", model.PermOutletsRead, model.PermOutletsWrite},
+	{"/api/categories", model.PermCategoriesRead, model.PermCategoriesWrite},
+	{"/api/checkout", model.PermTransactionsCreate, model.PermTransactionsCreate},
+	{"/api/carts", model.PermTransactionsCreate, model.PermTransactionsCreate},
+	{"/api/transactions", model.PermTransactionsRead, model.PermTransactionsCreate},
+	{"/api/report/z", model.PermReportsRead, model.PermReportsClose},
+	{"/api/report", model.PermReportsRead, model.PermReportsRead},
+	{"/api/shifts", model.PermTransactionsCreate, model.PermTransactionsCreate},
+	{"/api/approvals", model.PermTransactionsCreate, model.PermTransactionsCreate},
+	{"/api/users", model.PermUsersManage, model.PermUsersManage},
+	{"/api/api-keys", model.PermUsersManage, model.PermUsersManage},
+	{"/api/audit-log", model.PermAuditRead, model.PermAuditRead},
+}
+
+// Authorize rejects requests whose user's role, or whose API key's scopes,
+// lack the permission the route requires. It must run after Authenticate.
+func Authorize(permissions model.RolePermissions, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		required := requiredPermission(r)
+		if required == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		actor := actorFromRequest(r)
+		if actor.UserID == 0 && actor.APIKeyID == 0 {
+			writeError(w, r, errUnauthenticated)
+			return
+		}
+		if !permissions.ActorAllows(actor, required) {
+			writeError(w, r, forbidden(actor, required))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requiredPermission returns the permission needed for r, or "" when the
+// route has none.
+func requiredPermission(r *http.Request) string {
+	for _, route := range routePermissions {
+		if r.URL.Path != route.prefix && !strings.HasPrefix(r.URL.Path, route.prefix+"/") {
+			continue
+		}
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			return route.read
+		}
+		return route.write
+	}
+	return ""
+}
+
+func forbidden(actor model.Actor, permission string) *APIError {
+	details := map[string]string{"permission": permission, "role": actor.Role}
+	if actor.APIKeyID != 0 {
+		details = map[string]string{"permission": permission, "api_key": actor.Name}
+	}
+	return &APIError{
+		Status:  http.StatusForbidden,
+		Code:    CodeForbidden,
+		Message: "Missing permission " + permission,
+		Details: details,
+	}
+}
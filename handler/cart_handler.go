@@ -0,0 +1,207 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type CartHandler struct {
+	service service.CartService
+}
+
+func NewCartHandler(service service.CartService) *CartHandler {
+	return &CartHandler{service: service}
+}
+
+func (h *CartHandler) HandleCarts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getAll(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleCartByID serves /api/carts/{id} and its sub-resources: items,
+// items/{product_id}, park, resume and checkout.
+func (h *CartHandler) HandleCartByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/carts/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Cart ID"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		switch r.Method {
+		case http.MethodGet:
+			h.getByID(w, r, id)
+		case http.MethodDelete:
+			cart, err := h.service.Cancel(id)
+			writeCart(w, r, cart, err)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+		}
+	case len(parts) == 2 && parts[1] == "items":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		h.addItem(w, r, id)
+	case len(parts) == 3 && parts[1] == "items":
+		productID, err := strconv.Atoi(parts[2])
+		if err != nil {
+			writeError(w, r, invalidParameter("Invalid Product ID"))
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			h.setItem(w, r, id, productID)
+		case http.MethodDelete:
+			cart, err := h.service.RemoveItem(id, productID)
+			writeCart(w, r, cart, err)
+		default:
+			methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		}
+	case len(parts) == 2 && r.Method != http.MethodPost:
+		methodNotAllowed(w, r, http.MethodPost)
+	case len(parts) == 2 && parts[1] == "park":
+		h.park(w, r, id)
+	case len(parts) == 2 && parts[1] == "resume":
+		cart, err := h.service.Resume(id)
+		writeCart(w, r, cart, err)
+	case len(parts) == 2 && parts[1] == "checkout":
+		h.checkout(w, r, id)
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *CartHandler) getAll(w http.ResponseWriter, r *http.Request) {
+	carts, err := h.service.GetAll(model.CartFilter{
+		Status:     r.URL.Query().Get("status"),
+		TerminalID: r.URL.Query().Get("terminal_id"),
+	})
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if carts == nil {
+		carts = []model.Cart{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(carts)
+}
+
+func (h *CartHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
+	cart, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if cart == nil {
+		writeError(w, r, errCartNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
+
+func (h *CartHandler) create(w http.ResponseWriter, r *http.Request) {
+	var cart model.Cart
+	if err := decodeJSON(w, r, &cart); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Create(&cart); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(cart)
+}
+
+// addItem adds a quantity of a product to the cart. Carts hold no price
+// overrides or discounts; those are given at checkout.
+func (h *CartHandler) addItem(w http.ResponseWriter, r *http.Request, id int) {
+	var body struct {
+		ProductID int `json:"product_id"`
+		Quantity  int `json:"quantity"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	cart, err := h.service.AddItem(id, body.ProductID, body.Quantity)
+	writeCart(w, r, cart, err)
+}
+
+func (h *CartHandler) setItem(w http.ResponseWriter, r *http.Request, id, productID int) {
+	var body struct {
+		Quantity int `json:"quantity"`
+	}
+	if err := decodeJSON(w, r, &body); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	cart, err := h.service.SetItem(id, productID, body.Quantity)
+	writeCart(w, r, cart, err)
+}
+
+func (h *CartHandler) park(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.ParkCartRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	cart, err := h.service.Park(id, req)
+	writeCart(w, r, cart, err)
+}
+
+func (h *CartHandler) checkout(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.CheckoutRequest
+	if err := decodeOptionalJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	req.ApprovalToken = r.Header.Get("X-Approval-Token")
+
+	transaction, err := h.service.Checkout(id, req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// writeCart writes the outcome of a cart mutation.
+func writeCart(w http.ResponseWriter, r *http.Request, cart *model.Cart, err error) {
+	if err != nil {
+		writeError(w, r, orNotFound(err, errCartItemNotFound))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(cart)
+}
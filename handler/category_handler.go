@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"database/sql"
 	"encoding/json"
 	"net/http"
 	"strconv"
@@ -26,15 +25,28 @@ func (h *CategoryHandler) HandleCategories(w http.ResponseWriter, r *http.Reques
 	case http.MethodPost:
 		h.create(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (h *CategoryHandler) HandleCategoryByID(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/categories/")
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/categories/"), "/")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid Category ID", http.StatusBadRequest)
+		writeError(w, r, invalidParameter("Invalid Category ID"))
+		return
+	}
+
+	if action == "restore" {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		h.restore(w, r, id)
+		return
+	}
+	if action != "" {
+		NotFound(w, r)
 		return
 	}
 
@@ -43,17 +55,38 @@ func (h *CategoryHandler) HandleCategoryByID(w http.ResponseWriter, r *http.Requ
 		h.getByID(w, r, id)
 	case http.MethodPut:
 		h.update(w, r, id)
+	case http.MethodPatch:
+		h.patch(w, r, id)
 	case http.MethodDelete:
 		h.delete(w, r, id)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
+	}
+}
+
+func (h *CategoryHandler) HandleCategoryTree(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
 	}
+
+	tree, err := h.service.GetTree()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if tree == nil {
+		tree = []model.Category{}
+	}
+
+	writeJSONWithETag(w, r, tree)
 }
 
 func (h *CategoryHandler) getAll(w http.ResponseWriter, r *http.Request) {
-	categories, err := h.service.GetAll()
+	categories, err := h.service.GetAll(r.URL.Query().Get("include_archived") == "true")
 	if err != nil {
-		http.Error(w, "Failed to fetch categories", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -61,78 +94,137 @@ func (h *CategoryHandler) getAll(w http.ResponseWriter, r *http.Request) {
 		categories = []model.Category{}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(categories)
+	writeJSONWithETag(w, r, categories)
 }
 
 func (h *CategoryHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
 	category, err := h.service.GetByID(id)
 	if err != nil {
-		http.Error(w, "Failed to fetch category", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	if category == nil {
-		http.Error(w, "Category not found", http.StatusNotFound)
+		writeError(w, r, errCategoryNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(category)
+	writeVersioned(w, r, http.StatusOK, versionETag(category.Version), category)
 }
 
 func (h *CategoryHandler) create(w http.ResponseWriter, r *http.Request) {
 	var category model.Category
-	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &category); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if category.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	if err := h.service.Create(&category, actorFromRequest(r)); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.service.Create(&category); err != nil {
-		http.Error(w, "Failed to create category", http.StatusInternalServerError)
+	writeVersioned(w, r, http.StatusCreated, versionETag(category.Version), category)
+}
+
+func (h *CategoryHandler) update(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(category)
+	var category model.Category
+	if err := decodeRepresentation(w, r, &category, service.CategoryFields); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Update(id, version, &category, actorFromRequest(r)); err != nil {
+		writeError(w, r, orNotFound(err, errCategoryNotFound))
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, versionETag(category.Version), category)
 }
 
-func (h *CategoryHandler) update(w http.ResponseWriter, r *http.Request, id int) {
-	var category model.Category
-	if err := json.NewDecoder(r.Body).Decode(&category); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// patch applies a JSON Merge Patch: only the supplied members change and
+// null clears a nullable one.
+func (h *CategoryHandler) patch(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.service.Update(id, &category); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Category not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to update category", http.StatusInternalServerError)
+	patch, err := readMergePatch(w, r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(category)
+	category, err := h.service.Patch(id, version, patch, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, orNotFound(err, errCategoryNotFound))
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, versionETag(category.Version), category)
 }
 
+// delete archives the category by default. With ?hard=true the row is removed
+// permanently, which is refused while other records still reference it.
 func (h *CategoryHandler) delete(w http.ResponseWriter, r *http.Request, id int) {
-	if err := h.service.Delete(id); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Category not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to delete category", http.StatusInternalServerError)
+	hard := r.URL.Query().Get("hard") == "true"
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
+	if hard {
+		err = h.service.Delete(id, version, actorFromRequest(r))
+	} else {
+		err = h.service.Archive(id, version, actorFromRequest(r))
+	}
+	if err != nil {
+		writeError(w, r, orNotFound(err, errCategoryNotFound))
+		return
+	}
+
+	message := "Category archived successfully"
+	if hard {
+		message = "Category deleted successfully"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Category deleted successfully"})
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
+}
+
+// restore brings back an archived category. If-Match is honoured but not
+// required.
+func (h *CategoryHandler) restore(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := optionalIfMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Restore(id, version, actorFromRequest(r)); err != nil {
+		writeError(w, r, orNotFound(err, errCategoryNotFound))
+		return
+	}
+
+	category, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if category == nil {
+		writeError(w, r, errCategoryNotFound)
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, versionETag(category.Version), category)
 }
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/receipt"
+	"kasir-api/service"
+)
+
+type ClosingHandler struct {
+	service    service.ClosingService
+	store      receipt.Store
+	paperWidth int
+}
+
+func NewClosingHandler(service service.ClosingService, store receipt.Store, paperWidth int) *ClosingHandler {
+	return &ClosingHandler{service: service, store: store, paperWidth: paperWidth}
+}
+
+// HandleXReport serves GET /api/report/x, the running totals since the
+// last Z-report, for ?terminal_id= or all terminals and ?outlet_id= or all
+// outlets.
+func (h *ClosingHandler) HandleXReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	outletID, err := outletParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	report, err := h.service.GetXReport(r.URL.Query().Get("terminal_id"), outletID)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	h.writeReport(w, r, http.StatusOK, report, "x-report")
+}
+
+// HandleZReports lists the Z-reports on GET and closes the business day
+// with a new one on POST.
+func (h *ClosingHandler) HandleZReports(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		reports, err := h.service.GetZReports()
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		if reports == nil {
+			reports = []model.ClosingReport{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(reports)
+	case http.MethodPost:
+		// Check the print options first: the day is closed either way.
+		if _, _, err := h.printOptions(r); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		report, err := h.service.CloseDay(actorFromRequest(r))
+		if err != nil {
+			writeError(w, r, err)
+			return
+		}
+		h.writeReport(w, r, http.StatusCreated, report, fmt.Sprintf("z-report-%04d", report.Number))
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+// HandleZReportByNumber serves GET /api/report/z/{number}.
+func (h *ClosingHandler) HandleZReportByNumber(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/api/report/z/"))
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Z-report number"))
+		return
+	}
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	report, err := h.service.GetZReport(number)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if report == nil {
+		writeError(w, r, errZReportNotFound)
+		return
+	}
+	h.writeReport(w, r, http.StatusOK, report, fmt.Sprintf("z-report-%04d", report.Number))
+}
+
+// writeReport writes the report as JSON, or printed like a receipt when
+// ?format=text|escpos|pdf|html is given, with ?width=58|80.
+func (h *ClosingHandler) writeReport(w http.ResponseWriter, r *http.Request, status int, report *model.ClosingReport, name string) {
+	format, paperWidth, err := h.printOptions(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if format == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+		return
+	}
+	writeDocument(w, r, receipt.FromClosingReport(report, h.store), format, paperWidth, name)
+}
+
+// printOptions reads ?format= and ?width=. format is "" for JSON.
+func (h *ClosingHandler) printOptions(r *http.Request) (format string, paperWidth int, err error) {
+	format = r.URL.Query().Get("format")
+	switch format {
+	case "", "json":
+		return "", 0, nil
+	case "text", "escpos", "pdf", "html":
+	default:
+		return "", 0, invalidParameter("format must be one of json, text, escpos, pdf, html")
+	}
+	paperWidth, err = paperWidthParam(r, h.paperWidth)
+	return format, paperWidth, err
+}
@@ -0,0 +1,291 @@
+package handler
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"kasir-api/repository"
+	"kasir-api/service"
+)
+
+// Error codes sent in the "code" field of error responses. Clients may rely
+// on them; messages are for humans and may change.
+const (
+	CodeValidationFailed      = "VALIDATION_FAILED"
+	CodeInvalidParameter      = "INVALID_PARAMETER"
+	CodeInvalidBody           = "INVALID_BODY"
+	CodeBodyTooLarge          = "BODY_TOO_LARGE"
+	CodeUnsupportedFormat     = "UNSUPPORTED_FORMAT"
+	CodeUnsupportedMediaType  = "UNSUPPORTED_MEDIA_TYPE"
+	CodeNotFound              = "NOT_FOUND"
+	CodeMethodNotAllowed      = "METHOD_NOT_ALLOWED"
+	CodePreconditionRequired  = "PRECONDITION_REQUIRED"
+	CodePreconditionFailed    = "PRECONDITION_FAILED"
+	CodeInternal              = "INTERNAL_ERROR"
+	CodeProductNotFound       = "PRODUCT_NOT_FOUND"
+	CodeParentProductNotFound = "PARENT_PRODUCT_NOT_FOUND"
+	CodeCategoryNotFound      = "CATEGORY_NOT_FOUND"
+	CodeCategoryCycle         = "CATEGORY_CYCLE"
+	CodeReferenced            = "RESOURCE_REFERENCED"
+	CodeTransactionNotFound   = "TRANSACTION_NOT_FOUND"
+	CodeCartNotFound          = "CART_NOT_FOUND"
+	CodeCartNotOpen           = "CART_NOT_OPEN"
+	CodeCartClosed            = "CART_CLOSED"
+	CodeCartEmpty             = "CART_EMPTY"
+	CodeInvalidItems          = "INVALID_ITEMS"
+	CodeInsufficientStock     = "INSUFFICIENT_STOCK"
+	CodeInsufficientPayment   = "INSUFFICIENT_PAYMENT"
+	CodeInvalidBarcode        = "INVALID_BARCODE"
+	CodeReceiptChannel        = "RECEIPT_CHANNEL_NOT_CONFIGURED"
+	CodeScheduledPriceMissing = "SCHEDULED_PRICE_NOT_FOUND"
+	CodeScheduledPriceClosed  = "SCHEDULED_PRICE_NOT_PENDING"
+	CodeUnauthenticated       = "UNAUTHENTICATED"
+	CodeForbidden             = "FORBIDDEN"
+	CodeApprovalRequired      = "APPROVAL_REQUIRED"
+	CodeChallengeNotFound     = "CHALLENGE_NOT_FOUND"
+	CodeInvalidPIN            = "INVALID_PIN"
+	CodeApproverNotPermitted  = "APPROVER_NOT_PERMITTED"
+	CodeInvalidApproval       = "INVALID_APPROVAL"
+	CodeTransactionVoided     = "TRANSACTION_VOIDED"
+	CodeInvalidCredentials    = "INVALID_CREDENTIALS"
+	CodeInvalidToken          = "INVALID_TOKEN"
+	CodeUsernameTaken         = "USERNAME_TAKEN"
+	CodeUserNotFound          = "USER_NOT_FOUND"
+	CodeShiftNotFound         = "SHIFT_NOT_FOUND"
+	CodeShiftAlreadyOpen      = "SHIFT_ALREADY_OPEN"
+	CodeTerminalInUse         = "TERMINAL_IN_USE"
+	CodeShiftClosed           = "SHIFT_CLOSED"
+	CodeNoOpenShift           = "NO_OPEN_SHIFT"
+	CodeZReportNotFound       = "Z_REPORT_NOT_FOUND"
+	CodeInvalidAPIKey         = "INVALID_API_KEY"
+	CodeIPNotAllowed          = "IP_NOT_ALLOWED"
+	CodeAPIKeyNotFound        = "API_KEY_NOT_FOUND"
+	CodeRateLimited           = "RATE_LIMITED"
+	CodeLoginLocked           = "LOGIN_LOCKED"
+	CodeAuthLocked            = "AUTH_LOCKED"
+	CodePINLocked             = "PIN_LOCKED"
+	CodeOutletNotFound        = "OUTLET_NOT_FOUND"
+)
+
+// APIError is an error together with the status and code it is reported
+// with. Handlers return one directly for failures they detect themselves;
+// errors from the service and repository layers are mapped by toAPIError.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Details interface{}
+}
+
+func (e *APIError) Error() string {
+	return e.Message
+}
+
+// Errors for lookups that found nothing.
+var (
+	errNotFound               = &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "Not found"}
+	errProductNotFound        = &APIError{Status: http.StatusNotFound, Code: CodeProductNotFound, Message: "Product not found"}
+	errParentProductNotFound  = &APIError{Status: http.StatusNotFound, Code: CodeParentProductNotFound, Message: "Parent product not found"}
+	errCategoryNotFound       = &APIError{Status: http.StatusNotFound, Code: CodeCategoryNotFound, Message: "Category not found"}
+	errTransactionNotFound    = &APIError{Status: http.StatusNotFound, Code: CodeTransactionNotFound, Message: "Transaction not found"}
+	errCartNotFound           = &APIError{Status: http.StatusNotFound, Code: CodeCartNotFound, Message: "Cart not found"}
+	errCartItemNotFound       = &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "Cart or item not found"}
+	errUserNotFound           = &APIError{Status: http.StatusNotFound, Code: CodeUserNotFound, Message: "User not found"}
+	errShiftNotFound          = &APIError{Status: http.StatusNotFound, Code: CodeShiftNotFound, Message: "Shift not found"}
+	errZReportNotFound        = &APIError{Status: http.StatusNotFound, Code: CodeZReportNotFound, Message: "Z-report not found"}
+	errAPIKeyNotFound         = &APIError{Status: http.StatusNotFound, Code: CodeAPIKeyNotFound, Message: "API key not found"}
+	errOutletNotFound         = &APIError{Status: http.StatusNotFound, Code: CodeOutletNotFound, Message: "Outlet not found"}
+	errOutletPriceNotFound    = &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "Outlet has no price for this product"}
+	errOutletTerminalNotFound = &APIError{Status: http.StatusNotFound, Code: CodeNotFound, Message: "Terminal is not assigned to this outlet"}
+)
+
+var errInvalidBody = &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Invalid request body"}
+
+// invalidParameter reports a malformed path or query parameter. Invalid
+// body fields are reported as a service.ValidationError instead.
+func invalidParameter(message string) *APIError {
+	return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidParameter, Message: message}
+}
+
+// errorMappings lists the service and repository errors clients are told
+// about, with the status and code each is reported with.
+var errorMappings = []struct {
+	err     error
+	status  int
+	code    string
+	message string
+}{
+	{repository.ErrProductNotFound, http.StatusBadRequest, CodeProductNotFound, "Product not found"},
+	{repository.ErrInsufficientStock, http.StatusBadRequest, CodeInsufficientStock, "Insufficient stock"},
+	{repository.ErrInsufficientPayment, http.StatusBadRequest, CodeInsufficientPayment, "Paid amount is less than the total"},
+	{repository.ErrCartNotFound, http.StatusNotFound, CodeCartNotFound, "Cart not found"},
+	{repository.ErrCartNotOpen, http.StatusConflict, CodeCartNotOpen, "Cart is not open; resume it first"},
+	{repository.ErrCartClosed, http.StatusConflict, CodeCartClosed, "Cart has already been checked out or cancelled"},
+	{repository.ErrCartEmpty, http.StatusBadRequest, CodeCartEmpty, "Cart is empty"},
+	{service.ErrInvalidBarcode, http.StatusBadRequest, CodeInvalidBarcode, "Invalid barcode"},
+	{service.ErrUnknownReceiptChannel, http.StatusBadRequest, CodeReceiptChannel, "Receipt channel is not configured"},
+	{service.ErrTransactionNotFound, http.StatusNotFound, CodeTransactionNotFound, "Transaction not found"},
+	{service.ErrCategoryCycle, http.StatusConflict, CodeCategoryCycle, "Category cannot be moved under itself or its descendants"},
+	{repository.ErrScheduledPriceNotFound, http.StatusNotFound, CodeScheduledPriceMissing, "Scheduled price change not found"},
+	{repository.ErrScheduledPriceNotPending, http.StatusConflict, CodeScheduledPriceClosed, "Scheduled price change has already been applied or cancelled"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, CodeInvalidCredentials, "Invalid username or password"},
+	{service.ErrInvalidToken, http.StatusUnauthorized, CodeInvalidToken, "Token is invalid, expired or revoked"},
+	{repository.ErrUsernameTaken, http.StatusConflict, CodeUsernameTaken, "Username is already taken"},
+	{service.ErrChallengeNotFound, http.StatusNotFound, CodeChallengeNotFound, "Approval challenge not found, expired or closed"},
+	{service.ErrInvalidPIN, http.StatusForbidden, CodeInvalidPIN, "Invalid supervisor or PIN"},
+	{service.ErrApproverNotPermitted, http.StatusForbidden, CodeApproverNotPermitted, "Supervisor is not allowed to approve this action"},
+	{service.ErrInvalidApproval, http.StatusForbidden, CodeInvalidApproval, "Approval token is invalid, expired or already used"},
+	{repository.ErrTransactionVoided, http.StatusConflict, CodeTransactionVoided, "Transaction has already been voided"},
+	{repository.ErrShiftAlreadyOpen, http.StatusConflict, CodeShiftAlreadyOpen, "Cashier already has an open shift"},
+	{repository.ErrTerminalInUse, http.StatusConflict, CodeTerminalInUse, "Terminal already has an open shift"},
+	{repository.ErrShiftClosed, http.StatusConflict, CodeShiftClosed, "Shift is closed"},
+	{repository.ErrNoOpenShift, http.StatusConflict, CodeNoOpenShift, "Open a shift on this terminal before checking out"},
+	{service.ErrNotShiftOwner, http.StatusForbidden, CodeForbidden, "Shift belongs to another cashier"},
+	{service.ErrInvalidAPIKey, http.StatusUnauthorized, CodeInvalidAPIKey, "API key is invalid or revoked"},
+	{service.ErrIPNotAllowed, http.StatusForbidden, CodeIPNotAllowed, "API key is not allowed from this address"},
+	{service.ErrAPIKeyNotPermitted, http.StatusForbidden, CodeForbidden, "API key is not allowed to perform this action"},
+	{errUnsupportedFormat, http.StatusBadRequest, CodeUnsupportedFormat, "Unsupported file format, use csv or xlsx"},
+	{repository.ErrVersionConflict, http.StatusPreconditionFailed, CodePreconditionFailed, "Resource has been modified; fetch it again and retry"},
+	{sql.ErrNoRows, http.StatusNotFound, CodeNotFound, "Not found"},
+}
+
+// toAPIError maps err to the error reported to the client. Anything not
+// known here is an internal error whose details are not exposed.
+func toAPIError(err error) *APIError {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		return &APIError{
+			Status:  http.StatusUnprocessableEntity,
+			Code:    CodeValidationFailed,
+			Message: "Request has invalid fields",
+			Details: validationErr.Fields,
+		}
+	}
+
+	var checkoutErr *repository.CheckoutError
+	if errors.As(err, &checkoutErr) {
+		return checkoutAPIError(checkoutErr)
+	}
+
+	var approvalErr *service.ApprovalRequiredError
+	if errors.As(err, &approvalErr) {
+		return &APIError{
+			Status:  http.StatusForbidden,
+			Code:    CodeApprovalRequired,
+			Message: "Supervisor approval is required for " + approvalErr.Challenge.Action,
+			Details: approvalErr.Challenge,
+		}
+	}
+
+	var refErr *repository.ReferencedError
+	if errors.As(err, &refErr) {
+		return &APIError{
+			Status:  http.StatusConflict,
+			Code:    CodeReferenced,
+			Message: fmt.Sprintf("Cannot delete: %s", refErr.Error()),
+			Details: refErr.Dependents,
+		}
+	}
+
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return &APIError{
+			Status:  http.StatusRequestEntityTooLarge,
+			Code:    CodeBodyTooLarge,
+			Message: fmt.Sprintf("Request body must not exceed %d bytes", tooLarge.Limit),
+		}
+	}
+
+	for _, m := range errorMappings {
+		if errors.Is(err, m.err) {
+			return &APIError{Status: m.status, Code: m.code, Message: m.message}
+		}
+	}
+
+	return &APIError{Status: http.StatusInternalServerError, Code: CodeInternal, Message: "Internal server error"}
+}
+
+// checkoutAPIError reports every unsellable checkout line in Details. The
+// code is the lines' shared code, or INVALID_ITEMS when they differ.
+func checkoutAPIError(checkoutErr *repository.CheckoutError) *APIError {
+	apiErr := &APIError{Status: http.StatusBadRequest, Code: CodeInvalidItems, Details: checkoutErr.Problems}
+	problems := checkoutErr.Problems
+	if len(problems) > 0 {
+		apiErr.Code = problems[0].Code
+		apiErr.Message = problems[0].Message
+	}
+	for _, p := range problems {
+		if p.Code != apiErr.Code {
+			apiErr.Code = CodeInvalidItems
+			break
+		}
+	}
+	if len(problems) > 1 {
+		apiErr.Message = fmt.Sprintf("%d items cannot be sold", len(problems))
+	}
+	return apiErr
+}
+
+// orNotFound reports a sql.ErrNoRows from an update or delete as notFound.
+func orNotFound(err error, notFound *APIError) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return notFound
+	}
+	return err
+}
+
+type errorResponse struct {
+	Error errorPayload `json:"error"`
+}
+
+type errorPayload struct {
+	Code      string      `json:"code"`
+	Message   string      `json:"message"`
+	RequestID string      `json:"request_id,omitempty"`
+	Details   interface{} `json:"details,omitempty"`
+}
+
+// writeError writes err as a JSON error envelope. Internal errors are
+// logged with the request ID so they can be found from the response.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	apiErr := toAPIError(err)
+	requestID := RequestIDFromContext(r.Context())
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Printf("[%s] %s %s: %v", requestID, r.Method, r.URL.Path, err)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(errorResponse{Error: errorPayload{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		RequestID: requestID,
+		Details:   apiErr.Details,
+	}})
+}
+
+// methodNotAllowed answers 405 and lists the methods the resource accepts
+// in the Allow header.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, r, &APIError{
+		Status:  http.StatusMethodNotAllowed,
+		Code:    CodeMethodNotAllowed,
+		Message: fmt.Sprintf("Method %s not allowed", r.Method),
+	})
+}
+
+// NotFound answers requests for paths no handler is registered for.
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	writeError(w, r, errNotFound)
+}
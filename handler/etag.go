@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	errPreconditionRequired = &APIError{
+		Status:  http.StatusPreconditionRequired,
+		Code:    CodePreconditionRequired,
+		Message: "If-Match header with the current ETag is required",
+	}
+	errPreconditionFailed = &APIError{
+		Status:  http.StatusPreconditionFailed,
+		Code:    CodePreconditionFailed,
+		Message: "Resource has been modified; fetch it again and retry",
+	}
+)
+
+// versionETag is the strong ETag of a versioned resource. Representations
+// that embed another versioned resource, such as a product with its
+// category, add that version after a dot.
+func versionETag(version int, embedded ...int) string {
+	tag := strconv.Itoa(version)
+	for _, v := range embedded {
+		tag += "." + strconv.Itoa(v)
+	}
+	return `"` + tag + `"`
+}
+
+// ifMatchVersion returns the version named by the If-Match header of a
+// write. The header is required; "*" matches any version and yields 0.
+func ifMatchVersion(r *http.Request) (int, error) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		return 0, errPreconditionRequired
+	}
+	if header == "*" {
+		return 0, nil
+	}
+
+	// A list of tags cannot all match one version; use the first.
+	tag, _, _ := strings.Cut(header, ",")
+	tag = strings.Trim(strings.TrimSpace(tag), `"`)
+	if strings.HasPrefix(tag, "W/") {
+		// Weak tags never match under the strong comparison If-Match uses.
+		return 0, errPreconditionFailed
+	}
+	tag, _, _ = strings.Cut(tag, ".")
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		return 0, errPreconditionFailed
+	}
+	return version, nil
+}
+
+// optionalIfMatchVersion is ifMatchVersion for writes that do not require
+// the header; without it the version is not checked.
+func optionalIfMatchVersion(r *http.Request) (int, error) {
+	if r.Header.Get("If-Match") == "" {
+		return 0, nil
+	}
+	return ifMatchVersion(r)
+}
+
+// notModified sets the ETag header and, when the request's If-None-Match
+// lists etag, answers 304 and reports true.
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == strings.TrimPrefix(etag, "W/") {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// writeJSONWithETag writes v with a weak ETag derived from its encoding,
+// answering 304 instead when the client already has it. It is used for
+// listings, which have no version of their own.
+func writeJSONWithETag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	if notModified(w, r, `W/"`+hex.EncodeToString(sum[:16])+`"`) {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(append(body, '\n'))
+}
+
+// writeVersioned writes a single versioned resource with its ETag,
+// answering 304 instead when the client already has it.
+func writeVersioned(w http.ResponseWriter, r *http.Request, status int, etag string, v interface{}) {
+	if status == http.StatusOK && notModified(w, r, etag) {
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,218 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type OutletHandler struct {
+	service service.OutletService
+}
+
+func NewOutletHandler(service service.OutletService) *OutletHandler {
+	return &OutletHandler{service: service}
+}
+
+// HandleOutlets lists outlets and creates one on POST, e.g.
+// {"name": "Cabang Selatan", "address": "Jl. Melati 12"}.
+func (h *OutletHandler) HandleOutlets(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getAll(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *OutletHandler) getAll(w http.ResponseWriter, r *http.Request) {
+	outlets, err := h.service.GetAll()
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if outlets == nil {
+		outlets = []model.Outlet{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outlets)
+}
+
+func (h *OutletHandler) create(w http.ResponseWriter, r *http.Request) {
+	var req model.OutletRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	outlet, err := h.service.Create(req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(outlet)
+}
+
+// HandleOutletByID serves GET and PUT /api/outlets/{id},
+// GET /api/outlets/{id}/products (stock and prices at the outlet),
+// PUT /api/outlets/{id}/stock/{product_id} with {"stock": 40},
+// PUT and DELETE /api/outlets/{id}/prices/{product_id} with {"price": 3500},
+// and PUT and DELETE /api/outlets/{id}/terminals/{terminal_id}, which
+// assign a terminal to the outlet and return it to the default outlet.
+func (h *OutletHandler) HandleOutletByID(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/outlets/"), "/", 3)
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Outlet ID"))
+		return
+	}
+
+	switch {
+	case len(parts) == 1:
+		h.handleOutlet(w, r, id)
+	case len(parts) == 2 && parts[1] == "products":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		h.getProducts(w, r, id)
+	case len(parts) == 3 && parts[1] == "stock":
+		h.handleStock(w, r, id, parts[2])
+	case len(parts) == 3 && parts[1] == "prices":
+		h.handlePrice(w, r, id, parts[2])
+	case len(parts) == 3 && parts[1] == "terminals" && parts[2] != "":
+		h.handleTerminal(w, r, id, parts[2])
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *OutletHandler) handleOutlet(w http.ResponseWriter, r *http.Request, id int) {
+	switch r.Method {
+	case http.MethodGet:
+		outlet, err := h.service.GetByID(id)
+		writeOutlet(w, r, outlet, err)
+	case http.MethodPut:
+		var req model.OutletRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		outlet, err := h.service.Update(id, req)
+		writeOutlet(w, r, outlet, orNotFound(err, errOutletNotFound))
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut)
+	}
+}
+
+func (h *OutletHandler) getProducts(w http.ResponseWriter, r *http.Request, id int) {
+	products, err := h.service.GetProducts(id)
+	if err != nil {
+		writeError(w, r, orNotFound(err, errOutletNotFound))
+		return
+	}
+
+	if products == nil {
+		products = []model.OutletProduct{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(products)
+}
+
+func (h *OutletHandler) handleStock(w http.ResponseWriter, r *http.Request, id int, productIDStr string) {
+	productID, err := strconv.Atoi(productIDStr)
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Product ID"))
+		return
+	}
+	if r.Method != http.MethodPut {
+		methodNotAllowed(w, r, http.MethodPut)
+		return
+	}
+
+	var req model.OutletStockRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if err := h.service.SetStock(id, productID, req, actorFromRequest(r)); err != nil {
+		writeError(w, r, orNotFound(err, errOutletNotFound))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OutletHandler) handlePrice(w http.ResponseWriter, r *http.Request, id int, productIDStr string) {
+	productID, err := strconv.Atoi(productIDStr)
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Product ID"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var req model.OutletPriceRequest
+		if err := decodeJSON(w, r, &req); err != nil {
+			writeError(w, r, err)
+			return
+		}
+		err = orNotFound(h.service.SetPrice(id, productID, req, actorFromRequest(r)), errOutletNotFound)
+	case http.MethodDelete:
+		err = orNotFound(h.service.DeletePrice(id, productID, actorFromRequest(r)), errOutletPriceNotFound)
+	default:
+		methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		return
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *OutletHandler) handleTerminal(w http.ResponseWriter, r *http.Request, id int, terminalID string) {
+	var err error
+	switch r.Method {
+	case http.MethodPut:
+		err = orNotFound(h.service.AssignTerminal(id, terminalID), errOutletNotFound)
+	case http.MethodDelete:
+		err = orNotFound(h.service.UnassignTerminal(id, terminalID), errOutletTerminalNotFound)
+	default:
+		methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+		return
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeOutlet writes an outlet, or a 404 when there is none.
+func writeOutlet(w http.ResponseWriter, r *http.Request, outlet *model.Outlet, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if outlet == nil {
+		writeError(w, r, errOutletNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(outlet)
+}
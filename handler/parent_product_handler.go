@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type ParentProductHandler struct {
+	service service.ParentProductService
+}
+
+func NewParentProductHandler(service service.ParentProductService) *ParentProductHandler {
+	return &ParentProductHandler{service: service}
+}
+
+func (h *ParentProductHandler) HandleParentProducts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getAll(w, r)
+	case http.MethodPost:
+		h.create(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *ParentProductHandler) HandleParentProductByID(w http.ResponseWriter, r *http.Request) {
+	idStr := strings.TrimPrefix(r.URL.Path, "/api/parent-products/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Parent Product ID"))
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.getByID(w, r, id)
+	case http.MethodPut:
+		h.update(w, r, id)
+	case http.MethodDelete:
+		h.delete(w, r, id)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+}
+
+func (h *ParentProductHandler) getAll(w http.ResponseWriter, r *http.Request) {
+	parents, err := h.service.GetAll(r.URL.Query().Get("search"))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if parents == nil {
+		parents = []model.ParentProduct{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(parents)
+}
+
+func (h *ParentProductHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
+	parent, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if parent == nil {
+		writeError(w, r, errParentProductNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(parent)
+}
+
+func (h *ParentProductHandler) create(w http.ResponseWriter, r *http.Request) {
+	var parent model.ParentProduct
+	if err := decodeJSON(w, r, &parent); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Create(&parent); err != nil {
+		writeError(w, r, err)
+		return
+	}
+	parent.Variants = []model.Product{}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(parent)
+}
+
+func (h *ParentProductHandler) update(w http.ResponseWriter, r *http.Request, id int) {
+	var parent model.ParentProduct
+	if err := decodeJSON(w, r, &parent); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Update(id, &parent); err != nil {
+		writeError(w, r, orNotFound(err, errParentProductNotFound))
+		return
+	}
+
+	updated, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if updated == nil {
+		writeError(w, r, errParentProductNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+func (h *ParentProductHandler) delete(w http.ResponseWriter, r *http.Request, id int) {
+	if err := h.service.Delete(id); err != nil {
+		writeError(w, r, orNotFound(err, errParentProductNotFound))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"message": "Parent product deleted successfully"})
+}
@@ -1,8 +1,8 @@
 package handler
 
 import (
-	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 	"strings"
@@ -13,10 +13,11 @@ import (
 
 type ProductHandler struct {
 	service service.ProductService
+	prices  service.PriceService
 }
 
-func NewProductHandler(service service.ProductService) *ProductHandler {
-	return &ProductHandler{service: service}
+func NewProductHandler(service service.ProductService, prices service.PriceService) *ProductHandler {
+	return &ProductHandler{service: service, prices: prices}
 }
 
 func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request) {
@@ -26,15 +27,60 @@ func (h *ProductHandler) HandleProducts(w http.ResponseWriter, r *http.Request)
 	case http.MethodPost:
 		h.create(w, r)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 	}
 }
 
 func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Request) {
-	idStr := strings.TrimPrefix(r.URL.Path, "/api/products/")
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/products/"), "/")
 	id, err := strconv.Atoi(idStr)
 	if err != nil {
-		http.Error(w, "Invalid Product ID", http.StatusBadRequest)
+		writeError(w, r, invalidParameter("Invalid Product ID"))
+		return
+	}
+
+	switch action {
+	case "":
+	case "restore":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, r, http.MethodPost)
+			return
+		}
+		h.restore(w, r, id)
+		return
+	case "price-history":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		h.getPriceHistory(w, r, id)
+		return
+	case "scheduled-prices":
+		switch r.Method {
+		case http.MethodGet:
+			h.getScheduledPrices(w, r, id)
+		case http.MethodPost:
+			h.schedulePrice(w, r, id)
+		default:
+			methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	default:
+		changeIDStr, ok := strings.CutPrefix(action, "scheduled-prices/")
+		if !ok {
+			NotFound(w, r)
+			return
+		}
+		changeID, err := strconv.Atoi(changeIDStr)
+		if err != nil {
+			writeError(w, r, invalidParameter("Invalid scheduled price change ID"))
+			return
+		}
+		if r.Method != http.MethodDelete {
+			methodNotAllowed(w, r, http.MethodDelete)
+			return
+		}
+		h.cancelScheduledPrice(w, r, id, changeID)
 		return
 	}
 
@@ -43,16 +89,18 @@ func (h *ProductHandler) HandleProductByID(w http.ResponseWriter, r *http.Reques
 		h.getByID(w, r, id)
 	case http.MethodPut:
 		h.update(w, r, id)
+	case http.MethodPatch:
+		h.patch(w, r, id)
 	case http.MethodDelete:
 		h.delete(w, r, id)
 	default:
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
 	}
 }
 
 func (h *ProductHandler) HandleProductsByCategory(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
@@ -60,13 +108,18 @@ func (h *ProductHandler) HandleProductsByCategory(w http.ResponseWriter, r *http
 	path = strings.TrimSuffix(path, "/products")
 	categoryID, err := strconv.Atoi(path)
 	if err != nil {
-		http.Error(w, "Invalid Category ID", http.StatusBadRequest)
+		writeError(w, r, invalidParameter("Invalid Category ID"))
 		return
 	}
 
-	products, err := h.service.GetByCategoryID(categoryID)
+	var products []model.Product
+	if r.URL.Query().Get("recursive") == "true" {
+		products, err = h.service.GetByCategoryTree(categoryID)
+	} else {
+		products, err = h.service.GetByCategoryID(categoryID)
+	}
 	if err != nil {
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -74,24 +127,106 @@ func (h *ProductHandler) HandleProductsByCategory(w http.ResponseWriter, r *http
 		products = []model.Product{}
 	}
 
+	writeJSONWithETag(w, r, products)
+}
+
+func (h *ProductHandler) HandleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	records, err := readUpload(w, r)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if !errors.Is(err, errUnsupportedFormat) && !errors.As(err, &tooLarge) {
+			err = &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Invalid import file"}
+		}
+		writeError(w, r, err)
+		return
+	}
+
+	result, err := h.service.Import(records, r.URL.Query().Get("dry_run") == "true", actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	if !result.DryRun && !result.Applied {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func (h *ProductHandler) HandleExport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatCSV
+	}
+	if format != formatCSV && format != formatXLSX {
+		writeError(w, r, errUnsupportedFormat)
+		return
+	}
+
+	filter, err := productFilterFromQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	products, err := h.service.GetAllWithCategory(filter)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := writeSpreadsheet(w, format, "products", service.ExportRecords(products)); err != nil {
+		writeError(w, r, err)
+	}
+}
+
+// productFilterFromQuery reads the listing filters shared by the product
+// list and the product export.
+func productFilterFromQuery(r *http.Request) (model.ProductFilter, error) {
+	filter := model.ProductFilter{
+		Search:          r.URL.Query().Get("search"),
+		IncludeArchived: r.URL.Query().Get("include_archived") == "true",
+	}
+	if parentIDStr := r.URL.Query().Get("parent_id"); parentIDStr != "" {
+		parentID, err := strconv.Atoi(parentIDStr)
+		if err != nil {
+			return filter, invalidParameter("Invalid parent_id")
+		}
+		filter.ParentID = &parentID
+	}
+	return filter, nil
 }
 
 func (h *ProductHandler) getAll(w http.ResponseWriter, r *http.Request) {
 	includeCategory := r.URL.Query().Get("include_category")
 
+	filter, err := productFilterFromQuery(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
 	var products []model.Product
-	var err error
 
 	if includeCategory == "true" {
-		products, err = h.service.GetAllWithCategory()
+		products, err = h.service.GetAllWithCategory(filter)
 	} else {
-		products, err = h.service.GetAll()
+		products, err = h.service.GetAll(filter)
 	}
 
 	if err != nil {
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
@@ -99,8 +234,7 @@ func (h *ProductHandler) getAll(w http.ResponseWriter, r *http.Request) {
 		products = []model.Product{}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(products)
+	writeJSONWithETag(w, r, products)
 }
 
 func (h *ProductHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
@@ -116,71 +250,199 @@ func (h *ProductHandler) getByID(w http.ResponseWriter, r *http.Request, id int)
 	}
 
 	if err != nil {
-		http.Error(w, "Failed to fetch product", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	if product == nil {
-		http.Error(w, "Product not found", http.StatusNotFound)
+		writeError(w, r, errProductNotFound)
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(product)
+	writeVersioned(w, r, http.StatusOK, productETag(product), product)
+}
+
+// productETag is the ETag of a product, which also covers its embedded
+// category when present.
+func productETag(product *model.Product) string {
+	if product.Category != nil {
+		return versionETag(product.Version, product.Category.Version)
+	}
+	return versionETag(product.Version)
 }
 
 func (h *ProductHandler) create(w http.ResponseWriter, r *http.Request) {
 	var product model.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &product); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Create(&product, actorFromRequest(r)); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	writeVersioned(w, r, http.StatusCreated, productETag(&product), product)
+}
+
+func (h *ProductHandler) update(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var product model.Product
+	if err := decodeRepresentation(w, r, &product, service.ProductFields); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if err := h.service.Update(id, version, &product, actorFromRequest(r)); err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, productETag(&product), product)
+}
+
+// patch applies a JSON Merge Patch: only the supplied members change and
+// null clears a nullable one.
+func (h *ProductHandler) patch(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if product.Name == "" {
-		http.Error(w, "Name is required", http.StatusBadRequest)
+	patch, err := readMergePatch(w, r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.service.Create(&product); err != nil {
-		http.Error(w, "Failed to create product", http.StatusInternalServerError)
+	product, err := h.service.Patch(id, version, patch, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
 		return
 	}
 
+	writeVersioned(w, r, http.StatusOK, productETag(product), product)
+}
+
+// delete archives the product by default. With ?hard=true the row is removed
+// permanently, which is refused while other records still reference it.
+func (h *ProductHandler) delete(w http.ResponseWriter, r *http.Request, id int) {
+	hard := r.URL.Query().Get("hard") == "true"
+
+	version, err := ifMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if hard {
+		err = h.service.Delete(id, version, actorFromRequest(r))
+	} else {
+		err = h.service.Archive(id, version, actorFromRequest(r))
+	}
+	if err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
+		return
+	}
+
+	message := "Product archived successfully"
+	if hard {
+		message = "Product deleted successfully"
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(product)
+	json.NewEncoder(w).Encode(map[string]string{"message": message})
 }
 
-func (h *ProductHandler) update(w http.ResponseWriter, r *http.Request, id int) {
-	var product model.Product
-	if err := json.NewDecoder(r.Body).Decode(&product); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+// restore brings back an archived product. If-Match is honoured but not
+// required.
+func (h *ProductHandler) restore(w http.ResponseWriter, r *http.Request, id int) {
+	version, err := optionalIfMatchVersion(r)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	if err := h.service.Update(id, &product); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Product not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to update product", http.StatusInternalServerError)
+	if err := h.service.Restore(id, version, actorFromRequest(r)); err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
 		return
 	}
 
+	product, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if product == nil {
+		writeError(w, r, errProductNotFound)
+		return
+	}
+
+	writeVersioned(w, r, http.StatusOK, productETag(product), product)
+}
+
+func (h *ProductHandler) getPriceHistory(w http.ResponseWriter, r *http.Request, id int) {
+	history, err := h.prices.GetHistory(id)
+	if err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
+		return
+	}
+
+	if history == nil {
+		history = []model.PriceChange{}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(product)
+	json.NewEncoder(w).Encode(history)
 }
 
-func (h *ProductHandler) delete(w http.ResponseWriter, r *http.Request, id int) {
-	if err := h.service.Delete(id); err != nil {
-		if err == sql.ErrNoRows {
-			http.Error(w, "Product not found", http.StatusNotFound)
-			return
-		}
-		http.Error(w, "Failed to delete product", http.StatusInternalServerError)
+func (h *ProductHandler) getScheduledPrices(w http.ResponseWriter, r *http.Request, id int) {
+	changes, err := h.prices.GetScheduled(id)
+	if err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
+		return
+	}
+
+	if changes == nil {
+		changes = []model.ScheduledPriceChange{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(changes)
+}
+
+func (h *ProductHandler) schedulePrice(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.ScheduledPriceRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	change, err := h.prices.Schedule(id, req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, orNotFound(err, errProductNotFound))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(change)
+}
+
+func (h *ProductHandler) cancelScheduledPrice(w http.ResponseWriter, r *http.Request, id, changeID int) {
+	change, err := h.prices.CancelScheduled(id, changeID)
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"message": "Product deleted successfully"})
+	json.NewEncoder(w).Encode(change)
 }
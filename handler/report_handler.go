@@ -3,6 +3,7 @@ package handler
 import (
 	"encoding/json"
 	"net/http"
+	"strconv"
 	"time"
 
 	"kasir-api/service"
@@ -16,46 +17,146 @@ func NewReportHandler(service service.ReportService) *ReportHandler {
 	return &ReportHandler{service: service}
 }
 
+// HandleTodayReport summarises sales for today or the requested range,
+// broken down by outlet. Every report takes ?outlet_id= to cover a single
+// outlet.
 func (h *ReportHandler) HandleTodayReport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
-	startDateStr := r.URL.Query().Get("start_date")
-	endDateStr := r.URL.Query().Get("end_date")
+	startDate, endDate, hasRange, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	outletID, err := outletParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
 
 	var summary interface{}
-	var err error
-
-	if startDateStr != "" && endDateStr != "" {
-		startDate, parseErr := time.Parse("2006-01-02", startDateStr)
-		if parseErr != nil {
-			http.Error(w, "Invalid start_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-			return
-		}
-
-		endDate, parseErr := time.Parse("2006-01-02", endDateStr)
-		if parseErr != nil {
-			http.Error(w, "Invalid end_date format. Use YYYY-MM-DD", http.StatusBadRequest)
-			return
-		}
-
-		if startDate.After(endDate) {
-			http.Error(w, "start_date must be before end_date", http.StatusBadRequest)
-			return
-		}
-
-		summary, err = h.service.GetSummaryByDateRange(startDate, endDate)
+	if hasRange {
+		summary, err = h.service.GetSummaryByDateRange(startDate, endDate, outletID)
 	} else {
-		summary, err = h.service.GetTodaySummary()
+		summary, err = h.service.GetTodaySummary(outletID)
 	}
 
 	if err != nil {
-		http.Error(w, "Failed to get report", http.StatusInternalServerError)
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(summary)
 }
+
+func (h *ReportHandler) HandleCategoryReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	startDate, endDate, hasRange, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	outletID, err := outletParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var sales interface{}
+	if hasRange {
+		sales, err = h.service.GetCategorySalesByDateRange(startDate, endDate, outletID)
+	} else {
+		sales, err = h.service.GetTodayCategorySales(outletID)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+}
+
+// HandleCashierReport reports each cashier's sales, transaction count,
+// average basket and voids for today or the requested range.
+func (h *ReportHandler) HandleCashierReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	startDate, endDate, hasRange, err := parseDateRange(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	outletID, err := outletParam(r)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	var sales interface{}
+	if hasRange {
+		sales, err = h.service.GetCashierSalesByDateRange(startDate, endDate, outletID)
+	} else {
+		sales, err = h.service.GetTodayCashierSales(outletID)
+	}
+
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(sales)
+}
+
+// parseDateRange reads the optional start_date and end_date query
+// parameters. hasRange is false when either of them is missing.
+func parseDateRange(r *http.Request) (startDate, endDate time.Time, hasRange bool, err error) {
+	startDateStr := r.URL.Query().Get("start_date")
+	endDateStr := r.URL.Query().Get("end_date")
+
+	if startDateStr == "" || endDateStr == "" {
+		return startDate, endDate, false, nil
+	}
+
+	startDate, err = time.Parse("2006-01-02", startDateStr)
+	if err != nil {
+		return startDate, endDate, false, invalidParameter("Invalid start_date format. Use YYYY-MM-DD")
+	}
+
+	endDate, err = time.Parse("2006-01-02", endDateStr)
+	if err != nil {
+		return startDate, endDate, false, invalidParameter("Invalid end_date format. Use YYYY-MM-DD")
+	}
+
+	if startDate.After(endDate) {
+		return startDate, endDate, false, invalidParameter("start_date must be before end_date")
+	}
+
+	return startDate, endDate, true, nil
+}
+
+// outletParam reads the optional outlet_id query parameter, 0 when absent.
+func outletParam(r *http.Request) (int, error) {
+	s := r.URL.Query().Get("outlet_id")
+	if s == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(s)
+	if err != nil || id <= 0 {
+		return 0, invalidParameter("Invalid outlet_id")
+	}
+	return id, nil
+}
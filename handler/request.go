@@ -0,0 +1,142 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+// maxBodySize bounds JSON request bodies; uploads have their own limit.
+const maxBodySize = 1 << 20
+
+// decodeJSON decodes the request body into v. Bodies over maxBodySize,
+// unknown fields and trailing data are rejected, and a value of the wrong
+// type is reported against its field.
+func decodeJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	body, err := readBody(w, r)
+	if err != nil {
+		return err
+	}
+	return decodeBody(body, v)
+}
+
+// decodeRepresentation is decodeJSON for PUT, which replaces the whole
+// resource: every member in required must be present, if only as null.
+func decodeRepresentation(w http.ResponseWriter, r *http.Request, v interface{}, required []string) error {
+	body, err := readBody(w, r)
+	if err != nil {
+		return err
+	}
+
+	var members map[string]json.RawMessage
+	if json.Unmarshal(body, &members) == nil && members != nil {
+		missing := &service.ValidationError{}
+		for _, name := range required {
+			if _, ok := members[name]; !ok {
+				missing.Add(name, "is required by PUT; use PATCH to change only some fields")
+			}
+		}
+		if err := missing.Err(); err != nil {
+			return err
+		}
+	}
+	return decodeBody(body, v)
+}
+
+// readMergePatch returns the body of a PATCH request, which must be sent as
+// application/merge-patch+json (or plain application/json).
+func readMergePatch(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", "application/merge-patch+json")
+		return nil, &APIError{
+			Status:  http.StatusUnsupportedMediaType,
+			Code:    CodeUnsupportedMediaType,
+			Message: "PATCH requires Content-Type application/merge-patch+json",
+		}
+	}
+	return readBody(w, r)
+}
+
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	return io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+}
+
+func decodeBody(body []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(v)
+	if err == nil {
+		if dec.Decode(&struct{}{}) != io.EOF {
+			return &APIError{Status: http.StatusBadRequest, Code: CodeInvalidBody, Message: "Request body must contain a single JSON value"}
+		}
+		return nil
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return service.NewFieldError(typeErr.Field, "must be a "+jsonTypeName(typeErr.Type.Kind().String()))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return service.NewFieldError(field, "is not a known field")
+	default:
+		return errInvalidBody
+	}
+}
+
+// actorFromRequest identifies the authenticated user making the request
+// and the request itself.
+func actorFromRequest(r *http.Request) model.Actor {
+	actor := model.Actor{RequestID: RequestIDFromContext(r.Context()), IP: clientIP(r)}
+	if user := UserFromContext(r.Context()); user != nil {
+		actor.UserID, actor.Name, actor.Role = user.ID, user.Username, user.Role
+		actor.SessionID = sessionFromContext(r.Context())
+	}
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		actor.APIKeyID, actor.Name, actor.Scopes = key.ID, "api-key:"+key.Name, key.Permissions
+	}
+	return actor
+}
+
+// clientIP returns the address of the peer that sent r. Forwarding headers
+// are not trusted, so behind a proxy this is the proxy's address.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// decodeOptionalJSON is decodeJSON for endpoints whose body may be empty.
+func decodeOptionalJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	if r.ContentLength == 0 {
+		return nil
+	}
+	return decodeJSON(w, r, v)
+}
+
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "number"
+	case kind == "bool":
+		return "boolean"
+	case kind == "slice", kind == "array":
+		return "list"
+	case kind == "map", kind == "struct":
+		return "object"
+	default:
+		return kind
+	}
+}
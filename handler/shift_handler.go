@@ -0,0 +1,168 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/service"
+)
+
+type ShiftHandler struct {
+	service service.ShiftService
+}
+
+func NewShiftHandler(service service.ShiftService) *ShiftHandler {
+	return &ShiftHandler{service: service}
+}
+
+// HandleShifts lists shifts, filtered with ?cashier_id=, ?terminal_id= and
+// ?status=open|closed, and opens a shift for the caller on POST, e.g.
+// {"terminal_id": "T1", "opening_float": 200000}.
+func (h *ShiftHandler) HandleShifts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		h.getAll(w, r)
+	case http.MethodPost:
+		h.open(w, r)
+	default:
+		methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+}
+
+func (h *ShiftHandler) getAll(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := model.ShiftFilter{TerminalID: query.Get("terminal_id"), Status: query.Get("status")}
+	if s := query.Get("cashier_id"); s != "" {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			writeError(w, r, invalidParameter("Invalid cashier_id"))
+			return
+		}
+		filter.CashierID = id
+	}
+
+	shifts, err := h.service.GetAll(filter, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if shifts == nil {
+		shifts = []model.Shift{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shifts)
+}
+
+func (h *ShiftHandler) open(w http.ResponseWriter, r *http.Request) {
+	var req model.OpenShiftRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	shift, err := h.service.Open(req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(shift)
+}
+
+// HandleShiftByID serves GET /api/shifts/current (the caller's open
+// shift), GET /api/shifts/{id}, POST /api/shifts/{id}/cash-events and
+// POST /api/shifts/{id}/close.
+func (h *ShiftHandler) HandleShiftByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/shifts/"), "/")
+	if idStr == "current" && action == "" {
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		shift, err := h.service.GetCurrent(actorFromRequest(r))
+		writeShift(w, r, shift, err)
+		return
+	}
+
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Shift ID"))
+		return
+	}
+
+	method := http.MethodGet
+	var handle func(http.ResponseWriter, *http.Request, int)
+	switch action {
+	case "":
+		handle = h.getByID
+	case "cash-events":
+		method, handle = http.MethodPost, h.addCashEvent
+	case "close":
+		method, handle = http.MethodPost, h.close
+	default:
+		NotFound(w, r)
+		return
+	}
+
+	if r.Method != method {
+		methodNotAllowed(w, r, method)
+		return
+	}
+	handle(w, r, id)
+}
+
+func (h *ShiftHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
+	shift, err := h.service.GetByID(id, actorFromRequest(r))
+	writeShift(w, r, shift, err)
+}
+
+func (h *ShiftHandler) addCashEvent(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.CashEventRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	event, err := h.service.AddCashEvent(id, req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, orNotFound(err, errShiftNotFound))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(event)
+}
+
+func (h *ShiftHandler) close(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.CloseShiftRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	shift, err := h.service.Close(id, req, actorFromRequest(r))
+	writeShift(w, r, shift, orNotFound(err, errShiftNotFound))
+}
+
+// writeShift writes a shift, or a 404 when there is none.
+func writeShift(w http.ResponseWriter, r *http.Request, shift *model.Shift, err error) {
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+	if shift == nil {
+		writeError(w, r, errShiftNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(shift)
+}
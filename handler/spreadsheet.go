@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	formatCSV  = "csv"
+	formatXLSX = "xlsx"
+
+	contentTypeCSV  = "text/csv"
+	contentTypeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+
+	maxUploadSize = 10 << 20
+)
+
+var errUnsupportedFormat = errors.New("unsupported file format, use csv or xlsx")
+
+// readUpload returns the records of a CSV or XLSX upload, sent either as
+// the "file" field of a multipart form or as the raw request body. The
+// format comes from ?format=, the file name or the content type.
+func readUpload(w http.ResponseWriter, r *http.Request) ([][]string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadSize)
+
+	format := r.URL.Query().Get("format")
+	body := io.Reader(r.Body)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		body = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	} else if format == "" {
+		switch mediaType {
+		case contentTypeCSV:
+			format = formatCSV
+		case contentTypeXLSX:
+			format = formatXLSX
+		}
+	}
+
+	switch format {
+	case formatCSV:
+		reader := csv.NewReader(body)
+		reader.FieldsPerRecord = -1
+		reader.TrimLeadingSpace = true
+		return reader.ReadAll()
+	case formatXLSX:
+		f, err := excelize.OpenReader(body)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		return f.GetRows(f.GetSheetName(0))
+	default:
+		return nil, errUnsupportedFormat
+	}
+}
+
+// writeSpreadsheet sends records as a CSV or XLSX attachment named
+// name.<format>.
+func writeSpreadsheet(w http.ResponseWriter, format, name string, records [][]string) error {
+	switch format {
+	case formatCSV:
+		w.Header().Set("Content-Type", contentTypeCSV)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.csv"`)
+		writer := csv.NewWriter(w)
+		if err := writer.WriteAll(records); err != nil {
+			return err
+		}
+		return writer.Error()
+	case formatXLSX:
+		f := excelize.NewFile()
+		defer f.Close()
+
+		sheet := f.GetSheetName(0)
+		for i, record := range records {
+			cell, err := excelize.CoordinatesToCellName(1, i+1)
+			if err != nil {
+				return err
+			}
+			row := make([]interface{}, len(record))
+			for j, v := range record {
+				row[j] = v
+			}
+			if err := f.SetSheetRow(sheet, cell, &row); err != nil {
+				return err
+			}
+		}
+
+		w.Header().Set("Content-Type", contentTypeXLSX)
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.xlsx"`)
+		return f.Write(w)
+	default:
+		return errUnsupportedFormat
+	}
+}
@@ -2,65 +2,299 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"kasir-api/model"
-	"kasir-api/repository"
+	"kasir-api/receipt"
 	"kasir-api/service"
 )
 
 type TransactionHandler struct {
-	service service.TransactionService
+	service    service.TransactionService
+	deliveries service.ReceiptDeliveryService
+	store      receipt.Store
+	paperWidth int
 }
 
-func NewTransactionHandler(service service.TransactionService) *TransactionHandler {
-	return &TransactionHandler{service: service}
+func NewTransactionHandler(service service.TransactionService, deliveries service.ReceiptDeliveryService,
+	store receipt.Store, paperWidth int) *TransactionHandler {
+	return &TransactionHandler{service: service, deliveries: deliveries, store: store, paperWidth: paperWidth}
 }
 
+// HandleCheckout sells the request's items. A user who may not give its
+// price overrides or discounts gets a 403 APPROVAL_REQUIRED challenge, and
+// retries the same request with the token a supervisor's approval returns
+// in the X-Approval-Token header.
 func (h *TransactionHandler) HandleCheckout(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
 	var req model.CheckoutRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
+	req.ApprovalToken = r.Header.Get("X-Approval-Token")
 
-	if len(req.Items) == 0 {
-		http.Error(w, "Items cannot be empty", http.StatusBadRequest)
+	transaction, err := h.service.Checkout(req, actorFromRequest(r))
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// HandlePreview prices a checkout request without committing it. Problems
+// with individual lines are part of the 200 response, not an error.
+func (h *TransactionHandler) HandlePreview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	var req model.CheckoutRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	for _, item := range req.Items {
-		if item.ProductID <= 0 {
-			http.Error(w, "product_id must be valid", http.StatusBadRequest)
+	preview, err := h.service.Preview(req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(preview)
+}
+
+// HandleTransactions lists transactions without their details, newest
+// first. They can be filtered with ?cashier_id=, ?terminal_id=,
+// ?outlet_id=, ?start_date= and ?end_date= (YYYY-MM-DD), and paged with
+// ?limit= and ?offset=.
+func (h *TransactionHandler) HandleTransactions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := model.TransactionFilter{TerminalID: query.Get("terminal_id")}
+	for name, dst := range map[string]*int{"cashier_id": &filter.CashierID, "outlet_id": &filter.OutletID, "limit": &filter.Limit, "offset": &filter.Offset} {
+		if s := query.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				writeError(w, r, invalidParameter("Invalid "+name))
+				return
+			}
+			*dst = n
+		}
+	}
+	if query.Get("start_date") != "" || query.Get("end_date") != "" {
+		startDate, endDate, hasRange, err := parseDateRange(r)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		if item.Quantity <= 0 {
-			http.Error(w, "quantity must be greater than 0", http.StatusBadRequest)
+		if !hasRange {
+			writeError(w, r, invalidParameter("start_date and end_date must be given together"))
 			return
 		}
+		filter.StartDate, filter.EndDate = &startDate, &endDate
 	}
 
-	transaction, err := h.service.Checkout(req)
+	transactions, err := h.service.GetAll(filter)
 	if err != nil {
-		if errors.Is(err, repository.ErrInsufficientStock) {
-			http.Error(w, "Insufficient stock", http.StatusBadRequest)
-			return
-		}
-		if errors.Is(err, repository.ErrProductNotFound) {
-			http.Error(w, "Product not found", http.StatusBadRequest)
+		writeError(w, r, err)
+		return
+	}
+
+	if transactions == nil {
+		transactions = []model.Transaction{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
+
+func (h *TransactionHandler) HandleTransactionByID(w http.ResponseWriter, r *http.Request) {
+	idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/transactions/"), "/")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		writeError(w, r, invalidParameter("Invalid Transaction ID"))
+		return
+	}
+
+	method := http.MethodGet
+	var handle func(http.ResponseWriter, *http.Request, int)
+	switch action {
+	case "":
+		handle = h.getByID
+	case "receipt":
+		handle = h.getReceipt
+	case "receipt/send":
+		method, handle = http.MethodPost, h.sendReceipt
+	case "receipt/deliveries":
+		handle = h.getReceiptDeliveries
+	case "void":
+		method, handle = http.MethodPost, h.void
+	default:
+		NotFound(w, r)
+		return
+	}
+
+	if r.Method != method {
+		methodNotAllowed(w, r, method)
+		return
+	}
+	handle(w, r, id)
+}
+
+func (h *TransactionHandler) getByID(w http.ResponseWriter, r *http.Request, id int) {
+	transaction, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if transaction == nil {
+		writeError(w, r, errTransactionNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// getReceipt renders the receipt of a stored transaction. The format is
+// chosen with ?format=text|escpos|pdf|html (text by default) and the paper
+// width with ?width=58|80.
+func (h *TransactionHandler) getReceipt(w http.ResponseWriter, r *http.Request, id int) {
+	paperWidth, err := paperWidthParam(r, h.paperWidth)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "text"
+	}
+
+	transaction, err := h.service.GetByID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if transaction == nil {
+		writeError(w, r, errTransactionNotFound)
+		return
+	}
+
+	writeDocument(w, r, receipt.FromTransaction(transaction, h.store), format, paperWidth, "receipt-"+strconv.Itoa(id))
+}
+
+// sendReceipt queues a digital copy of the receipt, e.g.
+// {"channel": "email", "recipient": "budi@example.com"}.
+func (h *TransactionHandler) sendReceipt(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.ReceiptRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	delivery, err := h.deliveries.Enqueue(id, req)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(delivery)
+}
+
+func (h *TransactionHandler) getReceiptDeliveries(w http.ResponseWriter, r *http.Request, id int) {
+	deliveries, err := h.deliveries.GetByTransactionID(id)
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	if deliveries == nil {
+		deliveries = []model.ReceiptDelivery{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deliveries)
+}
+
+// paperWidthParam reads the optional ?width= paper width, falling back to
+// the configured one.
+func paperWidthParam(r *http.Request, paperWidth int) (int, error) {
+	widthStr := r.URL.Query().Get("width")
+	if widthStr == "" {
+		return paperWidth, nil
+	}
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || !receipt.IsValidPaperWidth(width) {
+		return 0, invalidParameter("width must be 58 or 80")
+	}
+	return width, nil
+}
+
+// writeDocument renders doc in the requested format. Binary formats are
+// sent as attachments named name.<ext>.
+func writeDocument(w http.ResponseWriter, r *http.Request, doc receipt.Document, format string, paperWidth int, name string) {
+	switch format {
+	case "text":
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(receipt.Text(doc, paperWidth)))
+	case "escpos":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.bin"`)
+		w.Write(receipt.ESCPOS(doc, paperWidth))
+	case "pdf":
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", `inline; filename="`+name+`.pdf"`)
+		w.Write(receipt.PDF(doc, paperWidth))
+	case "html":
+		page, err := receipt.HTML(doc, paperWidth)
+		if err != nil {
+			writeError(w, r, err)
 			return
 		}
-		http.Error(w, "Failed to process checkout", http.StatusInternalServerError)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte(page))
+	default:
+		writeError(w, r, invalidParameter("format must be one of text, escpos, pdf, html"))
+	}
+}
+
+// void voids the transaction. A user who may not void on their own gets a
+// 403 APPROVAL_REQUIRED challenge, and retries with the token a supervisor's
+// approval returns in the X-Approval-Token header.
+func (h *TransactionHandler) void(w http.ResponseWriter, r *http.Request, id int) {
+	var req model.VoidRequest
+	if err := decodeJSON(w, r, &req); err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	transaction, err := h.service.Void(id, req, actorFromRequest(r), r.Header.Get("X-Approval-Token"))
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(transaction)
 }
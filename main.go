@@ -1,14 +1,18 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"kasir-api/config"
 	"kasir-api/database"
 	"kasir-api/handler"
+	"kasir-api/receipt"
 	"kasir-api/repository"
 	"kasir-api/service"
 )
@@ -19,23 +23,129 @@ func main() {
 	db := database.NewConnection(cfg.DBConn)
 	defer db.Close()
 
+	userRepo := repository.NewUserRepository(db)
+	userService := service.NewUserService(userRepo)
+	if err := userService.Bootstrap(cfg.AdminUsername, cfg.AdminPassword); err != nil {
+		log.Fatalf("Failed to create initial user: %v", err)
+	}
+	userHandler := handler.NewUserHandler(userService)
+
+	sessionRepo := repository.NewSessionRepository(db)
+	authService := service.NewAuthService(userRepo, sessionRepo, service.AuthConfig{
+		Secret:          []byte(cfg.JWTSecret),
+		AccessTokenTTL:  cfg.AccessTokenTTL,
+		RefreshTokenTTL: cfg.RefreshTokenTTL,
+	})
+	rateLimitStore := service.NewMemoryRateLimitStore()
+	rateLimiter := service.NewRateLimiter(rateLimitStore, cfg.RateLimits)
+	authLockout := service.NewLockout(rateLimitStore, service.LockoutConfig{
+		Threshold: cfg.LoginLockoutThreshold,
+		BaseDelay: cfg.LoginLockoutBase,
+		MaxDelay:  cfg.LoginLockoutMax,
+		Window:    cfg.LoginLockoutWindow,
+	})
+	authHandler := handler.NewAuthHandler(authService, authLockout)
+
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+	apiKeyHandler := handler.NewAPIKeyHandler(apiKeyService)
+
+	approvalRepo := repository.NewApprovalRepository(db)
+	approvalService := service.NewApprovalService(approvalRepo, userRepo, cfg.RolePermissions, authLockout)
+	approvalHandler := handler.NewApprovalHandler(approvalService)
+
+	auditRepo := repository.NewAuditRepository(db)
+	auditService := service.NewAuditService(auditRepo)
+	auditHandler := handler.NewAuditHandler(auditService)
+
 	categoryRepo := repository.NewCategoryRepository(db)
-	categoryService := service.NewCategoryService(categoryRepo)
+	categoryService := service.NewCategoryService(categoryRepo, auditService)
 	categoryHandler := handler.NewCategoryHandler(categoryService)
 
+	parentProductRepo := repository.NewParentProductRepository(db)
+	parentProductService := service.NewParentProductService(parentProductRepo, categoryRepo)
+	parentProductHandler := handler.NewParentProductHandler(parentProductService)
+
 	productRepo := repository.NewProductRepository(db)
-	productService := service.NewProductService(productRepo)
-	productHandler := handler.NewProductHandler(productService)
+	productService := service.NewProductService(productRepo, categoryRepo, parentProductRepo, auditService)
+	priceRepo := repository.NewPriceRepository(db)
+	priceService := service.NewPriceService(priceRepo, productRepo, cfg.PriceSchedulePollInterval)
+	go priceService.Run(context.Background())
+	productHandler := handler.NewProductHandler(productService, priceService)
+
+	store := receipt.Store{
+		Name:    cfg.StoreName,
+		Address: cfg.StoreAddress,
+		Phone:   cfg.StorePhone,
+		Footer:  cfg.ReceiptFooter,
+	}
+
+	receiptSenders := map[string]service.ReceiptSender{}
+	if cfg.SMTPHost != "" {
+		receiptSenders["email"] = service.NewSMTPReceiptSender(service.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		})
+	}
+	if cfg.ReceiptGatewayURL != "" {
+		receiptSenders["whatsapp"] = service.NewHTTPGatewayReceiptSender(cfg.ReceiptGatewayURL, cfg.ReceiptGatewayToken)
+	}
 
 	transactionRepo := repository.NewTransactionRepository(db)
-	transactionService := service.NewTransactionService(transactionRepo)
-	transactionHandler := handler.NewTransactionHandler(transactionService)
+	receiptDeliveryRepo := repository.NewReceiptDeliveryRepository(db)
+	receiptDeliveryService := service.NewReceiptDeliveryService(receiptDeliveryRepo, transactionRepo, receiptSenders,
+		service.ReceiptDeliveryConfig{
+			Store:        store,
+			PaperWidth:   cfg.ReceiptPaperWidth,
+			MaxAttempts:  cfg.ReceiptMaxAttempts,
+			RetryDelay:   cfg.ReceiptRetryDelay,
+			PollInterval: 10 * time.Second,
+		})
+	go receiptDeliveryService.Run(context.Background())
+
+	transactionService := service.NewTransactionService(transactionRepo, productRepo, receiptDeliveryService, approvalService,
+		auditService, service.ScaleBarcodeConfig{
+			WeightPrefixes: cfg.ScaleWeightPrefixes,
+			PricePrefixes:  cfg.ScalePricePrefixes,
+		}, cfg.DiscountApprovalPercent)
+	transactionHandler := handler.NewTransactionHandler(transactionService, receiptDeliveryService, store, cfg.ReceiptPaperWidth)
+
+	cartRepo := repository.NewCartRepository(db)
+	cartService := service.NewCartService(cartRepo, transactionService, cfg.CartReservationMinutes, cfg.CartMaxReservationMinutes)
+	cartHandler := handler.NewCartHandler(cartService)
+
+	closingRepo := repository.NewClosingRepository(db)
+	closingService := service.NewClosingService(closingRepo, cfg.TaxRate)
+	closingHandler := handler.NewClosingHandler(closingService, store, cfg.ReceiptPaperWidth)
+
+	outletRepo := repository.NewOutletRepository(db)
+	outletService := service.NewOutletService(outletRepo, auditService)
+	outletHandler := handler.NewOutletHandler(outletService)
+
+	shiftRepo := repository.NewShiftRepository(db)
+	shiftService := service.NewShiftService(shiftRepo, cfg.RolePermissions)
+	shiftHandler := handler.NewShiftHandler(shiftService)
 
 	reportRepo := repository.NewReportRepository(db)
 	reportService := service.NewReportService(reportRepo)
 	reportHandler := handler.NewReportHandler(reportService)
 
+	http.HandleFunc("/api/auth/login", authHandler.HandleLogin)
+	http.HandleFunc("/api/auth/refresh", authHandler.HandleRefresh)
+	http.HandleFunc("/api/auth/logout", authHandler.HandleLogout)
+	http.HandleFunc("/api/auth/me", authHandler.HandleMe)
+	http.HandleFunc("/api/users", userHandler.HandleUsers)
+	http.HandleFunc("/api/users/", userHandler.HandleUserByID)
+	http.HandleFunc("/api/api-keys", apiKeyHandler.HandleAPIKeys)
+	http.HandleFunc("/api/api-keys/", apiKeyHandler.HandleAPIKeyByID)
+	http.HandleFunc("/api/approvals/", approvalHandler.HandleApprove)
+	http.HandleFunc("/api/audit-log", auditHandler.HandleAuditLog)
+
 	http.HandleFunc("/api/categories", categoryHandler.HandleCategories)
+	http.HandleFunc("/api/categories/tree", categoryHandler.HandleCategoryTree)
 	http.HandleFunc("/api/categories/", func(w http.ResponseWriter, r *http.Request) {
 		if strings.HasSuffix(r.URL.Path, "/products") {
 			productHandler.HandleProductsByCategory(w, r)
@@ -45,11 +155,33 @@ func main() {
 	})
 
 	http.HandleFunc("/api/products", productHandler.HandleProducts)
+	http.HandleFunc("/api/products/import", productHandler.HandleImport)
+	http.HandleFunc("/api/products/export", productHandler.HandleExport)
 	http.HandleFunc("/api/products/", productHandler.HandleProductByID)
 
+	http.HandleFunc("/api/parent-products", parentProductHandler.HandleParentProducts)
+	http.HandleFunc("/api/parent-products/", parentProductHandler.HandleParentProductByID)
+
+	http.HandleFunc("/api/outlets", outletHandler.HandleOutlets)
+	http.HandleFunc("/api/outlets/", outletHandler.HandleOutletByID)
+
 	http.HandleFunc("/api/checkout", transactionHandler.HandleCheckout)
+	http.HandleFunc("/api/checkout/preview", transactionHandler.HandlePreview)
+	http.HandleFunc("/api/transactions", transactionHandler.HandleTransactions)
+	http.HandleFunc("/api/transactions/", transactionHandler.HandleTransactionByID)
+
+	http.HandleFunc("/api/carts", cartHandler.HandleCarts)
+	http.HandleFunc("/api/carts/", cartHandler.HandleCartByID)
+
+	http.HandleFunc("/api/shifts", shiftHandler.HandleShifts)
+	http.HandleFunc("/api/shifts/", shiftHandler.HandleShiftByID)
 
 	http.HandleFunc("/api/report/today", reportHandler.HandleTodayReport)
+	http.HandleFunc("/api/report/categories", reportHandler.HandleCategoryReport)
+	http.HandleFunc("/api/report/cashiers", reportHandler.HandleCashierReport)
+	http.HandleFunc("/api/report/x", closingHandler.HandleXReport)
+	http.HandleFunc("/api/report/z", closingHandler.HandleZReports)
+	http.HandleFunc("/api/report/z/", closingHandler.HandleZReportByNumber)
 
 	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
@@ -59,10 +191,13 @@ func main() {
 		})
 	})
 
+	http.HandleFunc("/", handler.NotFound)
+
 	addr := "0.0.0.0:" + cfg.Port
 	fmt.Printf("Server running on %s\n", addr)
 
-	if err := http.ListenAndServe(addr, nil); err != nil {
+	if err := http.ListenAndServe(addr, handler.RequestID(handler.RateLimit(rateLimiter, authService,
+		handler.Authenticate(authService, apiKeyService, authLockout, handler.Authorize(cfg.RolePermissions, http.DefaultServeMux))))); err != nil {
 		fmt.Printf("Server error: %v\n", err)
 	}
 }
@@ -0,0 +1,17 @@
+package model
+
+// Actor identifies who performs a write, so that it can be recorded
+// alongside the change. SessionID is the login session the request was
+// made in, and RequestID and IP identify the request for the audit log.
+// Integrations acting through an API key have an APIKeyID and the key's
+// Scopes instead of a user and role.
+type Actor struct {
+	UserID    int      `json:"user_id"`
+	Name      string   `json:"name"`
+	Role      string   `json:"role"`
+	SessionID int      `json:"-"`
+	RequestID string   `json:"-"`
+	IP        string   `json:"-"`
+	APIKeyID  int      `json:"-"`
+	Scopes    []string `json:"-"`
+}
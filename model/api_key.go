@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// APIKey lets an integration call the API without a user login. Only the
+// key's Prefix is kept in the clear; the key itself is shown once, when it
+// is created. Permissions are the key's scopes, and AllowedIPs, when not
+// empty, the addresses or CIDR ranges it may be used from.
+type APIKey struct {
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Permissions []string   `json:"permissions"`
+	AllowedIPs  []string   `json:"allowed_ips"`
+	CreatedBy   int        `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	LastUsedIP  *string    `json:"last_used_ip"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// APIKeyRequest represents the body of a request to create an API key.
+type APIKeyRequest struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	AllowedIPs  []string `json:"allowed_ips"`
+}
+
+// CreatedAPIKey is a new API key together with the key itself.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// ApprovalChallenge is returned when a user attempts an action their role
+// does not allow. A user whose role does allow it approves the challenge
+// with their PIN, and the action is retried with the resulting token.
+type ApprovalChallenge struct {
+	ID          string    `json:"challenge_id"`
+	Action      string    `json:"action"`
+	ResourceID  int       `json:"resource_id"`
+	RequestedBy int       `json:"requested_by"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// ApprovalRequest is a supervisor's PIN entry for a challenge.
+type ApprovalRequest struct {
+	Supervisor string `json:"supervisor"`
+	PIN        string `json:"pin"`
+}
+
+// Approval is a one-time token that lets the user who was challenged
+// perform Action on ResourceID once, sent in the X-Approval-Token header.
+type Approval struct {
+	ChallengeID string    `json:"challenge_id"`
+	Action      string    `json:"action"`
+	ResourceID  int       `json:"resource_id"`
+	ApprovedBy  int       `json:"approved_by"`
+	Token       string    `json:"approval_token"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
+
+// PINRequest sets a user's approval PIN.
+type PINRequest struct {
+	PIN string `json:"pin"`
+}
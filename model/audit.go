@@ -0,0 +1,61 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Audited entity types.
+const (
+	EntityCategory    = "category"
+	EntityProduct     = "product"
+	EntityTransaction = "transaction"
+	// Outlet entries record a product's stock or price at the outlet,
+	// with fields named like products[12].price.
+	EntityOutlet = "outlet"
+)
+
+// Audited actions.
+const (
+	AuditCreate  = "create"
+	AuditUpdate  = "update"
+	AuditDelete  = "delete"
+	AuditArchive = "archive"
+	AuditRestore = "restore"
+	AuditImport  = "import"
+	AuditVoid    = "void"
+)
+
+// AuditEntry records one write. Changes maps each changed field to its
+// before and after values; a field that did not exist before or after has
+// a null on that side. EntityID is 0 for bulk actions such as imports.
+type AuditEntry struct {
+	ID         int                    `json:"id"`
+	ActorID    *int                   `json:"actor_id"`
+	ActorName  string                 `json:"actor_name"`
+	Action     string                 `json:"action"`
+	EntityType string                 `json:"entity_type"`
+	EntityID   int                    `json:"entity_id"`
+	Changes    map[string]FieldChange `json:"changes"`
+	RequestID  string                 `json:"request_id"`
+	IP         string                 `json:"ip"`
+	CreatedAt  time.Time              `json:"created_at"`
+}
+
+// FieldChange is the value of a field before and after a write.
+type FieldChange struct {
+	Before json.RawMessage `json:"before"`
+	After  json.RawMessage `json:"after"`
+}
+
+// AuditFilter narrows the audit log. Zero values do not filter; From and
+// To bound the time the entry was recorded.
+type AuditFilter struct {
+	EntityType string
+	EntityID   int
+	ActorID    int
+	From       *time.Time
+	To         *time.Time
+	Limit      int
+	Offset     int
+}
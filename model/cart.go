@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Cart statuses. Only open carts can be edited; open and parked carts can be
+// checked out.
+const (
+	CartOpen      = "open"
+	CartParked    = "parked"
+	CartConverted = "converted"
+	CartCancelled = "cancelled"
+)
+
+// Cart is a server-side basket built up at a terminal. A cart can be parked
+// (held) and resumed later, optionally reserving its stock until
+// ReservedUntil.
+type Cart struct {
+	ID            int        `json:"id"`
+	TerminalID    string     `json:"terminal_id"`
+	OutletID      int        `json:"outlet_id"`
+	Label         string     `json:"label"`
+	Status        string     `json:"status"`
+	ReservedUntil *time.Time `json:"reserved_until,omitempty"`
+	TransactionID *int       `json:"transaction_id,omitempty"`
+	TotalAmount   int        `json:"total_amount"`
+	Items         []CartItem `json:"items"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// CartItem is a line of a cart priced at the product's current price.
+type CartItem struct {
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	IsWeighted  bool   `json:"is_weighted"`
+	Price       int    `json:"price"`
+	Quantity    int    `json:"quantity"`
+	Subtotal    int    `json:"subtotal"`
+}
+
+// CartFilter narrows down cart listings.
+type CartFilter struct {
+	Status     string
+	TerminalID string
+}
+
+// ParkCartRequest represents the body of a park request. With Reserve set
+// the cart's stock is held for ReserveMinutes, or the configured default,
+// up to the configured maximum.
+type ParkCartRequest struct {
+	Label          string `json:"label"`
+	Reserve        bool   `json:"reserve"`
+	ReserveMinutes int    `json:"reserve_minutes,omitempty"`
+}
@@ -1,8 +1,15 @@
 package model
 
-// Category represents a product category
+import "time"
+
+// Category represents a product category. Categories form a tree through
+// the optional ParentID. Archived categories have DeletedAt set.
 type Category struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
+	ID          int        `json:"id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	ParentID    *int       `json:"parent_id,omitempty"`
+	Children    []Category `json:"children,omitempty"`
+	DeletedAt   *time.Time `json:"deleted_at,omitempty"`
+	Version     int        `json:"version"`
 }
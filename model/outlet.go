@@ -0,0 +1,51 @@
+package model
+
+import "time"
+
+// Outlet is a store of the business. Products are shared by every outlet,
+// while each holds its own stock and may charge its own prices. Terminals
+// sell from the outlet they are assigned to, or from the default outlet.
+type Outlet struct {
+	ID        int       `json:"id"`
+	Name      string    `json:"name"`
+	Address   string    `json:"address"`
+	IsDefault bool      `json:"is_default"`
+	Terminals []string  `json:"terminals"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutletRequest represents the body of a request to create or update an
+// outlet.
+type OutletRequest struct {
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// OutletProduct is a product as sold at an outlet: its stock there and the
+// price charged, which is PriceOverride when the outlet has one and the
+// catalog price otherwise.
+type OutletProduct struct {
+	ProductID     int    `json:"product_id"`
+	Name          string `json:"name"`
+	Stock         int    `json:"stock"`
+	Price         int    `json:"price"`
+	PriceOverride *int   `json:"price_override"`
+}
+
+// OutletStockRequest sets a product's stock at an outlet.
+type OutletStockRequest struct {
+	Stock *int `json:"stock"`
+}
+
+// OutletPriceRequest sets the price an outlet charges for a product.
+type OutletPriceRequest struct {
+	Price *int `json:"price"`
+}
+
+// OutletSales summarises the sales made at one outlet.
+type OutletSales struct {
+	OutletID     int    `json:"outlet_id"`
+	Name         string `json:"name"`
+	Revenue      int    `json:"revenue"`
+	Transactions int    `json:"transactions"`
+}
@@ -0,0 +1,54 @@
+package model
+
+import "time"
+
+// Sources of a price change.
+const (
+	PriceSourceManual    = "manual"
+	PriceSourceImport    = "import"
+	PriceSourceScheduled = "scheduled"
+)
+
+// Scheduled price change statuses.
+const (
+	ScheduledPricePending   = "pending"
+	ScheduledPriceApplied   = "applied"
+	ScheduledPriceCancelled = "cancelled"
+)
+
+// PriceChange is an entry in a product's price history.
+type PriceChange struct {
+	ID        int    `json:"id"`
+	ProductID int    `json:"product_id"`
+	OldPrice  int    `json:"old_price"`
+	NewPrice  int    `json:"new_price"`
+	Source    string `json:"source"`
+	ChangedBy string `json:"changed_by"`
+	// OutletID is set when the price charged at one outlet changed, and
+	// nil for a change of the catalog price.
+	OutletID *int `json:"outlet_id,omitempty"`
+	// ScheduledChangeID is set when the change was applied from a
+	// scheduled price change.
+	ScheduledChangeID *int      `json:"scheduled_change_id,omitempty"`
+	ChangedAt         time.Time `json:"changed_at"`
+}
+
+// ScheduledPriceChange sets a product's price once EffectiveAt has passed.
+type ScheduledPriceChange struct {
+	ID          int        `json:"id"`
+	ProductID   int        `json:"product_id"`
+	Price       int        `json:"price"`
+	EffectiveAt time.Time  `json:"effective_at"`
+	Status      string     `json:"status"`
+	CreatedBy   string     `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	AppliedAt   *time.Time `json:"applied_at,omitempty"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+}
+
+// ScheduledPriceRequest asks for a product's price to change at a future
+// time.
+type ScheduledPriceRequest struct {
+	Price       int       `json:"price"`
+	EffectiveAt time.Time `json:"effective_at"`
+}
@@ -1,11 +1,77 @@
 package model
 
-// Product represents a product with optional category relationship
+import "time"
+
+// WeightUnitsPerKg is the number of stock/quantity units in one kilogram
+// (or litre) of a weighted product. Weighted quantities are stored as whole
+// grams (or millilitres) so they stay exact integers.
+const WeightUnitsPerKg = 1000
+
+// Product represents a sellable product with optional category relationship.
+//
+// A product may be a variant of a ParentProduct, in which case Attributes
+// describe what distinguishes it from its siblings (e.g. size or flavour).
+// For weighted products (IsWeighted) Price is per kilogram or litre, while
+// Stock and checkout quantities are expressed in grams or millilitres.
+//
+// Stock is the product's stock at the default outlet; the stock at every
+// outlet is managed through the outlet.
 type Product struct {
-	ID         int       `json:"id"`
-	Name       string    `json:"name"`
-	Price      int       `json:"price"`
-	Stock      int       `json:"stock"`
-	CategoryID *int      `json:"category_id,omitempty"`
-	Category   *Category `json:"category,omitempty"`
+	ID         int               `json:"id"`
+	Name       string            `json:"name"`
+	SKU        *string           `json:"sku,omitempty"`
+	Barcode    *string           `json:"barcode,omitempty"`
+	Price      int               `json:"price"`
+	Cost       int               `json:"cost"`
+	Stock      int               `json:"stock"`
+	IsWeighted bool              `json:"is_weighted"`
+	PLUCode    *string           `json:"plu_code,omitempty"`
+	ParentID   *int              `json:"parent_id,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	CategoryID *int              `json:"category_id,omitempty"`
+	Category   *Category         `json:"category,omitempty"`
+	DeletedAt  *time.Time        `json:"deleted_at,omitempty"`
+	Version    int               `json:"version"`
+}
+
+// ProductFilter narrows down product listings.
+type ProductFilter struct {
+	// Search matches product name, SKU or barcode, or the parent product name.
+	Search          string
+	ParentID        *int
+	IncludeArchived bool
+}
+
+// ParentProduct groups product variants (e.g. "Vit" in 330ml, 600ml and
+// 1000ml) so they can be listed and reported together.
+type ParentProduct struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	CategoryID  *int      `json:"category_id,omitempty"`
+	Variants    []Product `json:"variants"`
+}
+
+// LineSubtotal returns the rupiah subtotal for selling quantity units of the
+// product. Weighted subtotals are rounded half up to the nearest rupiah.
+func (p *Product) LineSubtotal(quantity int) int {
+	if !p.IsWeighted {
+		return p.Price * quantity
+	}
+	return DivRoundHalfUp(p.Price*quantity, WeightUnitsPerKg)
+}
+
+// WeightFor returns the quantity of a weighted product that subtotal rupiah
+// buys, rounded half up to the nearest unit, or 0 when it has no price.
+func (p *Product) WeightFor(subtotal int) int {
+	if p.Price <= 0 {
+		return 0
+	}
+	return DivRoundHalfUp(subtotal*WeightUnitsPerKg, p.Price)
+}
+
+// DivRoundHalfUp divides numerator by denominator and rounds the result half
+// up to the nearest integer. Both arguments are expected to be non-negative.
+func DivRoundHalfUp(numerator, denominator int) int {
+	return (numerator + denominator/2) / denominator
 }
@@ -0,0 +1,33 @@
+package model
+
+// ProductImportRow is a single product parsed from an import file.
+type ProductImportRow struct {
+	// Row is the 1-based line number in the file; the header is row 1.
+	Row          int
+	Name         string
+	SKU          string
+	Barcode      *string
+	CategoryName string
+	Price        int
+	Cost         int
+	Stock        int
+}
+
+// ProductImportResult summarises a product import. Rows are only written
+// when Applied is true, i.e. for a non dry-run import without errors.
+type ProductImportResult struct {
+	DryRun            bool             `json:"dry_run"`
+	Applied           bool             `json:"applied"`
+	TotalRows         int              `json:"total_rows"`
+	Created           int              `json:"created"`
+	Updated           int              `json:"updated"`
+	CategoriesCreated int              `json:"categories_created"`
+	Errors            []ImportRowError `json:"errors"`
+}
+
+// ImportRowError describes why a row of an import file was rejected.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// RateLimit allows Requests requests per Per, in bursts of up to Requests.
+// A zero RateLimit does not limit.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+}
+
+// Route groups with their own rate limit. Every request not in another
+// group is in RateLimitDefault.
+const (
+	RateLimitDefault = "default"
+	RateLimitLogin   = "login"
+	RateLimitAuth    = "auth"
+	RateLimitImport  = "import"
+	RateLimitReports = "reports"
+)
+
+// RateLimitGroups lists every rate limit group.
+var RateLimitGroups = []string{RateLimitDefault, RateLimitLogin, RateLimitAuth, RateLimitImport, RateLimitReports}
+
+// DefaultRateLimits is used for any group the configuration does not
+// override.
+var DefaultRateLimits = map[string]RateLimit{
+	RateLimitDefault: {Requests: 300, Per: time.Minute},
+	RateLimitLogin:   {Requests: 10, Per: time.Minute},
+	RateLimitAuth:    {Requests: 60, Per: time.Minute},
+	RateLimitImport:  {Requests: 5, Per: time.Minute},
+	RateLimitReports: {Requests: 30, Per: time.Minute},
+}
@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// Receipt delivery statuses. A delivery stays pending between retries and
+// becomes failed once it runs out of attempts.
+const (
+	DeliveryPending = "pending"
+	DeliverySending = "sending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// ReceiptDelivery tracks sending the receipt of a transaction to a customer
+// through a digital channel such as email or WhatsApp.
+type ReceiptDelivery struct {
+	ID            int        `json:"id"`
+	TransactionID int        `json:"transaction_id"`
+	Channel       string     `json:"channel"`
+	Recipient     string     `json:"recipient"`
+	Status        string     `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt time.Time  `json:"next_attempt_at"`
+	SentAt        *time.Time `json:"sent_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// ReceiptRequest asks for a digital copy of the receipt to be sent.
+type ReceiptRequest struct {
+	Channel   string `json:"channel"`
+	Recipient string `json:"recipient"`
+}
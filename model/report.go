@@ -1,10 +1,22 @@
 package model
 
+import "time"
+
 // SalesSummary represents daily sales summary report
 type SalesSummary struct {
 	TotalRevenue      int          `json:"total_revenue"`
 	TotalTransactions int          `json:"total_transactions"`
 	TopProducts       []TopProduct `json:"top_products"`
+
+	// TopWeightedProducts ranks the products sold by weight, which are left
+	// out of TopProducts so grams are not counted as items.
+	TopWeightedProducts []TopWeightedProduct `json:"top_weighted_products"`
+
+	// TopParentProducts aggregates the sales of variants by parent product.
+	TopParentProducts []TopParentProduct `json:"top_parent_products"`
+
+	// Outlets breaks the revenue down by outlet.
+	Outlets []OutletSales `json:"outlets"`
 }
 
 // TopProduct represents a product with its total sold quantity
@@ -13,3 +25,114 @@ type TopProduct struct {
 	ProductName string `json:"product_name"`
 	TotalSold   int    `json:"total_sold"`
 }
+
+// TopWeightedProduct represents a product sold by weight with the total
+// weight sold in grams or millilitres
+type TopWeightedProduct struct {
+	ProductID   int    `json:"product_id"`
+	ProductName string `json:"product_name"`
+	TotalWeight int    `json:"total_weight"`
+}
+
+// TopParentProduct represents a parent product with the total quantity sold
+// across all of its variants. Variants sold by weight are not counted.
+type TopParentProduct struct {
+	ParentProductID int    `json:"parent_product_id"`
+	Name            string `json:"name"`
+	TotalSold       int    `json:"total_sold"`
+}
+
+// CashierSales summarises the sales made by one cashier. Revenue,
+// Transactions and AverageBasket leave out voided sales, which are counted
+// in Voids and VoidedAmount instead.
+type CashierSales struct {
+	CashierID     int    `json:"cashier_id"`
+	Username      string `json:"username"`
+	Name          string `json:"name"`
+	Revenue       int    `json:"revenue"`
+	Transactions  int    `json:"transactions"`
+	AverageBasket int    `json:"average_basket"`
+	Voids         int    `json:"voids"`
+	VoidedAmount  int    `json:"voided_amount"`
+}
+
+// CategorySales represents the sales of a category. Quantity, Weight and
+// Revenue cover products assigned directly to the category, while the Total
+// fields also include every descendant category. Quantity counts the items
+// sold of products sold by the piece and Weight the grams or millilitres
+// sold of products sold by weight.
+type CategorySales struct {
+	CategoryID    int             `json:"category_id"`
+	CategoryName  string          `json:"category_name"`
+	ParentID      *int            `json:"parent_id,omitempty"`
+	Quantity      int             `json:"quantity"`
+	Weight        int             `json:"weight"`
+	Revenue       int             `json:"revenue"`
+	TotalQuantity int             `json:"total_quantity"`
+	TotalWeight   int             `json:"total_weight"`
+	TotalRevenue  int             `json:"total_revenue"`
+	Children      []CategorySales `json:"children,omitempty"`
+}
+
+// Closing report types. An X-report is a running total that can be taken
+// at any time; a Z-report closes the business day and is stored.
+const (
+	ClosingReportX = "x"
+	ClosingReportZ = "z"
+)
+
+// ClosingReport totals the sales since the last Z-report. Sales are counted
+// in the period they were made and voids in the period they were voided,
+// so a void after the day was closed shows in the next report. NetSales is
+// GrossSales less Voids, and Tax is the tax included in NetSales at TaxRate
+// percent. Transactions counts every sale made in the period. Checkout
+// records neither discounts nor returns, so there are none to deduct.
+type ClosingReport struct {
+	Type   string `json:"type"`
+	Number int    `json:"number,omitempty"`
+	// TerminalID and OutletID are set on X-reports for a single terminal
+	// or outlet.
+	TerminalID         string          `json:"terminal_id,omitempty"`
+	OutletID           int             `json:"outlet_id,omitempty"`
+	PeriodStart        *time.Time      `json:"period_start"`
+	PeriodEnd          time.Time       `json:"period_end"`
+	GrossSales         int             `json:"gross_sales"`
+	Voids              int             `json:"voids"`
+	VoidCount          int             `json:"void_count"`
+	NetSales           int             `json:"net_sales"`
+	TaxRate            int             `json:"tax_rate"`
+	Tax                int             `json:"tax"`
+	Transactions       int             `json:"transactions"`
+	Payments           []PaymentTotal  `json:"payments"`
+	Terminals          []TerminalTotal `json:"terminals"`
+	Outlets            []OutletTotal   `json:"outlets"`
+	FirstTransactionID *int            `json:"first_transaction_id"`
+	LastTransactionID  *int            `json:"last_transaction_id"`
+	GeneratedBy        int             `json:"generated_by"`
+	GeneratedAt        time.Time       `json:"generated_at"`
+}
+
+// PaymentTotal is the amount taken with one payment method by the sales
+// made in the period that have not been voided.
+type PaymentTotal struct {
+	Method       string `json:"method"`
+	Transactions int    `json:"transactions"`
+	Amount       int    `json:"amount"`
+}
+
+// OutletTotal is the total of one outlet's sales made in the period that
+// have not been voided.
+type OutletTotal struct {
+	OutletID     int    `json:"outlet_id"`
+	Name         string `json:"name"`
+	Transactions int    `json:"transactions"`
+	NetSales     int    `json:"net_sales"`
+}
+
+// TerminalTotal is the total of one terminal's sales made in the period
+// that have not been voided.
+type TerminalTotal struct {
+	TerminalID   string `json:"terminal_id"`
+	Transactions int    `json:"transactions"`
+	NetSales     int    `json:"net_sales"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import "slices"
+
+// Roles a user can have.
+const (
+	RoleCashier    = "cashier"
+	RoleSupervisor = "supervisor"
+	RoleOwner      = "owner"
+)
+
+// Roles lists every role, from least to most privileged.
+var Roles = []string{RoleCashier, RoleSupervisor, RoleOwner}
+
+// Permissions checked by the API, named "resource:action".
+const (
+	PermProductsRead       = "products:read"
+	PermProductsWrite      = "products:write"
+	PermCategoriesRead     = "categories:read"
+	PermCategoriesWrite    = "categories:write"
+	PermTransactionsCreate = "transactions:create"
+	PermTransactionsRead   = "transactions:read"
+	PermTransactionsVoid   = "transactions:void"
+	PermReportsRead        = "reports:read"
+	PermReportsClose       = "reports:close"
+	PermUsersManage        = "users:manage"
+	PermAuditRead          = "audit:read"
+	// PermOutletsWrite covers outlets themselves and what differs between
+	// them: their terminals, stock and price overrides.
+	PermOutletsRead  = "outlets:read"
+	PermOutletsWrite = "outlets:write"
+	// PermShiftsManage lets a user see and close other cashiers' shifts.
+	PermShiftsManage = "shifts:manage"
+	// PermTransactionsDiscount lets a user override prices at checkout and
+	// give discounts above the configured share of a line.
+	PermTransactionsDiscount = "transactions:discount"
+)
+
+// APIKeyPermissions lists the permissions an API key can be granted. Users,
+// shifts and closing the day stay with people.
+var APIKeyPermissions = []string{
+	PermProductsRead, PermProductsWrite, PermCategoriesRead, PermCategoriesWrite,
+	PermTransactionsCreate, PermTransactionsRead, PermTransactionsVoid, PermTransactionsDiscount, PermReportsRead,
+	PermAuditRead, PermOutletsRead, PermOutletsWrite,
+}
+
+// RolePermissions maps each role to the permissions it grants.
+type RolePermissions map[string][]string
+
+// Allows reports whether role grants permission.
+func (p RolePermissions) Allows(role, permission string) bool {
+	for _, granted := range p[role] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// ActorAllows reports whether actor holds permission: through the scopes of
+// its API key when it is one, and through its role otherwise.
+func (p RolePermissions) ActorAllows(actor Actor, permission string) bool {
+	if actor.APIKeyID != 0 {
+		return slices.Contains(actor.Scopes, permission)
+	}
+	return p.Allows(actor.Role, permission)
+}
+
+// DefaultRolePermissions is used for any role the configuration does not
+// override.
+var DefaultRolePermissions = RolePermissions{
+	RoleCashier: {
+		PermProductsRead, PermCategoriesRead, PermTransactionsCreate, PermTransactionsRead,
+	},
+	RoleSupervisor: {
+		PermProductsRead, PermProductsWrite, PermCategoriesRead, PermCategoriesWrite,
+		PermTransactionsCreate, PermTransactionsRead, PermTransactionsVoid, PermTransactionsDiscount,
+		PermReportsRead, PermReportsClose, PermShiftsManage, PermOutletsRead,
+	},
+	RoleOwner: {
+		PermProductsRead, PermProductsWrite, PermCategoriesRead, PermCategoriesWrite,
+		PermTransactionsCreate, PermTransactionsRead, PermTransactionsVoid, PermTransactionsDiscount,
+		PermReportsRead, PermReportsClose, PermShiftsManage, PermUsersManage, PermAuditRead, PermOutletsRead,
+		PermOutletsWrite,
+	},
+}
@@ -0,0 +1,79 @@
+package model
+
+import "time"
+
+// Shift statuses. A cashier can have one open shift at a time, and a
+// terminal's drawer can be used by one open shift at a time.
+const (
+	ShiftOpen   = "open"
+	ShiftClosed = "closed"
+)
+
+// Cash drawer event types.
+const (
+	CashIn  = "cash_in"
+	CashOut = "cash_out"
+)
+
+// Shift is a cashier's session at a terminal's cash drawer. ExpectedCash is
+// the opening float plus cash sales and cash-ins, less cash-outs. The
+// totals are live while the shift is open and fixed when it closes, when
+// the counted cash and its Variance (counted less expected; negative when
+// the drawer is short) are recorded.
+type Shift struct {
+	ID           int         `json:"id"`
+	CashierID    int         `json:"cashier_id"`
+	TerminalID   string      `json:"terminal_id"`
+	Status       string      `json:"status"`
+	OpeningFloat int         `json:"opening_float"`
+	CashSales    int         `json:"cash_sales"`
+	CashIn       int         `json:"cash_in"`
+	CashOut      int         `json:"cash_out"`
+	ExpectedCash int         `json:"expected_cash"`
+	CountedCash  *int        `json:"counted_cash,omitempty"`
+	Variance     *int        `json:"variance,omitempty"`
+	Note         string      `json:"note,omitempty"`
+	OpenedAt     time.Time   `json:"opened_at"`
+	ClosedAt     *time.Time  `json:"closed_at,omitempty"`
+	ClosedBy     *int        `json:"closed_by,omitempty"`
+	Events       []CashEvent `json:"events,omitempty"`
+}
+
+// CashEvent is cash put into or taken out of the drawer outside of a sale,
+// e.g. paying a supplier.
+type CashEvent struct {
+	ID        int       `json:"id"`
+	ShiftID   int       `json:"shift_id"`
+	Type      string    `json:"type"`
+	Amount    int       `json:"amount"`
+	Reason    string    `json:"reason"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OpenShiftRequest represents the body of a request to open a shift.
+type OpenShiftRequest struct {
+	TerminalID   string `json:"terminal_id"`
+	OpeningFloat int    `json:"opening_float"`
+}
+
+// CashEventRequest represents the body of a cash-in or cash-out.
+type CashEventRequest struct {
+	Type   string `json:"type"`
+	Amount int    `json:"amount"`
+	Reason string `json:"reason"`
+}
+
+// CloseShiftRequest represents the body of a request to close a shift.
+// CountedCash is required; a pointer tells a count of zero from none.
+type CloseShiftRequest struct {
+	CountedCash *int   `json:"counted_cash"`
+	Note        string `json:"note"`
+}
+
+// ShiftFilter narrows down shift listings.
+type ShiftFilter struct {
+	CashierID  int
+	TerminalID string
+	Status     string
+}
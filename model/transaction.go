@@ -2,31 +2,175 @@ package model
 
 import "time"
 
-// Transaction represents a completed transaction
+// Payment methods accepted at checkout. Only cash payments can exceed the
+// total and produce change.
+const (
+	PaymentCash     = "cash"
+	PaymentCard     = "card"
+	PaymentQRIS     = "qris"
+	PaymentTransfer = "transfer"
+)
+
+// Transaction represents a completed transaction. Details are left out of
+// transaction listings.
 type Transaction struct {
-	ID          int                 `json:"id"`
-	TotalAmount int                 `json:"total_amount"`
-	CreatedAt   time.Time           `json:"created_at"`
-	Details     []TransactionDetail `json:"details"`
+	ID            int                 `json:"id"`
+	TotalAmount   int                 `json:"total_amount"`
+	PaymentMethod string              `json:"payment_method"`
+	PaidAmount    int                 `json:"paid_amount"`
+	ChangeAmount  int                 `json:"change_amount"`
+	CashierID     *int                `json:"cashier_id"`
+	TerminalID    string              `json:"terminal_id"`
+	OutletID      int                 `json:"outlet_id"`
+	ShiftID       *int                `json:"shift_id"`
+	CreatedAt     time.Time           `json:"created_at"`
+	Details       []TransactionDetail `json:"details,omitempty"`
+
+	// DiscountApprovedBy is the supervisor who approved the sale's price
+	// overrides and discounts when the cashier was not allowed to give
+	// them on their own.
+	DiscountApprovedBy *int `json:"discount_approved_by,omitempty"`
+
+	// Void details. VoidApprovedBy is the supervisor who approved the void
+	// when the user who voided it was not allowed to on their own.
+	VoidedAt       *time.Time `json:"voided_at,omitempty"`
+	VoidedBy       *int       `json:"voided_by,omitempty"`
+	VoidApprovedBy *int       `json:"void_approved_by,omitempty"`
+	VoidReason     string     `json:"void_reason,omitempty"`
+}
+
+// VoidRequest voids a transaction, returning its items to stock.
+type VoidRequest struct {
+	Reason string `json:"reason"`
 }
 
-// TransactionDetail represents a line item in a transaction
+// TransactionDetail represents a line item in a transaction. Price is the
+// unit price (per kg or litre for weighted products) charged at the time of
+// sale, and RegularPrice the outlet's price when Price overrode it.
+// Subtotal is after Discount.
 type TransactionDetail struct {
 	ID            int    `json:"id"`
 	TransactionID int    `json:"transaction_id"`
 	ProductID     int    `json:"product_id"`
 	ProductName   string `json:"product_name"`
+	IsWeighted    bool   `json:"is_weighted"`
+	Price         int    `json:"price"`
+	RegularPrice  *int   `json:"regular_price,omitempty"`
 	Quantity      int    `json:"quantity"`
+	Discount      int    `json:"discount,omitempty"`
 	Subtotal      int    `json:"subtotal"`
 }
 
-// CheckoutItem represents a single item in checkout request
+// CheckoutItem represents a single item in checkout request.
+//
+// An item is identified either by ProductID or by a Barcode. A product
+// barcode defaults Quantity to 1, while an in-store scale label supplies both
+// the product and the quantity. Quantity is in grams or millilitres for
+// weighted products.
+//
+// Price, when set, is charged instead of the outlet's unit price, and
+// Discount is taken off the line's subtotal. Without the
+// transactions:discount permission, overriding a price or discounting more
+// than the configured share of a line needs a supervisor's approval. At a
+// cart's checkout, items only give the cart's lines, by ProductID, their
+// price overrides and discounts.
 type CheckoutItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID int    `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+	Barcode   string `json:"barcode,omitempty"`
+	Price     *int   `json:"price,omitempty"`
+	Discount  int    `json:"discount,omitempty"`
+
+	// FixedSubtotal is set when a price-embedded barcode dictates the amount
+	// to charge for the line instead of price × quantity. The quantity is
+	// then worked out from the price charged at the sale's outlet.
+	FixedSubtotal int `json:"-"`
 }
 
-// CheckoutRequest represents the checkout request body
+// CheckoutRequest represents the checkout request body. PaymentMethod
+// defaults to cash and PaidAmount to the exact total. When Receipt is set a
+// digital receipt is queued once the transaction is committed.
 type CheckoutRequest struct {
-	Items []CheckoutItem `json:"items"`
+	Items         []CheckoutItem  `json:"items"`
+	PaymentMethod string          `json:"payment_method,omitempty"`
+	PaidAmount    int             `json:"paid_amount,omitempty"`
+	Receipt       *ReceiptRequest `json:"receipt,omitempty"`
+	TerminalID    string          `json:"terminal_id"`
+
+	// CartID is set when checking out a cart, whose lines are then sold
+	// with the price overrides and discounts in Items, and whose terminal
+	// replaces TerminalID.
+	CartID int `json:"-"`
+	// CashierID is the user making the sale, set from the checkout's actor.
+	CashierID int `json:"-"`
+	// ApprovalToken is a supervisor's approval of the request's price
+	// overrides and discounts, from the X-Approval-Token header.
+	ApprovalToken string `json:"-"`
+}
+
+// TransactionFilter narrows the transaction list. Zero values do not
+// filter; dates cover whole days.
+type TransactionFilter struct {
+	CashierID  int
+	TerminalID string
+	OutletID   int
+	StartDate  *time.Time
+	EndDate    *time.Time
+	Limit      int
+	Offset     int
+}
+
+// IsValidPaymentMethod reports whether method is one of the supported
+// payment methods.
+func IsValidPaymentMethod(method string) bool {
+	switch method {
+	case PaymentCash, PaymentCard, PaymentQRIS, PaymentTransfer:
+		return true
+	}
+	return false
+}
+
+// Checkout problem codes reported per line by the checkout preview.
+const (
+	ProblemProductNotFound   = "PRODUCT_NOT_FOUND"
+	ProblemInsufficientStock = "INSUFFICIENT_STOCK"
+	ProblemInvalidBarcode    = "INVALID_BARCODE"
+	ProblemInvalidDiscount   = "INVALID_DISCOUNT"
+)
+
+// CheckoutLine is a checkout item priced at the product's current price,
+// or at the item's Price, which then keeps the former in RegularPrice.
+// Subtotal is after Discount. Problem is set when the line cannot be sold
+// as requested.
+type CheckoutLine struct {
+	ProductID    int              `json:"product_id"`
+	ProductName  string           `json:"product_name,omitempty"`
+	IsWeighted   bool             `json:"is_weighted"`
+	Price        int              `json:"price"`
+	RegularPrice *int             `json:"regular_price,omitempty"`
+	Quantity     int              `json:"quantity"`
+	Discount     int              `json:"discount,omitempty"`
+	Subtotal     int              `json:"subtotal"`
+	Problem      *CheckoutProblem `json:"problem,omitempty"`
+}
+
+// CheckoutProblem describes why a checkout line cannot be sold. Line is the
+// 0-based index of the item in the request.
+type CheckoutProblem struct {
+	Line      int    `json:"line"`
+	ProductID int    `json:"product_id,omitempty"`
+	Barcode   string `json:"barcode,omitempty"`
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	Requested int    `json:"requested,omitempty"`
+	Available *int   `json:"available,omitempty"`
+}
+
+// CheckoutPreview is the outcome of pricing a checkout request without
+// committing it. Valid is false when any line has a problem.
+type CheckoutPreview struct {
+	Lines       []CheckoutLine    `json:"lines"`
+	TotalAmount int               `json:"total_amount"`
+	Valid       bool              `json:"valid"`
+	Problems    []CheckoutProblem `json:"problems"`
 }
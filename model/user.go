@@ -0,0 +1,46 @@
+package model
+
+import "time"
+
+// User is a staff account that can log in to the API.
+type User struct {
+	ID           int       `json:"id"`
+	Username     string    `json:"username"`
+	Name         string    `json:"name"`
+	Role         string    `json:"role"`
+	PasswordHash string    `json:"-"`
+	PINHash      string    `json:"-"`
+	Active       bool      `json:"active"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// UserRequest creates a user account. Role defaults to cashier. PIN is
+// optional; supervisors need one to approve restricted actions.
+type UserRequest struct {
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+	Password string `json:"password"`
+	PIN      string `json:"pin,omitempty"`
+}
+
+// LoginRequest exchanges credentials for a token pair.
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// RefreshRequest exchanges a refresh token for a new token pair.
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// TokenPair is returned by login and refresh. The access token is sent as
+// "Authorization: Bearer <token>" and expires after ExpiresIn seconds; the
+// refresh token is single use.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
@@ -0,0 +1,66 @@
+package receipt
+
+import (
+	"fmt"
+	"strconv"
+
+	"kasir-api/model"
+)
+
+// FromClosingReport lays out an X- or Z-report. Payment methods are listed
+// as items and the sales figures as totals, followed by each outlet's when
+// the report covers several.
+func FromClosingReport(r *model.ClosingReport, store Store) Document {
+	doc := NewDocument(store)
+
+	title := "LAPORAN X"
+	if r.Type == model.ClosingReportZ {
+		title = fmt.Sprintf("LAPORAN Z #%04d", r.Number)
+	}
+	doc.Meta = []Row{{Label: title, Bold: true}}
+	if r.TerminalID != "" {
+		doc.Meta = append(doc.Meta, Row{Label: "Terminal", Value: r.TerminalID})
+	}
+	if r.OutletID != 0 {
+		outlet := strconv.Itoa(r.OutletID)
+		if len(r.Outlets) == 1 {
+			outlet = r.Outlets[0].Name
+		}
+		doc.Meta = append(doc.Meta, Row{Label: "Outlet", Value: outlet})
+	}
+	if r.PeriodStart != nil {
+		doc.Meta = append(doc.Meta, Row{Label: "Dari", Value: r.PeriodStart.Format("02/01/2006 15:04")})
+	}
+	doc.Meta = append(doc.Meta, Row{Label: "Sampai", Value: r.PeriodEnd.Format("02/01/2006 15:04")})
+
+	for _, p := range r.Payments {
+		label, ok := paymentLabels[p.Method]
+		if !ok {
+			label = p.Method
+		}
+		doc.Items = append(doc.Items, Item{Name: label, Detail: fmt.Sprintf("%d transaksi", p.Transactions), Amount: FormatRupiah(p.Amount)})
+	}
+
+	doc.Totals = []Row{
+		{Label: fmt.Sprintf("Penjualan Kotor (%d)", r.Transactions), Value: FormatRupiah(r.GrossSales)},
+		{Label: fmt.Sprintf("Void (%d)", r.VoidCount), Value: FormatRupiah(-r.Voids)},
+		{Label: "PENJUALAN BERSIH", Value: FormatRupiah(r.NetSales), Bold: true},
+		{Label: fmt.Sprintf("Termasuk Pajak %d%%", r.TaxRate), Value: FormatRupiah(r.Tax)},
+		{Label: "No. Awal", Value: transactionNumber(r.FirstTransactionID)},
+		{Label: "No. Akhir", Value: transactionNumber(r.LastTransactionID)},
+	}
+	if len(r.Outlets) > 1 {
+		for _, o := range r.Outlets {
+			label := fmt.Sprintf("%s (%d)", o.Name, o.Transactions)
+			doc.Totals = append(doc.Totals, Row{Label: label, Value: FormatRupiah(o.NetSales)})
+		}
+	}
+	return doc
+}
+
+func transactionNumber(id *int) string {
+	if id == nil {
+		return "-"
+	}
+	return strconv.Itoa(*id)
+}
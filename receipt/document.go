@@ -0,0 +1,103 @@
+// Package receipt renders printable documents such as sales receipts as
+// plain text, ESC/POS byte streams, PDF and HTML.
+package receipt
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Paper widths supported by the receipt printers, in millimetres.
+const (
+	Paper58mm = 58
+	Paper80mm = 80
+)
+
+// Store holds the configurable header and footer printed on documents.
+type Store struct {
+	Name    string
+	Address string
+	Phone   string
+	Footer  string
+}
+
+// Document is a format-independent receipt layout. Every renderer prints
+// the same sections in the same order: header, meta, items, totals, footer.
+type Document struct {
+	Header []string
+	Meta   []Row
+	Items  []Item
+	Totals []Row
+	Footer []string
+}
+
+// Row is a label printed on the left with its value aligned to the right.
+type Row struct {
+	Label string
+	Value string
+	Bold  bool
+}
+
+// Item is a line item: the name on its own line followed by the quantity
+// detail and the line amount.
+type Item struct {
+	Name   string
+	Detail string
+	Amount string
+}
+
+// Columns returns the number of monospace characters that fit on one line
+// of the given paper width using the printer's standard font.
+func Columns(paperWidth int) int {
+	if paperWidth == Paper58mm {
+		return 32
+	}
+	return 48
+}
+
+// IsValidPaperWidth reports whether width is a supported paper width.
+func IsValidPaperWidth(width int) bool {
+	return width == Paper58mm || width == Paper80mm
+}
+
+// FormatRupiah formats an amount with dots as thousands separators, e.g.
+// 1234567 becomes "1.234.567".
+func FormatRupiah(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	digits := strconv.Itoa(amount)
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(d)
+	}
+	return sign + b.String()
+}
+
+// FormatWeight formats grams (or millilitres) as kilograms (or litres) with
+// three decimals and a decimal comma, e.g. 1250 becomes "1,250".
+func FormatWeight(units int) string {
+	return fmt.Sprintf("%d,%03d", units/1000, units%1000)
+}
+
+// NewDocument returns an empty document carrying the store header and
+// footer.
+func NewDocument(store Store) Document {
+	var doc Document
+	for _, l := range []string{store.Name, store.Address, store.Phone} {
+		if l != "" {
+			doc.Header = append(doc.Header, l)
+		}
+	}
+	if store.Footer != "" {
+		doc.Footer = strings.Split(store.Footer, "\n")
+	}
+	return doc
+}
@@ -0,0 +1,57 @@
+package receipt
+
+import "bytes"
+
+// ESC/POS command sequences understood by common thermal receipt printers.
+var (
+	escInit       = []byte{0x1b, '@'}
+	escBoldOn     = []byte{0x1b, 'E', 1}
+	escBoldOff    = []byte{0x1b, 'E', 0}
+	escDoubleHigh = []byte{0x1d, '!', 0x10}
+	escNormalSize = []byte{0x1d, '!', 0x00}
+	escFeed       = []byte{0x1b, 'd', 4}
+	escPartialCut = []byte{0x1d, 'V', 66, 0}
+)
+
+// ESCPOS renders the document as an ESC/POS byte stream for a thermal
+// printer loaded with paper of the given width. The first header line is
+// printed bold at double height and the paper is cut at the end.
+func ESCPOS(doc Document, paperWidth int) []byte {
+	var b bytes.Buffer
+	b.Write(escInit)
+
+	for i, line := range textLines(doc, Columns(paperWidth)) {
+		title := i == 0 && len(doc.Header) > 0
+		if title {
+			b.Write(escDoubleHigh)
+		}
+		if line.bold {
+			b.Write(escBoldOn)
+		}
+		b.Write(asciiBytes(line.text))
+		if line.bold {
+			b.Write(escBoldOff)
+		}
+		if title {
+			b.Write(escNormalSize)
+		}
+		b.WriteByte('\n')
+	}
+
+	b.Write(escFeed)
+	b.Write(escPartialCut)
+	return b.Bytes()
+}
+
+// asciiBytes replaces characters outside printable ASCII with '?', since
+// the printer's default code page cannot be relied on for anything else.
+func asciiBytes(s string) []byte {
+	out := make([]byte, 0, len(s))
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		out = append(out, byte(r))
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package receipt
+
+import (
+	"bytes"
+	"html/template"
+)
+
+var htmlTemplate = template.Must(template.New("receipt").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: monospace; width: {{.Width}}mm; margin: 0 auto; }
+.center { text-align: center; }
+table { width: 100%; border-collapse: collapse; }
+td.amount { text-align: right; white-space: nowrap; }
+td.detail { padding-left: 1em; }
+.bold { font-weight: bold; }
+hr { border: none; border-top: 1px dashed #000; }
+</style>
+</head>
+<body>
+{{- range $i, $h := .Doc.Header}}
+<div class="center{{if eq $i 0}} bold{{end}}">{{$h}}</div>
+{{- end}}
+<hr>
+{{- if .Doc.Meta}}
+<table>
+{{- range .Doc.Meta}}
+<tr{{if .Bold}} class="bold"{{end}}><td>{{.Label}}</td><td class="amount">{{.Value}}</td></tr>
+{{- end}}
+</table>
+<hr>
+{{- end}}
+{{- if .Doc.Items}}
+<table>
+{{- range .Doc.Items}}
+<tr><td colspan="2">{{.Name}}</td></tr>
+<tr><td class="detail">{{.Detail}}</td><td class="amount">{{.Amount}}</td></tr>
+{{- end}}
+</table>
+<hr>
+{{- end}}
+<table>
+{{- range .Doc.Totals}}
+<tr{{if .Bold}} class="bold"{{end}}><td>{{.Label}}</td><td class="amount">{{.Value}}</td></tr>
+{{- end}}
+</table>
+{{- if .Doc.Footer}}
+<hr>
+{{- range .Doc.Footer}}
+<div class="center">{{.}}</div>
+{{- end}}
+{{- end}}
+</body>
+</html>
+`))
+
+// HTML renders the document as a standalone HTML page sized for the paper
+// width.
+func HTML(doc Document, paperWidth int) (string, error) {
+	var b bytes.Buffer
+	var title string
+	if len(doc.Header) > 0 {
+		title = doc.Header[0]
+	}
+
+	err := htmlTemplate.Execute(&b, struct {
+		Doc   Document
+		Title string
+		Width int
+	}{doc, title, paperWidth})
+	if err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
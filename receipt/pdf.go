@@ -0,0 +1,69 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+const (
+	pointsPerMM = 72 / 25.4
+	pdfMargin   = 8.0
+)
+
+// PDF renders the document as a single-page PDF shaped like a till roll of
+// the given paper width, using the built-in Courier font so that the text
+// layout matches the plain text receipt.
+func PDF(doc Document, paperWidth int) []byte {
+	cols := Columns(paperWidth)
+	lines := textLines(doc, cols)
+
+	pageWidth := float64(paperWidth) * pointsPerMM
+	// Courier glyphs are 0.6 em wide.
+	fontSize := (pageWidth - 2*pdfMargin) / (0.6 * float64(cols))
+	leading := fontSize * 1.2
+	pageHeight := 2*pdfMargin + leading*float64(len(lines))
+
+	var content bytes.Buffer
+	fmt.Fprintf(&content, "BT\n%.2f TL\n%.2f %.2f Td\n", leading, pdfMargin, pageHeight-pdfMargin-fontSize)
+	for _, line := range lines {
+		font := "F1"
+		if line.bold {
+			font = "F2"
+		}
+		fmt.Fprintf(&content, "/%s %.2f Tf\n(%s) Tj\nT*\n", font, fontSize, pdfEscape(line.text))
+	}
+	content.WriteString("ET\n")
+
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] "+
+			"/Resources << /Font << /F1 4 0 R /F2 5 0 R >> >> /Contents 6 0 R >>", pageWidth, pageHeight),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier-Bold >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.String()),
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes()
+}
+
+var pdfEscaper = strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`)
+
+func pdfEscape(s string) string {
+	return pdfEscaper.Replace(string(asciiBytes(s)))
+}
@@ -0,0 +1,156 @@
+package receipt
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kasir-api/model"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var testStore = Store{
+	Name:    "Toko Kasir",
+	Address: "Jl. Merdeka No. 1, Bandung",
+	Phone:   "022-123456",
+	Footer:  "Terima kasih\nBarang yang sudah dibeli tidak dapat dikembalikan",
+}
+
+func testTransaction() *model.Transaction {
+	return &model.Transaction{
+		ID:            1042,
+		TotalAmount:   60250,
+		PaymentMethod: model.PaymentCash,
+		PaidAmount:    100000,
+		ChangeAmount:  39750,
+		CreatedAt:     time.Date(2026, 3, 14, 9, 26, 0, 0, time.UTC),
+		Details: []model.TransactionDetail{
+			{ProductName: "Indomie Goreng", Price: 3500, Quantity: 3, Subtotal: 10500},
+			{ProductName: "Beras Pandan Wangi Premium Kemasan Karung Ekonomis", Price: 14500, Quantity: 2500,
+				IsWeighted: true, Subtotal: 36250},
+			{ProductName: "Teh Botol (350ml)", Price: 5000, Quantity: 3, Discount: 1500, Subtotal: 13500},
+		},
+	}
+}
+
+func testZReport() *model.ClosingReport {
+	start := time.Date(2026, 3, 13, 21, 2, 0, 0, time.UTC)
+	first, last := 990, 1042
+	return &model.ClosingReport{
+		Type:        model.ClosingReportZ,
+		Number:      73,
+		PeriodStart: &start,
+		PeriodEnd:   time.Date(2026, 3, 14, 21, 5, 0, 0, time.UTC),
+		GrossSales:  4250000,
+		Voids:       61750,
+		VoidCount:   1,
+		NetSales:    4188250,
+		TaxRate:     11,
+		Tax:         415052,
+		Payments: []model.PaymentTotal{
+			{Method: model.PaymentCash, Transactions: 40, Amount: 2900000},
+			{Method: model.PaymentQRIS, Transactions: 12, Amount: 1288250},
+		},
+		Transactions: 53,
+		Outlets: []model.OutletTotal{
+			{OutletID: 1, Name: "Toko Utama", Transactions: 38, NetSales: 3100000},
+			{OutletID: 2, Name: "Cabang Selatan", Transactions: 14, NetSales: 1088250},
+		},
+		FirstTransactionID: &first,
+		LastTransactionID:  &last,
+	}
+}
+
+// renderers lists every format with the golden file extension it is
+// compared under.
+var renderers = []struct {
+	ext    string
+	render func(doc Document, paperWidth int) ([]byte, error)
+}{
+	{"txt", func(doc Document, w int) ([]byte, error) { return []byte(Text(doc, w)), nil }},
+	{"escpos", func(doc Document, w int) ([]byte, error) { return ESCPOS(doc, w), nil }},
+	{"html", func(doc Document, w int) ([]byte, error) {
+		s, err := HTML(doc, w)
+		return []byte(s), err
+	}},
+	{"pdf", func(doc Document, w int) ([]byte, error) { return PDF(doc, w), nil }},
+}
+
+func TestRenderers(t *testing.T) {
+	docs := []struct {
+		name string
+		doc  Document
+	}{
+		{"transaction", FromTransaction(testTransaction(), testStore)},
+		{"z-report", FromClosingReport(testZReport(), testStore)},
+	}
+	for _, d := range docs {
+		for _, width := range []int{Paper58mm, Paper80mm} {
+			for _, r := range renderers {
+				name := fmt.Sprintf("%s-%dmm.%s", d.name, width, r.ext)
+				t.Run(name, func(t *testing.T) {
+					got, err := r.render(d.doc, width)
+					if err != nil {
+						t.Fatal(err)
+					}
+					checkGolden(t, name, got)
+				})
+			}
+		}
+	}
+}
+
+func TestTextFitsPaperWidth(t *testing.T) {
+	doc := FromTransaction(testTransaction(), testStore)
+	for _, width := range []int{Paper58mm, Paper80mm} {
+		for _, line := range textLines(doc, Columns(width)) {
+			if n := len([]rune(line.text)); n > Columns(width) {
+				t.Errorf("%dmm: line %q is %d columns wide, want at most %d", width, line.text, n, Columns(width))
+			}
+		}
+	}
+}
+
+func TestFormatRupiah(t *testing.T) {
+	tests := []struct {
+		amount int
+		want   string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1.000"},
+		{1234567, "1.234.567"},
+		{-38250, "-38.250"},
+	}
+	for _, tt := range tests {
+		if got := FormatRupiah(tt.amount); got != tt.want {
+			t.Errorf("FormatRupiah(%d) = %q, want %q", tt.amount, got, tt.want)
+		}
+	}
+}
+
+// checkGolden compares got with testdata/name.golden, rewriting the file
+// instead when the tests run with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test ./receipt -update to create it", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from %s; run go test ./receipt -update if the change is intended\ngot:\n%s", name, path, got)
+	}
+}
@@ -0,0 +1,130 @@
+package receipt
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Text renders the document as plain monospace text sized for the paper
+// width.
+func Text(doc Document, paperWidth int) string {
+	var b strings.Builder
+	for _, line := range textLines(doc, Columns(paperWidth)) {
+		b.WriteString(line.text)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// textLine is a rendered line plus the styling hints used by the ESC/POS
+// renderer.
+type textLine struct {
+	text   string
+	center bool
+	bold   bool
+}
+
+func textLines(doc Document, cols int) []textLine {
+	var lines []textLine
+	separator := textLine{text: strings.Repeat("-", cols)}
+
+	for i, h := range doc.Header {
+		for _, l := range wrap(h, cols) {
+			lines = append(lines, textLine{text: center(l, cols), center: true, bold: i == 0})
+		}
+	}
+	lines = append(lines, separator)
+
+	if len(doc.Meta) > 0 {
+		for _, r := range doc.Meta {
+			lines = append(lines, textLine{text: justify(r.Label, r.Value, cols), bold: r.Bold})
+		}
+		lines = append(lines, separator)
+	}
+
+	if len(doc.Items) > 0 {
+		for _, item := range doc.Items {
+			for _, l := range wrap(item.Name, cols) {
+				lines = append(lines, textLine{text: l})
+			}
+			lines = append(lines, textLine{text: justify("  "+item.Detail, item.Amount, cols)})
+		}
+		lines = append(lines, separator)
+	}
+
+	for _, r := range doc.Totals {
+		lines = append(lines, textLine{text: justify(r.Label, r.Value, cols), bold: r.Bold})
+	}
+
+	if len(doc.Footer) > 0 {
+		if len(doc.Totals) > 0 {
+			lines = append(lines, separator)
+		}
+		for _, f := range doc.Footer {
+			for _, l := range wrap(f, cols) {
+				lines = append(lines, textLine{text: center(l, cols), center: true})
+			}
+		}
+	}
+	return lines
+}
+
+// justify puts label on the left and value on the right of a cols wide
+// line, truncating the label when both do not fit.
+func justify(label, value string, cols int) string {
+	space := cols - utf8.RuneCountInString(value) - 1
+	if space < 0 {
+		space = 0
+	}
+	label = truncate(label, space)
+	pad := cols - utf8.RuneCountInString(label) - utf8.RuneCountInString(value)
+	if pad < 1 {
+		pad = 1
+	}
+	return label + strings.Repeat(" ", pad) + value
+}
+
+func center(s string, cols int) string {
+	pad := (cols - utf8.RuneCountInString(s)) / 2
+	if pad <= 0 {
+		return s
+	}
+	return strings.Repeat(" ", pad) + s
+}
+
+func truncate(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+// wrap splits s into lines of at most cols characters, breaking on spaces
+// where possible.
+func wrap(s string, cols int) []string {
+	var lines []string
+	var current string
+	for _, word := range strings.Fields(s) {
+		for utf8.RuneCountInString(word) > cols {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			lines = append(lines, string([]rune(word)[:cols]))
+			word = string([]rune(word)[cols:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case utf8.RuneCountInString(current)+1+utf8.RuneCountInString(word) <= cols:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" || len(lines) == 0 {
+		lines = append(lines, current)
+	}
+	return lines
+}
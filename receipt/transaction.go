@@ -0,0 +1,62 @@
+package receipt
+
+import (
+	"fmt"
+	"strconv"
+
+	"kasir-api/model"
+)
+
+var paymentLabels = map[string]string{
+	model.PaymentCash:     "Tunai",
+	model.PaymentCard:     "Kartu",
+	model.PaymentQRIS:     "QRIS",
+	model.PaymentTransfer: "Transfer",
+}
+
+// FromTransaction lays out the receipt of a stored transaction.
+func FromTransaction(t *model.Transaction, store Store) Document {
+	doc := NewDocument(store)
+
+	doc.Meta = []Row{
+		{Label: "No", Value: strconv.Itoa(t.ID)},
+		{Label: "Tanggal", Value: t.CreatedAt.Format("02/01/2006 15:04")},
+	}
+
+	// Lines show their amount before discount; the discounts are totalled
+	// below the subtotal.
+	totalQuantity := 0
+	for _, d := range t.Details {
+		var detail string
+		if d.IsWeighted {
+			detail = fmt.Sprintf("%s kg x %s", FormatWeight(d.Quantity), FormatRupiah(d.Price))
+			totalQuantity++
+		} else {
+			detail = fmt.Sprintf("%d x %s", d.Quantity, FormatRupiah(d.Price))
+			totalQuantity += d.Quantity
+		}
+		doc.Items = append(doc.Items, Item{Name: d.ProductName, Detail: detail, Amount: FormatRupiah(d.Subtotal + d.Discount)})
+	}
+
+	subtotal, discount := 0, 0
+	for _, d := range t.Details {
+		subtotal += d.Subtotal + d.Discount
+		discount += d.Discount
+	}
+
+	paymentLabel, ok := paymentLabels[t.PaymentMethod]
+	if !ok {
+		paymentLabel = t.PaymentMethod
+	}
+
+	doc.Totals = []Row{{Label: fmt.Sprintf("Subtotal (%d item)", totalQuantity), Value: FormatRupiah(subtotal)}}
+	if discount > 0 {
+		doc.Totals = append(doc.Totals, Row{Label: "Diskon", Value: "-" + FormatRupiah(discount)})
+	}
+	doc.Totals = append(doc.Totals,
+		Row{Label: "TOTAL", Value: FormatRupiah(t.TotalAmount), Bold: true},
+		Row{Label: paymentLabel, Value: FormatRupiah(t.PaidAmount)},
+		Row{Label: "Kembali", Value: FormatRupiah(t.ChangeAmount)},
+	)
+	return doc
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/model"
+
+	"github.com/lib/pq"
+)
+
+// APIKeyRepository stores API keys by the hash of the key.
+type APIKeyRepository interface {
+	// GetAll lists every key, revoked ones included, newest first.
+	GetAll() ([]model.APIKey, error)
+	Create(key *model.APIKey, keyHash string) error
+	// GetActive returns the unrevoked key with the given hash, or nil.
+	GetActive(keyHash string) (*model.APIKey, error)
+	// Revoke revokes the key. It returns sql.ErrNoRows when there is no
+	// such key.
+	Revoke(id int) error
+	// TouchLastUsed records that the key was used from ip. To spare a write
+	// on every request it is recorded at most once a minute.
+	TouchLastUsed(id int, ip string) error
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+func NewAPIKeyRepository(db *sql.DB) APIKeyRepository {
+	return &apiKeyRepository{db: db}
+}
+
+const apiKeyColumns = "id, name, prefix, permissions, allowed_ips, created_by, created_at, last_used_at, last_used_ip, revoked_at"
+
+func scanAPIKey(row rowScanner) (*model.APIKey, error) {
+	var k model.APIKey
+	err := row.Scan(&k.ID, &k.Name, &k.Prefix, pq.Array(&k.Permissions), pq.Array(&k.AllowedIPs),
+		&k.CreatedBy, &k.CreatedAt, &k.LastUsedAt, &k.LastUsedIP, &k.RevokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if k.Permissions == nil {
+		k.Permissions = []string{}
+	}
+	if k.AllowedIPs == nil {
+		k.AllowedIPs = []string{}
+	}
+	return &k, nil
+}
+
+func (r *apiKeyRepository) GetAll() ([]model.APIKey, error) {
+	rows, err := r.db.Query("SELECT " + apiKeyColumns + " FROM api_keys ORDER BY id DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []model.APIKey
+	for rows.Next() {
+		k, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, *k)
+	}
+	return keys, rows.Err()
+}
+
+func (r *apiKeyRepository) Create(key *model.APIKey, keyHash string) error {
+	created, err := scanAPIKey(r.db.QueryRow(
+		`INSERT INTO api_keys (name, prefix, key_hash, permissions, allowed_ips, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING `+apiKeyColumns,
+		key.Name, key.Prefix, keyHash, pq.Array(key.Permissions), pq.Array(key.AllowedIPs), key.CreatedBy,
+	))
+	if err != nil {
+		return err
+	}
+	*key = *created
+	return nil
+}
+
+func (r *apiKeyRepository) GetActive(keyHash string) (*model.APIKey, error) {
+	key, err := scanAPIKey(r.db.QueryRow(
+		"SELECT "+apiKeyColumns+" FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL", keyHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return key, err
+}
+
+func (r *apiKeyRepository) Revoke(id int) error {
+	return execAffectingOne(r.db, "UPDATE api_keys SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1", id)
+}
+
+func (r *apiKeyRepository) TouchLastUsed(id int, ip string) error {
+	_, err := r.db.Exec(`
+		UPDATE api_keys SET last_used_at = NOW(), last_used_ip = $2
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < NOW() - INTERVAL '1 minute' OR last_used_ip <> $2)`,
+		id, ip)
+	return err
+}
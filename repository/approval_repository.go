@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/model"
+	"time"
+)
+
+// ApprovalRepository stores approval challenges and the tokens issued for
+// them. Rows are never deleted, so they double as the approval trail.
+type ApprovalRepository interface {
+	Create(challenge *model.ApprovalChallenge, sessionID int) error
+	// GetOpen returns the challenge if it belongs to the session and can
+	// still be approved: not yet approved, not expired and with fewer than
+	// maxAttempts failed PIN entries. Otherwise it returns nil.
+	GetOpen(id string, sessionID, maxAttempts int) (*model.ApprovalChallenge, error)
+	RecordFailure(id string) error
+	// Approve records the approver and the hash of the token issued to
+	// them. It returns sql.ErrNoRows when the challenge is no longer open.
+	Approve(id string, approvedBy int, tokenHash string, expiresAt time.Time, maxAttempts int) error
+	// Consume marks the approval holding tokenHash as used and returns the
+	// approver. The token must match the action, resource, user and session
+	// it was issued for; otherwise sql.ErrNoRows is returned.
+	Consume(tokenHash, action string, resourceID, requestedBy, sessionID int) (int, error)
+}
+
+type approvalRepository struct {
+	db *sql.DB
+}
+
+func NewApprovalRepository(db *sql.DB) ApprovalRepository {
+	return &approvalRepository{db: db}
+}
+
+const openApproval = "approved_by IS NULL AND expires_at > NOW() AND failed_attempts < $3"
+
+func (r *approvalRepository) Create(challenge *model.ApprovalChallenge, sessionID int) error {
+	_, err := r.db.Exec(
+		`INSERT INTO approvals (id, action, resource_id, requested_by, session_id, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`,
+		challenge.ID, challenge.Action, challenge.ResourceID, challenge.RequestedBy, sessionID, challenge.ExpiresAt,
+	)
+	return err
+}
+
+func (r *approvalRepository) GetOpen(id string, sessionID, maxAttempts int) (*model.ApprovalChallenge, error) {
+	var c model.ApprovalChallenge
+	err := r.db.QueryRow(`
+		SELECT id, action, resource_id, requested_by, expires_at FROM approvals
+		WHERE id = $1 AND session_id = $2 AND `+openApproval,
+		id, sessionID, maxAttempts,
+	).Scan(&c.ID, &c.Action, &c.ResourceID, &c.RequestedBy, &c.ExpiresAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *approvalRepository) RecordFailure(id string) error {
+	return execAffectingOne(r.db, "UPDATE approvals SET failed_attempts = failed_attempts + 1 WHERE id = $1", id)
+}
+
+func (r *approvalRepository) Approve(id string, approvedBy int, tokenHash string, expiresAt time.Time, maxAttempts int) error {
+	return execAffectingOne(r.db, `
+		UPDATE approvals SET approved_by = $2, approved_at = NOW(), token_hash = $4, expires_at = $5
+		WHERE id = $1 AND `+openApproval,
+		id, approvedBy, maxAttempts, tokenHash, expiresAt,
+	)
+}
+
+func (r *approvalRepository) Consume(tokenHash, action string, resourceID, requestedBy, sessionID int) (int, error) {
+	var approvedBy int
+	err := r.db.QueryRow(`
+		UPDATE approvals SET used_at = NOW()
+		WHERE token_hash = $1 AND action = $2 AND resource_id = $3 AND requested_by = $4 AND session_id = $5
+			AND used_at IS NULL AND expires_at > NOW()
+		RETURNING approved_by`,
+		tokenHash, action, resourceID, requestedBy, sessionID,
+	).Scan(&approvedBy)
+	return approvedBy, err
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"kasir-api/model"
+	"strings"
+)
+
+// Audit builds the audit log entry for a write. Repositories call it inside
+// the write's database transaction with the entity as it was before, read
+// under the write's row lock, and as it is after, nil where it does not
+// exist, and insert the entry before committing. An error rolls the write
+// back, so no write is left without its entry.
+type Audit func(entityID int, before, after any) (*model.AuditEntry, error)
+
+// AuditRepository reads the audit log. Entries are only added by the
+// writes they record, through an Audit, and never changed; the table
+// rejects updates and deletes.
+type AuditRepository interface {
+	// GetAll lists entries, newest first.
+	GetAll(filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type auditRepository struct {
+	db *sql.DB
+}
+
+func NewAuditRepository(db *sql.DB) AuditRepository {
+	return &auditRepository{db: db}
+}
+
+const auditColumns = "id, actor_id, actor_name, action, entity_type, entity_id, changes, request_id, ip, created_at"
+
+func scanAuditEntry(row rowScanner) (*model.AuditEntry, error) {
+	var e model.AuditEntry
+	var changes []byte
+	err := row.Scan(&e.ID, &e.ActorID, &e.ActorName, &e.Action, &e.EntityType, &e.EntityID, &changes, &e.RequestID, &e.IP, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(changes, &e.Changes); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// writeAudit records a write with audit. It must run in the transaction
+// that made the write.
+func writeAudit(tx *sql.Tx, audit Audit, entityID int, before, after any) error {
+	entry, err := audit(entityID, before, after)
+	if err != nil {
+		return err
+	}
+	changes, err := json.Marshal(entry.Changes)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(
+		`INSERT INTO audit_log (actor_id, actor_name, action, entity_type, entity_id, changes, request_id, ip)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		entry.ActorID, entry.ActorName, entry.Action, entry.EntityType, entry.EntityID, changes, entry.RequestID, entry.IP,
+	)
+	return err
+}
+
+func (r *auditRepository) GetAll(filter model.AuditFilter) ([]model.AuditEntry, error) {
+	var conditions []string
+	var args []any
+	if filter.EntityType != "" {
+		args = append(args, filter.EntityType)
+		conditions = append(conditions, fmt.Sprintf("entity_type = $%d", len(args)))
+	}
+	if filter.EntityID != 0 {
+		args = append(args, filter.EntityID)
+		conditions = append(conditions, fmt.Sprintf("entity_id = $%d", len(args)))
+	}
+	if filter.ActorID != 0 {
+		args = append(args, filter.ActorID)
+		conditions = append(conditions, fmt.Sprintf("actor_id = $%d", len(args)))
+	}
+	if filter.From != nil {
+		args = append(args, *filter.From)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.To != nil {
+		args = append(args, *filter.To)
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := "SELECT " + auditColumns + " FROM audit_log"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []model.AuditEntry
+	for rows.Next() {
+		e, err := scanAuditEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *e)
+	}
+	return entries, rows.Err()
+}
@@ -0,0 +1,369 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/model"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrCartNotFound = errors.New("cart not found")
+var ErrCartNotOpen = errors.New("cart is not open")
+var ErrCartClosed = errors.New("cart has already been checked out or cancelled")
+var ErrCartEmpty = errors.New("cart is empty")
+
+type CartRepository interface {
+	// Create starts a cart at the outlet of its terminal.
+	Create(cart *model.Cart) error
+	GetByID(id int) (*model.Cart, error)
+	GetAll(filter model.CartFilter) ([]model.Cart, error)
+	AddItem(cartID, productID, quantity int) error
+	SetItem(cartID, productID, quantity int) error
+	RemoveItem(cartID, productID int) error
+	Park(id int, label string, reserveMinutes int) error
+	Resume(id int) error
+	Cancel(id int) error
+}
+
+type cartRepository struct {
+	db *sql.DB
+}
+
+func NewCartRepository(db *sql.DB) CartRepository {
+	return &cartRepository{db: db}
+}
+
+const cartColumns = "id, terminal_id, outlet_id, label, status, reserved_until, transaction_id, created_at, updated_at"
+
+func scanCart(row rowScanner) (*model.Cart, error) {
+	var c model.Cart
+	err := row.Scan(&c.ID, &c.TerminalID, &c.OutletID, &c.Label, &c.Status, &c.ReservedUntil, &c.TransactionID, &c.CreatedAt, &c.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	c.Items = []model.CartItem{}
+	return &c, nil
+}
+
+func (r *cartRepository) Create(cart *model.Cart) error {
+	outletID, err := terminalOutlet(r.db, cart.TerminalID)
+	if err != nil {
+		return err
+	}
+	c, err := scanCart(r.db.QueryRow(
+		"INSERT INTO carts (terminal_id, outlet_id, label) VALUES ($1, $2, $3) RETURNING "+cartColumns,
+		cart.TerminalID, outletID, cart.Label,
+	))
+	if err != nil {
+		return err
+	}
+	*cart = *c
+	return nil
+}
+
+func (r *cartRepository) GetByID(id int) (*model.Cart, error) {
+	cart, err := scanCart(r.db.QueryRow("SELECT "+cartColumns+" FROM carts WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	carts := []model.Cart{*cart}
+	if err := r.loadItems(carts); err != nil {
+		return nil, err
+	}
+	return &carts[0], nil
+}
+
+func (r *cartRepository) GetAll(filter model.CartFilter) ([]model.Cart, error) {
+	var conditions []string
+	var args []any
+	if filter.Status != "" {
+		args = append(args, filter.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+	if filter.TerminalID != "" {
+		args = append(args, filter.TerminalID)
+		conditions = append(conditions, fmt.Sprintf("terminal_id = $%d", len(args)))
+	}
+
+	query := "SELECT " + cartColumns + " FROM carts"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := r.db.Query(query+" ORDER BY updated_at DESC", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var carts []model.Cart
+	for rows.Next() {
+		c, err := scanCart(rows)
+		if err != nil {
+			return nil, err
+		}
+		carts = append(carts, *c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := r.loadItems(carts); err != nil {
+		return nil, err
+	}
+	return carts, nil
+}
+
+// loadItems fills in the items and totals of carts at the current prices
+// of their outlets.
+func (r *cartRepository) loadItems(carts []model.Cart) error {
+	if len(carts) == 0 {
+		return nil
+	}
+
+	index := map[int]int{}
+	ids := make([]int64, len(carts))
+	for i, c := range carts {
+		index[c.ID] = i
+		ids[i] = int64(c.ID)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT ci.cart_id, ci.product_id, p.name, p.is_weighted, COALESCE(op.price, p.price), ci.quantity
+		FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		JOIN products p ON ci.product_id = p.id
+		LEFT JOIN outlet_prices op ON op.product_id = p.id AND op.outlet_id = c.outlet_id
+		WHERE ci.cart_id = ANY($1)
+		ORDER BY ci.cart_id, ci.added_at`, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cartID int
+		var item model.CartItem
+		if err := rows.Scan(&cartID, &item.ProductID, &item.ProductName, &item.IsWeighted, &item.Price, &item.Quantity); err != nil {
+			return err
+		}
+		product := model.Product{Price: item.Price, IsWeighted: item.IsWeighted}
+		item.Subtotal = product.LineSubtotal(item.Quantity)
+
+		cart := &carts[index[cartID]]
+		cart.Items = append(cart.Items, item)
+		cart.TotalAmount += item.Subtotal
+	}
+	return rows.Err()
+}
+
+// AddItem adds quantity to the cart line of a product, creating the line
+// if needed.
+func (r *cartRepository) AddItem(cartID, productID, quantity int) error {
+	return r.editItems(cartID, func(tx *sql.Tx) error {
+		if err := checkProductExists(tx, productID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO cart_items (cart_id, product_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = cart_items.quantity + EXCLUDED.quantity`,
+			cartID, productID, quantity)
+		return err
+	})
+}
+
+// SetItem sets the quantity of a product in the cart, removing the line
+// when quantity is zero.
+func (r *cartRepository) SetItem(cartID, productID, quantity int) error {
+	if quantity == 0 {
+		return r.RemoveItem(cartID, productID)
+	}
+	return r.editItems(cartID, func(tx *sql.Tx) error {
+		if err := checkProductExists(tx, productID); err != nil {
+			return err
+		}
+		_, err := tx.Exec(`
+			INSERT INTO cart_items (cart_id, product_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (cart_id, product_id) DO UPDATE SET quantity = EXCLUDED.quantity`,
+			cartID, productID, quantity)
+		return err
+	})
+}
+
+func (r *cartRepository) RemoveItem(cartID, productID int) error {
+	return r.editItems(cartID, func(tx *sql.Tx) error {
+		return execAffectingOne(tx, "DELETE FROM cart_items WHERE cart_id = $1 AND product_id = $2", cartID, productID)
+	})
+}
+
+// editItems runs edit in a transaction after locking the cart and checking
+// that it is open.
+func (r *cartRepository) editItems(cartID int, edit func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCart(tx, cartID)
+	if err != nil {
+		return err
+	}
+	if status != model.CartOpen {
+		return ErrCartNotOpen
+	}
+
+	if err := edit(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("UPDATE carts SET updated_at = NOW() WHERE id = $1", cartID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Park holds an open cart under label. With reserveMinutes > 0 the cart's
+// stock is reserved for that long, provided enough unreserved stock is left.
+func (r *cartRepository) Park(id int, label string, reserveMinutes int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCart(tx, id)
+	if err != nil {
+		return err
+	}
+	if status != model.CartOpen {
+		return ErrCartNotOpen
+	}
+
+	if reserveMinutes > 0 {
+		items, err := lockCartItems(tx, id)
+		if err != nil {
+			return err
+		}
+		var outletID int
+		if err := tx.QueryRow("SELECT outlet_id FROM carts WHERE id = $1", id).Scan(&outletID); err != nil {
+			return err
+		}
+		lines, err := priceLines(tx, items, outletID, id, true)
+		if err != nil {
+			return err
+		}
+		if err := checkoutError(lines); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE carts SET status = 'parked', label = COALESCE(NULLIF($1, ''), label),
+			reserved_until = CASE WHEN $2 > 0 THEN NOW() + $2 * INTERVAL '1 minute' END,
+			updated_at = NOW()
+		WHERE id = $3`, label, reserveMinutes, id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Resume reopens a parked cart for editing and releases its reservation.
+func (r *cartRepository) Resume(id int) error {
+	return r.transition(id, []string{model.CartParked}, "status = 'open', reserved_until = NULL")
+}
+
+func (r *cartRepository) Cancel(id int) error {
+	return r.transition(id, []string{model.CartOpen, model.CartParked}, "status = 'cancelled', reserved_until = NULL")
+}
+
+func (r *cartRepository) transition(id int, from []string, set string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	status, err := lockCart(tx, id)
+	if err != nil {
+		return err
+	}
+	allowed := false
+	for _, s := range from {
+		allowed = allowed || s == status
+	}
+	if !allowed {
+		if status == model.CartConverted || status == model.CartCancelled {
+			return ErrCartClosed
+		}
+		return ErrCartNotOpen
+	}
+
+	if _, err := tx.Exec("UPDATE carts SET "+set+", updated_at = NOW() WHERE id = $1", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockCart locks the cart row for the rest of tx and returns its status.
+func lockCart(tx *sql.Tx, id int) (string, error) {
+	var status string
+	err := tx.QueryRow("SELECT status FROM carts WHERE id = $1 FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return "", ErrCartNotFound
+	}
+	return status, err
+}
+
+// lockCartItems locks a cart that can still be checked out and returns its
+// lines as checkout items.
+func lockCartItems(tx *sql.Tx, cartID int) ([]model.CheckoutItem, error) {
+	status, err := lockCart(tx, cartID)
+	if err != nil {
+		return nil, err
+	}
+	if status != model.CartOpen && status != model.CartParked {
+		return nil, ErrCartClosed
+	}
+
+	rows, err := tx.Query("SELECT product_id, quantity FROM cart_items WHERE cart_id = $1 ORDER BY added_at", cartID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []model.CheckoutItem
+	for rows.Next() {
+		var item model.CheckoutItem
+		if err := rows.Scan(&item.ProductID, &item.Quantity); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(items) == 0 {
+		return nil, ErrCartEmpty
+	}
+	return items, nil
+}
+
+func checkProductExists(tx *sql.Tx, productID int) error {
+	var exists bool
+	err := tx.QueryRow("SELECT EXISTS (SELECT 1 FROM products WHERE id = $1 AND deleted_at IS NULL)", productID).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrProductNotFound
+	}
+	return nil
+}
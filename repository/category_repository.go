@@ -6,11 +6,22 @@ import (
 )
 
 type CategoryRepository interface {
-	GetAll() ([]model.Category, error)
+	GetAll(includeArchived bool) ([]model.Category, error)
 	GetByID(id int) (*model.Category, error)
-	Create(category *model.Category) error
-	Update(id int, category *model.Category) error
-	Delete(id int) error
+	GetDescendantIDs(id int) ([]int, error)
+	// Create, Update, Patch, Archive, Restore and Delete record the write
+	// with audit in the same transaction.
+	Create(category *model.Category, audit Audit) error
+	// Update, Patch, Archive, Restore and Delete fail with
+	// ErrVersionConflict unless version is 0 or the category's current
+	// version. Writes bump the version.
+	Update(id, version int, category *model.Category, audit Audit) error
+	// Patch sets only the columns in changes, keyed by column name, and
+	// returns the new version.
+	Patch(id, version int, changes map[string]any, audit Audit) (int, error)
+	Archive(id, version int, audit Audit) error
+	Restore(id, version int, audit Audit) error
+	Delete(id, version int, audit Audit) error
 }
 
 type categoryRepository struct {
@@ -21,8 +32,31 @@ func NewCategoryRepository(db *sql.DB) CategoryRepository {
 	return &categoryRepository{db: db}
 }
 
-func (r *categoryRepository) GetAll() ([]model.Category, error) {
-	rows, err := r.db.Query("SELECT id, name, description FROM categories ORDER BY id")
+const categoryColumns = "id, name, description, parent_id, deleted_at, version"
+
+func scanCategory(row rowScanner) (*model.Category, error) {
+	var c model.Category
+	if err := row.Scan(&c.ID, &c.Name, &c.Description, &c.ParentID, &c.DeletedAt, &c.Version); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// getCategory returns the category, or nil when it does not exist.
+func getCategory(q queryRower, id int) (*model.Category, error) {
+	c, err := scanCategory(q.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+func (r *categoryRepository) GetAll(includeArchived bool) ([]model.Category, error) {
+	query := "SELECT " + categoryColumns + " FROM categories"
+	if !includeArchived {
+		query += " WHERE deleted_at IS NULL"
+	}
+	rows, err := r.db.Query(query + " ORDER BY id")
 	if err != nil {
 		return nil, err
 	}
@@ -30,68 +64,165 @@ func (r *categoryRepository) GetAll() ([]model.Category, error) {
 
 	var categories []model.Category
 	for rows.Next() {
-		var c model.Category
-		if err := rows.Scan(&c.ID, &c.Name, &c.Description); err != nil {
+		c, err := scanCategory(rows)
+		if err != nil {
 			return nil, err
 		}
-		categories = append(categories, c)
+		categories = append(categories, *c)
 	}
 	return categories, nil
 }
 
 func (r *categoryRepository) GetByID(id int) (*model.Category, error) {
-	var c model.Category
-	err := r.db.QueryRow("SELECT id, name, description FROM categories WHERE id = $1", id).
-		Scan(&c.ID, &c.Name, &c.Description)
+	return getCategory(r.db, id)
+}
+
+// GetDescendantIDs returns the IDs of every category below id in the tree,
+// not including id itself.
+func (r *categoryRepository) GetDescendantIDs(id int) ([]int, error) {
+	rows, err := r.db.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE parent_id = $1
+			UNION
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+		)
+		SELECT id FROM subtree ORDER BY id`, id)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
 		return nil, err
 	}
-	return &c, nil
-}
+	defer rows.Close()
 
-func (r *categoryRepository) Create(category *model.Category) error {
-	return r.db.QueryRow(
-		"INSERT INTO categories (name, description) VALUES ($1, $2) RETURNING id",
-		category.Name, category.Description,
-	).Scan(&category.ID)
+	var ids []int
+	for rows.Next() {
+		var descendantID int
+		if err := rows.Scan(&descendantID); err != nil {
+			return nil, err
+		}
+		ids = append(ids, descendantID)
+	}
+	return ids, rows.Err()
 }
 
-func (r *categoryRepository) Update(id int, category *model.Category) error {
-	result, err := r.db.Exec(
-		"UPDATE categories SET name = $1, description = $2 WHERE id = $3",
-		category.Name, category.Description, id,
-	)
+func (r *categoryRepository) Create(category *model.Category, audit Audit) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	err = tx.QueryRow(
+		"INSERT INTO categories (name, description, parent_id) VALUES ($1, $2, $3) RETURNING id, version",
+		category.Name, category.Description, category.ParentID,
+	).Scan(&category.ID, &category.Version)
+	if err != nil {
+		return err
+	}
+	created, err := getCategory(tx, category.ID)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if err := writeAudit(tx, audit, category.ID, nil, created); err != nil {
+		return err
 	}
+	return tx.Commit()
+}
 
-	category.ID = id
-	return nil
+func (r *categoryRepository) Update(id, version int, category *model.Category, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx) error {
+		newVersion, err := updateVersioned(tx, "categories", "name = $1, description = $2, parent_id = $3",
+			[]any{category.Name, category.Description, category.ParentID}, id, version)
+		if err != nil {
+			return err
+		}
+
+		category.ID = id
+		category.Version = newVersion
+		return nil
+	})
+}
+
+var categoryPatchColumns = map[string]bool{"name": true, "description": true, "parent_id": true}
+
+func (r *categoryRepository) Patch(id, version int, changes map[string]any, audit Audit) (int, error) {
+	var newVersion int
+	err := r.audited(id, audit, func(tx *sql.Tx) error {
+		var err error
+		newVersion, err = updateColumns(tx, "categories", categoryPatchColumns, id, version, changes)
+		return err
+	})
+	return newVersion, err
+}
+
+// Archive soft-deletes the category. Its products and subcategories keep
+// pointing at it so that a restore brings the whole branch back.
+func (r *categoryRepository) Archive(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx) error {
+		_, err := updateVersioned(tx, "categories", "deleted_at = COALESCE(deleted_at, NOW())", nil, id, version)
+		return err
+	})
+}
+
+func (r *categoryRepository) Restore(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx) error {
+		_, err := updateVersioned(tx, "categories", "deleted_at = NULL", nil, id, version)
+		return err
+	})
+}
+
+// Delete permanently removes the category. It returns a *ReferencedError
+// when products, parent products or subcategories still belong to it.
+func (r *categoryRepository) Delete(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx) error {
+		if err := lockVersioned(tx, "categories", id, version); err != nil {
+			return err
+		}
+
+		dependents := map[string]int{}
+		counts := map[string]string{
+			"products":        "SELECT COUNT(*) FROM products WHERE category_id = $1",
+			"parent_products": "SELECT COUNT(*) FROM parent_products WHERE category_id = $1",
+			"categories":      "SELECT COUNT(*) FROM categories WHERE parent_id = $1",
+		}
+		for table, query := range counts {
+			var n int
+			if err := tx.QueryRow(query, id).Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				dependents[table] = n
+			}
+		}
+		if len(dependents) > 0 {
+			return &ReferencedError{Dependents: dependents}
+		}
+
+		return execAffectingOne(tx, "DELETE FROM categories WHERE id = $1", id)
+	})
 }
 
-func (r *categoryRepository) Delete(id int) error {
-	result, err := r.db.Exec("DELETE FROM categories WHERE id = $1", id)
+// audited runs write, a change to category id, in a transaction that first
+// locks the category, and records it with audit. It returns sql.ErrNoRows
+// when the category does not exist.
+func (r *categoryRepository) audited(id int, audit Audit, write func(tx *sql.Tx) error) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	before, err := scanCategory(tx.QueryRow("SELECT "+categoryColumns+" FROM categories WHERE id = $1 FOR UPDATE", id))
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if err := write(tx); err != nil {
+		return err
+	}
+	after, err := getCategory(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, audit, id, before, after); err != nil {
+		return err
 	}
-	return nil
+	return tx.Commit()
 }
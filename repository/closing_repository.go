@@ -0,0 +1,249 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"kasir-api/model"
+	"time"
+)
+
+// ClosingRepository computes X-reports and stores Z-reports. Z-reports are
+// never changed once stored; the table rejects updates and deletes.
+type ClosingRepository interface {
+	// GetXReport totals the sales since the last Z-report, for one terminal
+	// or, when terminalID is "", for all of them, at one outlet or, when
+	// outletID is 0, at all of them.
+	GetXReport(terminalID string, outletID int) (*model.ClosingReport, error)
+	// CreateZReport closes the business day, numbering the report after the
+	// last one. complete is called with the totals before the report is
+	// stored, to fill in figures not computed by the database.
+	CreateZReport(generatedBy int, complete func(*model.ClosingReport)) (*model.ClosingReport, error)
+	// GetZReports lists the Z-reports, newest first.
+	GetZReports() ([]model.ClosingReport, error)
+	GetZReport(number int) (*model.ClosingReport, error)
+}
+
+// closingClock is the time closing reports end at and sales and voids are
+// stamped with. It is read after the statement has locked transactions:
+// CreateZReport's lock waits for sales and voids already written, and those
+// written later wait for the report, so each falls on the side of the
+// report's end on which it was committed. CURRENT_TIMESTAMP, the start of
+// the transaction, would date a sale committed after the report before it.
+const closingClock = "clock_timestamp()::timestamp"
+
+type closingRepository struct {
+	db *sql.DB
+}
+
+func NewClosingRepository(db *sql.DB) ClosingRepository {
+	return &closingRepository{db: db}
+}
+
+func (r *closingRepository) GetXReport(terminalID string, outletID int) (*model.ClosingReport, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var start *time.Time
+	var end time.Time
+	err = tx.QueryRow("SELECT MAX(period_end), "+closingClock+" FROM z_reports").Scan(&start, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := closingTotals(tx, start, end, terminalID, outletID)
+	if err != nil {
+		return nil, err
+	}
+	report.Type = model.ClosingReportX
+	report.TerminalID = terminalID
+	report.OutletID = outletID
+	return report, nil
+}
+
+func (r *closingRepository) CreateZReport(generatedBy int, complete func(*model.ClosingReport)) (*model.ClosingReport, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Reports are numbered one at a time, and sales and voids wait until
+	// the day is closed so none falls between two reports.
+	if _, err := tx.Exec("LOCK TABLE z_reports IN EXCLUSIVE MODE"); err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec("LOCK TABLE transactions IN SHARE MODE"); err != nil {
+		return nil, err
+	}
+
+	var last int
+	var start *time.Time
+	var end time.Time
+	err = tx.QueryRow("SELECT COALESCE(MAX(number), 0), MAX(period_end), "+closingClock+" FROM z_reports").
+		Scan(&last, &start, &end)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := closingTotals(tx, start, end, "", 0)
+	if err != nil {
+		return nil, err
+	}
+	report.Type = model.ClosingReportZ
+	report.Number = last + 1
+	report.GeneratedBy = generatedBy
+	complete(report)
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	_, err = tx.Exec(
+		"INSERT INTO z_reports (number, period_start, period_end, generated_by, report) VALUES ($1, $2, $3, $4, $5)",
+		report.Number, report.PeriodStart, report.PeriodEnd, generatedBy, data,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+func (r *closingRepository) GetZReports() ([]model.ClosingReport, error) {
+	rows, err := r.db.Query("SELECT report FROM z_reports ORDER BY number DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []model.ClosingReport
+	for rows.Next() {
+		report, err := scanZReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, rows.Err()
+}
+
+func (r *closingRepository) GetZReport(number int) (*model.ClosingReport, error) {
+	report, err := scanZReport(r.db.QueryRow("SELECT report FROM z_reports WHERE number = $1", number))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return report, err
+}
+
+func scanZReport(row rowScanner) (*model.ClosingReport, error) {
+	var data []byte
+	if err := row.Scan(&data); err != nil {
+		return nil, err
+	}
+	var report model.ClosingReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// closingTotals totals the sales made and the sales voided after start (or
+// ever, when start is nil) up to end, on one terminal or all of them and at
+// one outlet or all of them.
+func closingTotals(tx *sql.Tx, start *time.Time, end time.Time, terminalID string, outletID int) (*model.ClosingReport, error) {
+	report := &model.ClosingReport{
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: end,
+		Payments:    []model.PaymentTotal{},
+		Terminals:   []model.TerminalTotal{},
+		Outlets:     []model.OutletTotal{},
+	}
+
+	const sales = `FROM transactions
+		WHERE ($1::timestamp IS NULL OR created_at > $1) AND created_at <= $2 AND ($3 = '' OR terminal_id = $3)
+		AND ($4 = 0 OR outlet_id = $4)`
+
+	err := tx.QueryRow(
+		"SELECT COALESCE(SUM(total_amount), 0), COUNT(*), MIN(id), MAX(id) "+sales,
+		start, end, terminalID, outletID,
+	).Scan(&report.GrossSales, &report.Transactions, &report.FirstTransactionID, &report.LastTransactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(total_amount), 0), COUNT(*) FROM transactions
+		WHERE ($1::timestamp IS NULL OR voided_at > $1) AND voided_at <= $2 AND ($3 = '' OR terminal_id = $3)
+		AND ($4 = 0 OR outlet_id = $4)`,
+		start, end, terminalID, outletID,
+	).Scan(&report.Voids, &report.VoidCount)
+	if err != nil {
+		return nil, err
+	}
+	report.NetSales = report.GrossSales - report.Voids
+
+	rows, err := tx.Query(
+		"SELECT payment_method, COUNT(*), SUM(total_amount) "+sales+" AND voided_at IS NULL GROUP BY payment_method ORDER BY payment_method",
+		start, end, terminalID, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var p model.PaymentTotal
+		if err := rows.Scan(&p.Method, &p.Transactions, &p.Amount); err != nil {
+			return nil, err
+		}
+		report.Payments = append(report.Payments, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	terminalRows, err := tx.Query(
+		"SELECT terminal_id, COUNT(*), SUM(total_amount) "+sales+" AND voided_at IS NULL GROUP BY terminal_id ORDER BY terminal_id",
+		start, end, terminalID, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer terminalRows.Close()
+	for terminalRows.Next() {
+		var t model.TerminalTotal
+		if err := terminalRows.Scan(&t.TerminalID, &t.Transactions, &t.NetSales); err != nil {
+			return nil, err
+		}
+		report.Terminals = append(report.Terminals, t)
+	}
+	if err := terminalRows.Err(); err != nil {
+		return nil, err
+	}
+
+	outletRows, err := tx.Query(`
+		SELECT s.outlet_id, o.name, s.transactions, s.net_sales
+		FROM (SELECT outlet_id, COUNT(*) AS transactions, SUM(total_amount) AS net_sales `+sales+`
+			AND voided_at IS NULL GROUP BY outlet_id) s
+		JOIN outlets o ON o.id = s.outlet_id
+		ORDER BY s.outlet_id`,
+		start, end, terminalID, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer outletRows.Close()
+	for outletRows.Next() {
+		var o model.OutletTotal
+		if err := outletRows.Scan(&o.OutletID, &o.Name, &o.Transactions, &o.NetSales); err != nil {
+			return nil, err
+		}
+		report.Outlets = append(report.Outlets, o)
+	}
+	return report, outletRows.Err()
+}
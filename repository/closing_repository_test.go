@@ -0,0 +1,121 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"kasir-api/model"
+
+	_ "github.com/lib/pq"
+)
+
+// openTestDB connects to the database in TEST_DB_CONN and applies
+// schema.sql to it, or skips the test when the variable is not set. The
+// test's rows are left behind, so point it at a throwaway database.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	conn := os.Getenv("TEST_DB_CONN")
+	if conn == "" {
+		t.Skip("TEST_DB_CONN is not set")
+	}
+
+	db, err := sql.Open("postgres", conn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	schema, err := os.ReadFile("../schema.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(string(schema)); err != nil {
+		t.Fatalf("applying schema.sql: %v", err)
+	}
+	return db
+}
+
+// TestZReportPeriodBoundary covers a sale and a void whose database
+// transactions began before a Z-report but were committed after it. The
+// report cannot see them, so the next one must.
+func TestZReportPeriodBoundary(t *testing.T) {
+	db := openTestDB(t)
+	repo := NewClosingRepository(db)
+	noFigures := func(*model.ClosingReport) {}
+
+	var userID, outletID int
+	err := db.QueryRow("INSERT INTO users (username, name, password_hash) VALUES ($1, 'Z-report test', '') RETURNING id",
+		fmt.Sprintf("zreport-%d", time.Now().UnixNano())).Scan(&userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow("SELECT id FROM outlets WHERE is_default").Scan(&outletID); err != nil {
+		t.Fatal(err)
+	}
+	sell := func(q queryRower) int {
+		t.Helper()
+		var id int
+		err := q.QueryRow(insertTransaction, 5000, model.PaymentCash, 5000, 0, 0, "", outletID, nil, nil).Scan(&id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	voided := sell(db)
+	if _, err := repo.CreateZReport(userID, noFigures); err != nil {
+		t.Fatal(err)
+	}
+
+	sale, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sale.Rollback()
+	void, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer void.Rollback()
+	if _, err := sale.Exec("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	// Void locks the sale before writing to it.
+	if _, err := void.Exec("SELECT 1 FROM transactions WHERE id = $1 FOR UPDATE", voided); err != nil {
+		t.Fatal(err)
+	}
+
+	closed, err := repo.CreateZReport(userID, noFigures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sold := sell(sale)
+	if _, err := void.Exec(voidTransaction, voided, 0, nil, "boundary test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sale.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := void.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	next, err := repo.CreateZReport(userID, noFigures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if closed.Transactions != 0 || closed.VoidCount != 0 {
+		t.Errorf("report %d counted %d sales and %d voids, want none", closed.Number, closed.Transactions, closed.VoidCount)
+	}
+	if next.Transactions != 1 || next.FirstTransactionID == nil || *next.FirstTransactionID != sold {
+		t.Errorf("report %d counted %d sales starting at %v, want sale %d", next.Number, next.Transactions, next.FirstTransactionID, sold)
+	}
+	if next.VoidCount != 1 || next.Voids != 5000 {
+		t.Errorf("report %d counted %d voids of %d, want the void of sale %d", next.Number, next.VoidCount, next.Voids, voided)
+	}
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"kasir-api/model"
+)
+
+var ErrReferenced = errors.New("record is still referenced")
+
+// ErrVersionConflict is returned by versioned writes when the row was
+// changed since the caller read it.
+var ErrVersionConflict = errors.New("record has been modified since it was read")
+
+// ReferencedError is returned when a hard delete is refused because other
+// rows still point at the record. Dependents maps the referencing table to
+// the number of rows found there.
+type ReferencedError struct {
+	Dependents map[string]int
+}
+
+func (e *ReferencedError) Error() string {
+	tables := make([]string, 0, len(e.Dependents))
+	for table := range e.Dependents {
+		tables = append(tables, table)
+	}
+	sort.Strings(tables)
+
+	parts := make([]string, 0, len(tables))
+	for _, table := range tables {
+		parts = append(parts, fmt.Sprintf("%d %s", e.Dependents[table], table))
+	}
+	return "still referenced by " + strings.Join(parts, ", ")
+}
+
+func (e *ReferencedError) Is(target error) bool {
+	return target == ErrReferenced
+}
+
+// Total returns the number of dependent rows across all tables.
+func (e *ReferencedError) Total() int {
+	total := 0
+	for _, n := range e.Dependents {
+		total += n
+	}
+	return total
+}
+
+// StockError is an ErrInsufficientStock for a single product.
+type StockError struct {
+	ProductID int
+	Requested int
+	Available int
+}
+
+func (e *StockError) Error() string {
+	return fmt.Sprintf("insufficient stock for product %d: requested %d, available %d", e.ProductID, e.Requested, e.Available)
+}
+
+func (e *StockError) Is(target error) bool {
+	return target == ErrInsufficientStock
+}
+
+// ProductNotFoundError is an ErrProductNotFound for a single product.
+type ProductNotFoundError struct {
+	ProductID int
+}
+
+func (e *ProductNotFoundError) Error() string {
+	return fmt.Sprintf("product %d not found", e.ProductID)
+}
+
+func (e *ProductNotFoundError) Is(target error) bool {
+	return target == ErrProductNotFound
+}
+
+// CheckoutError is returned when one or more checkout lines cannot be sold.
+// It lists every offending line and unwraps to a StockError or
+// ProductNotFoundError per line, so errors.Is and errors.As keep working.
+type CheckoutError struct {
+	Problems []model.CheckoutProblem
+}
+
+func (e *CheckoutError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Unwrap()[0].Error()
+	}
+	return fmt.Sprintf("%d checkout lines cannot be sold", len(e.Problems))
+}
+
+func (e *CheckoutError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Problems))
+	for _, p := range e.Problems {
+		switch p.Code {
+		case model.ProblemInsufficientStock:
+			available := 0
+			if p.Available != nil {
+				available = *p.Available
+			}
+			errs = append(errs, &StockError{ProductID: p.ProductID, Requested: p.Requested, Available: available})
+		case model.ProblemProductNotFound:
+			errs = append(errs, &ProductNotFoundError{ProductID: p.ProductID})
+		default:
+			errs = append(errs, errors.New(strings.ToLower(p.Message)))
+		}
+	}
+	return errs
+}
+
+// checkoutError collects the problems of lines into a CheckoutError, or
+// returns nil when every line can be sold.
+func checkoutError(lines []model.CheckoutLine) error {
+	var problems []model.CheckoutProblem
+	for _, line := range lines {
+		if line.Problem != nil {
+			problems = append(problems, *line.Problem)
+		}
+	}
+	if len(problems) == 0 {
+		return nil
+	}
+	return &CheckoutError{Problems: problems}
+}
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+type queryRower interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+type querier interface {
+	queryRower
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+}
+
+// execAffectingOne runs a statement that targets a single row by ID and
+// returns sql.ErrNoRows when no row matched.
+func execAffectingOne(db execer, query string, args ...any) error {
+	result, err := db.Exec(query, args...)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// updateVersioned runs "UPDATE table SET sets" on the row with the given
+// ID and bumps its version, returning the new one. args are the values for
+// the placeholders in sets. A version of 0 skips the optimistic check;
+// otherwise a row at another version yields ErrVersionConflict.
+func updateVersioned(db queryRower, table, sets string, args []any, id, version int) (int, error) {
+	n := len(args)
+	query := fmt.Sprintf("UPDATE %s SET %s, version = version + 1 WHERE id = $%d AND ($%d = 0 OR version = $%d) RETURNING version",
+		table, sets, n+1, n+2, n+2)
+
+	var newVersion int
+	err := db.QueryRow(query, append(args, id, version)...).Scan(&newVersion)
+	if err == sql.ErrNoRows {
+		return 0, versionMiss(db, table, id)
+	}
+	return newVersion, err
+}
+
+// lockVersioned locks the row with the given ID for a versioned delete,
+// checking it exists and, unless version is 0, that it is at version.
+func lockVersioned(db queryRower, table string, id, version int) error {
+	var current int
+	err := db.QueryRow("SELECT version FROM "+table+" WHERE id = $1 FOR UPDATE", id).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if version != 0 && current != version {
+		return ErrVersionConflict
+	}
+	return nil
+}
+
+// versionMiss explains why a versioned statement matched no row.
+func versionMiss(db queryRower, table string, id int) error {
+	var exists bool
+	if err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM "+table+" WHERE id = $1)", id).Scan(&exists); err != nil {
+		return err
+	}
+	if !exists {
+		return sql.ErrNoRows
+	}
+	return ErrVersionConflict
+}
+
+// updateColumns sets only the given columns of the row with the given ID,
+// for partial updates, and returns the new version. Every key of changes
+// must be listed in allowed, which keeps column names out of caller control.
+func updateColumns(db queryRower, table string, allowed map[string]bool, id, version int, changes map[string]any) (int, error) {
+	columns := make([]string, 0, len(changes))
+	for column := range changes {
+		if !allowed[column] {
+			return 0, fmt.Errorf("column %q of %s cannot be updated", column, table)
+		}
+		columns = append(columns, column)
+	}
+	sort.Strings(columns)
+
+	sets := make([]string, len(columns))
+	args := make([]any, 0, len(columns))
+	for i, column := range columns {
+		sets[i] = fmt.Sprintf("%s = $%d", column, i+1)
+		args = append(args, changes[column])
+	}
+	if len(sets) == 0 {
+		sets = append(sets, "id = id")
+	}
+	return updateVersioned(db, table, strings.Join(sets, ", "), args, id, version)
+}
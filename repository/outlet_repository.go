@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"kasir-api/model"
+
+	"github.com/lib/pq"
+)
+
+// OutletRepository stores outlets with their terminals, stock and price
+// overrides.
+type OutletRepository interface {
+	// GetAll lists the outlets with their terminals, the default first.
+	GetAll() ([]model.Outlet, error)
+	GetByID(id int) (*model.Outlet, error)
+	Create(outlet *model.Outlet) error
+	// Update changes the outlet's name and address. It returns
+	// sql.ErrNoRows when there is no such outlet.
+	Update(id int, outlet *model.Outlet) error
+	// AssignTerminal makes the terminal sell from the outlet, moving it
+	// from any other. Carts already started keep their outlet.
+	AssignTerminal(outletID int, terminalID string) error
+	// UnassignTerminal returns the terminal to the default outlet. It
+	// returns sql.ErrNoRows when the terminal is not assigned to the outlet.
+	UnassignTerminal(outletID int, terminalID string) error
+	// GetProducts lists the active products with their stock and price at
+	// the outlet.
+	GetProducts(outletID int) ([]model.OutletProduct, error)
+	// SetStock, SetPrice and DeletePrice record the write with audit, as a
+	// change to the outlet, in the same transaction. SetPrice and
+	// DeletePrice also record the change of the price charged at the
+	// outlet in the product's price history against changedBy.
+	//
+	// SetStock sets the product's stock at the outlet. It returns
+	// sql.ErrNoRows for an unknown outlet and ErrProductNotFound for an
+	// unknown or archived product.
+	SetStock(outletID, productID, stock int, audit Audit) error
+	// SetPrice makes the outlet charge price for the product instead of its
+	// catalog price.
+	SetPrice(outletID, productID, price int, changedBy string, audit Audit) error
+	// DeletePrice returns the outlet to the product's catalog price. It
+	// returns sql.ErrNoRows when the outlet has no price for the product.
+	DeletePrice(outletID, productID int, changedBy string, audit Audit) error
+}
+
+type outletRepository struct {
+	db *sql.DB
+}
+
+func NewOutletRepository(db *sql.DB) OutletRepository {
+	return &outletRepository{db: db}
+}
+
+// defaultOutlet selects the ID of the default outlet.
+const defaultOutlet = "(SELECT id FROM outlets WHERE is_default)"
+
+// productStock selects the stock of product p at the default outlet.
+const productStock = "COALESCE((SELECT stock FROM outlet_stock WHERE product_id = p.id AND outlet_id = " + defaultOutlet + "), 0)"
+
+const outletSelect = `SELECT o.id, o.name, o.address, o.is_default, o.created_at,
+		ARRAY(SELECT t.id FROM terminals t WHERE t.outlet_id = o.id ORDER BY t.id)
+	FROM outlets o`
+
+func scanOutlet(row rowScanner) (*model.Outlet, error) {
+	var o model.Outlet
+	if err := row.Scan(&o.ID, &o.Name, &o.Address, &o.IsDefault, &o.CreatedAt, pq.Array(&o.Terminals)); err != nil {
+		return nil, err
+	}
+	if o.Terminals == nil {
+		o.Terminals = []string{}
+	}
+	return &o, nil
+}
+
+func (r *outletRepository) GetAll() ([]model.Outlet, error) {
+	rows, err := r.db.Query(outletSelect + " ORDER BY o.is_default DESC, o.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var outlets []model.Outlet
+	for rows.Next() {
+		o, err := scanOutlet(rows)
+		if err != nil {
+			return nil, err
+		}
+		outlets = append(outlets, *o)
+	}
+	return outlets, rows.Err()
+}
+
+func (r *outletRepository) GetByID(id int) (*model.Outlet, error) {
+	outlet, err := scanOutlet(r.db.QueryRow(outletSelect+" WHERE o.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return outlet, err
+}
+
+func (r *outletRepository) Create(outlet *model.Outlet) error {
+	var id int
+	err := r.db.QueryRow("INSERT INTO outlets (name, address) VALUES ($1, $2) RETURNING id", outlet.Name, outlet.Address).Scan(&id)
+	if err != nil {
+		return err
+	}
+	created, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	*outlet = *created
+	return nil
+}
+
+func (r *outletRepository) Update(id int, outlet *model.Outlet) error {
+	err := execAffectingOne(r.db, "UPDATE outlets SET name = $1, address = $2 WHERE id = $3", outlet.Name, outlet.Address, id)
+	if err != nil {
+		return err
+	}
+	updated, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	*outlet = *updated
+	return nil
+}
+
+func (r *outletRepository) AssignTerminal(outletID int, terminalID string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkOutletExists(tx, outletID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO terminals (id, outlet_id) VALUES ($1, $2)
+		ON CONFLICT (id) DO UPDATE SET outlet_id = EXCLUDED.outlet_id`,
+		terminalID, outletID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *outletRepository) UnassignTerminal(outletID int, terminalID string) error {
+	return execAffectingOne(r.db, "DELETE FROM terminals WHERE id = $1 AND outlet_id = $2", terminalID, outletID)
+}
+
+func (r *outletRepository) GetProducts(outletID int) ([]model.OutletProduct, error) {
+	if err := checkOutletExists(r.db, outletID); err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(`
+		SELECT p.id, p.name, COALESCE(s.stock, 0), COALESCE(op.price, p.price), op.price
+		FROM products p
+		LEFT JOIN outlet_stock s ON s.product_id = p.id AND s.outlet_id = $1
+		LEFT JOIN outlet_prices op ON op.product_id = p.id AND op.outlet_id = $1
+		WHERE p.deleted_at IS NULL
+		ORDER BY p.name, p.id`, outletID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var products []model.OutletProduct
+	for rows.Next() {
+		var p model.OutletProduct
+		if err := rows.Scan(&p.ProductID, &p.Name, &p.Stock, &p.Price, &p.PriceOverride); err != nil {
+			return nil, err
+		}
+		products = append(products, p)
+	}
+	return products, rows.Err()
+}
+
+func (r *outletRepository) SetStock(outletID, productID, stock int, audit Audit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkOutletExists(tx, outletID); err != nil {
+		return err
+	}
+	// Locking the product waits for checkouts selling it to finish.
+	if err := lockActiveProduct(tx, productID); err != nil {
+		return err
+	}
+	var oldStock *int
+	err = tx.QueryRow("SELECT stock FROM outlet_stock WHERE outlet_id = $1 AND product_id = $2", outletID, productID).Scan(&oldStock)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err := setOutletStock(tx, outletID, productID, stock); err != nil {
+		return err
+	}
+	// The product shows its stock at the default outlet, so a change there
+	// is a change to the product.
+	_, err = tx.Exec("UPDATE products SET version = version + 1 WHERE id = $1 AND $2 = "+defaultOutlet, productID, outletID)
+	if err != nil {
+		return err
+	}
+
+	field := outletProductField(productID, "stock")
+	if err := writeAudit(tx, audit, outletID, map[string]*int{field: oldStock}, map[string]int{field: stock}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *outletRepository) SetPrice(outletID, productID, price int, changedBy string, audit Audit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkOutletExists(tx, outletID); err != nil {
+		return err
+	}
+	if err := checkProductExists(tx, productID); err != nil {
+		return err
+	}
+	catalogPrice, override, err := lockOutletPrice(tx, outletID, productID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO outlet_prices (outlet_id, product_id, price) VALUES ($1, $2, $3)
+		ON CONFLICT (outlet_id, product_id) DO UPDATE SET price = EXCLUDED.price`,
+		outletID, productID, price)
+	if err != nil {
+		return err
+	}
+
+	oldPrice := catalogPrice
+	if override != nil {
+		oldPrice = *override
+	}
+	if err := recordPriceChange(tx, productID, &outletID, oldPrice, price, model.PriceSourceManual, changedBy, nil); err != nil {
+		return err
+	}
+	field := outletProductField(productID, "price")
+	if err := writeAudit(tx, audit, outletID, map[string]*int{field: override}, map[string]int{field: price}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *outletRepository) DeletePrice(outletID, productID int, changedBy string, audit Audit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	catalogPrice, override, err := lockOutletPrice(tx, outletID, productID)
+	if err != nil {
+		return err
+	}
+	if override == nil {
+		return sql.ErrNoRows
+	}
+	if err := execAffectingOne(tx, "DELETE FROM outlet_prices WHERE outlet_id = $1 AND product_id = $2", outletID, productID); err != nil {
+		return err
+	}
+
+	if err := recordPriceChange(tx, productID, &outletID, *override, catalogPrice, model.PriceSourceManual, changedBy, nil); err != nil {
+		return err
+	}
+	field := outletProductField(productID, "price")
+	if err := writeAudit(tx, audit, outletID, map[string]*int{field: override}, map[string]*int{field: nil}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockOutletPrice locks the product and the outlet's price for it, and
+// returns its catalog price and the outlet's override, nil when there is
+// none. It returns sql.ErrNoRows when the product does not exist.
+func lockOutletPrice(tx *sql.Tx, outletID, productID int) (catalogPrice int, override *int, err error) {
+	catalogPrice, err = lockPrice(tx, productID)
+	if err != nil {
+		return 0, nil, err
+	}
+	err = tx.QueryRow("SELECT price FROM outlet_prices WHERE outlet_id = $1 AND product_id = $2 FOR UPDATE",
+		outletID, productID).Scan(&override)
+	if err == sql.ErrNoRows {
+		return catalogPrice, nil, nil
+	}
+	return catalogPrice, override, err
+}
+
+// outletProductField names a product's field in the audit entries of an
+// outlet, e.g. products[12].price.
+func outletProductField(productID int, name string) string {
+	return fmt.Sprintf("products[%d].%s", productID, name)
+}
+
+// checkOutletExists returns sql.ErrNoRows when there is no such outlet.
+func checkOutletExists(q queryRower, id int) error {
+	var exists bool
+	return q.QueryRow("SELECT TRUE FROM outlets WHERE id = $1", id).Scan(&exists)
+}
+
+// lockActiveProduct locks the product's row, returning ErrProductNotFound
+// when it does not exist or is archived.
+func lockActiveProduct(q queryRower, id int) error {
+	var exists bool
+	err := q.QueryRow("SELECT TRUE FROM products WHERE id = $1 AND deleted_at IS NULL FOR UPDATE", id).Scan(&exists)
+	if err == sql.ErrNoRows {
+		return ErrProductNotFound
+	}
+	return err
+}
+
+// terminalOutlet returns the outlet the terminal sells from: the one it is
+// assigned to, or the default outlet.
+func terminalOutlet(q queryRower, terminalID string) (int, error) {
+	var id int
+	err := q.QueryRow("SELECT COALESCE((SELECT outlet_id FROM terminals WHERE id = $1), "+defaultOutlet+")", terminalID).Scan(&id)
+	return id, err
+}
+
+// setOutletStock sets the product's stock at the outlet.
+func setOutletStock(q execer, outletID, productID, stock int) error {
+	_, err := q.Exec(`
+		INSERT INTO outlet_stock (outlet_id, product_id, stock) VALUES ($1, $2, $3)
+		ON CONFLICT (outlet_id, product_id) DO UPDATE SET stock = EXCLUDED.stock`,
+		outletID, productID, stock)
+	return err
+}
+
+// setDefaultStock sets the product's stock at the default outlet, the one
+// shown on the product.
+func setDefaultStock(q execer, productID, stock int) error {
+	_, err := q.Exec(`
+		INSERT INTO outlet_stock (outlet_id, product_id, stock) VALUES (`+defaultOutlet+`, $1, $2)
+		ON CONFLICT (outlet_id, product_id) DO UPDATE SET stock = EXCLUDED.stock`,
+		productID, stock)
+	return err
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/model"
+)
+
+type ParentProductRepository interface {
+	GetAll(search string) ([]model.ParentProduct, error)
+	GetByID(id int) (*model.ParentProduct, error)
+	Create(parent *model.ParentProduct) error
+	Update(id int, parent *model.ParentProduct) error
+	Delete(id int) error
+}
+
+type parentProductRepository struct {
+	db *sql.DB
+}
+
+func NewParentProductRepository(db *sql.DB) ParentProductRepository {
+	return &parentProductRepository{db: db}
+}
+
+func (r *parentProductRepository) GetAll(search string) ([]model.ParentProduct, error) {
+	query := "SELECT id, name, description, category_id FROM parent_products"
+	var args []any
+	if search != "" {
+		query += " WHERE name ILIKE $1"
+		args = append(args, "%"+search+"%")
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parents []model.ParentProduct
+	index := map[int]int{}
+	for rows.Next() {
+		var pp model.ParentProduct
+		if err := rows.Scan(&pp.ID, &pp.Name, &pp.Description, &pp.CategoryID); err != nil {
+			return nil, err
+		}
+		pp.Variants = []model.Product{}
+		index[pp.ID] = len(parents)
+		parents = append(parents, pp)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(parents) == 0 {
+		return parents, nil
+	}
+
+	variantRows, err := r.db.Query("SELECT " + productColumns + " FROM products p WHERE p.parent_id IS NOT NULL AND p.deleted_at IS NULL ORDER BY p.id")
+	if err != nil {
+		return nil, err
+	}
+	defer variantRows.Close()
+
+	for variantRows.Next() {
+		p, err := scanProduct(variantRows, false)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[*p.ParentID]; ok {
+			parents[i].Variants = append(parents[i].Variants, *p)
+		}
+	}
+	return parents, variantRows.Err()
+}
+
+func (r *parentProductRepository) GetByID(id int) (*model.ParentProduct, error) {
+	var pp model.ParentProduct
+	err := r.db.QueryRow("SELECT id, name, description, category_id FROM parent_products WHERE id = $1", id).
+		Scan(&pp.ID, &pp.Name, &pp.Description, &pp.CategoryID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	rows, err := r.db.Query("SELECT "+productColumns+" FROM products p WHERE p.parent_id = $1 AND p.deleted_at IS NULL ORDER BY p.id", id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pp.Variants = []model.Product{}
+	for rows.Next() {
+		p, err := scanProduct(rows, false)
+		if err != nil {
+			return nil, err
+		}
+		pp.Variants = append(pp.Variants, *p)
+	}
+	return &pp, rows.Err()
+}
+
+func (r *parentProductRepository) Create(parent *model.ParentProduct) error {
+	return r.db.QueryRow(
+		"INSERT INTO parent_products (name, description, category_id) VALUES ($1, $2, $3) RETURNING id",
+		parent.Name, parent.Description, parent.CategoryID,
+	).Scan(&parent.ID)
+}
+
+func (r *parentProductRepository) Update(id int, parent *model.ParentProduct) error {
+	result, err := r.db.Exec(
+		"UPDATE parent_products SET name = $1, description = $2, category_id = $3 WHERE id = $4",
+		parent.Name, parent.Description, parent.CategoryID, id,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	parent.ID = id
+	return nil
+}
+
+func (r *parentProductRepository) Delete(id int) error {
+	result, err := r.db.Exec("DELETE FROM parent_products WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
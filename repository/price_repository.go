@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/model"
+)
+
+var ErrScheduledPriceNotFound = errors.New("scheduled price change not found")
+var ErrScheduledPriceNotPending = errors.New("scheduled price change has already been applied or cancelled")
+
+type PriceRepository interface {
+	GetHistory(productID int) ([]model.PriceChange, error)
+	GetScheduled(productID int) ([]model.ScheduledPriceChange, error)
+	Schedule(change *model.ScheduledPriceChange) error
+	CancelScheduled(productID, id int) (*model.ScheduledPriceChange, error)
+	// ApplyDue applies up to limit pending changes whose effective time has
+	// passed, recording each in the price history, and returns them.
+	ApplyDue(limit int) ([]model.ScheduledPriceChange, error)
+}
+
+type priceRepository struct {
+	db *sql.DB
+}
+
+func NewPriceRepository(db *sql.DB) PriceRepository {
+	return &priceRepository{db: db}
+}
+
+const priceChangeColumns = "id, product_id, outlet_id, old_price, new_price, source, changed_by, scheduled_change_id, changed_at"
+
+const scheduledPriceColumns = "id, product_id, price, effective_at, status, created_by, created_at, applied_at, cancelled_at"
+
+func scanScheduledPrice(row rowScanner) (*model.ScheduledPriceChange, error) {
+	var c model.ScheduledPriceChange
+	err := row.Scan(&c.ID, &c.ProductID, &c.Price, &c.EffectiveAt, &c.Status, &c.CreatedBy, &c.CreatedAt,
+		&c.AppliedAt, &c.CancelledAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (r *priceRepository) GetHistory(productID int) ([]model.PriceChange, error) {
+	rows, err := r.db.Query("SELECT "+priceChangeColumns+" FROM product_price_history WHERE product_id = $1 ORDER BY changed_at DESC, id DESC", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []model.PriceChange
+	for rows.Next() {
+		var c model.PriceChange
+		if err := rows.Scan(&c.ID, &c.ProductID, &c.OutletID, &c.OldPrice, &c.NewPrice, &c.Source, &c.ChangedBy,
+			&c.ScheduledChangeID, &c.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *priceRepository) GetScheduled(productID int) ([]model.ScheduledPriceChange, error) {
+	rows, err := r.db.Query("SELECT "+scheduledPriceColumns+" FROM scheduled_price_changes WHERE product_id = $1 ORDER BY effective_at, id", productID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []model.ScheduledPriceChange
+	for rows.Next() {
+		c, err := scanScheduledPrice(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, *c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *priceRepository) Schedule(change *model.ScheduledPriceChange) error {
+	c, err := scanScheduledPrice(r.db.QueryRow(
+		`INSERT INTO scheduled_price_changes (product_id, price, effective_at, created_by)
+		VALUES ($1, $2, $3, $4) RETURNING `+scheduledPriceColumns,
+		change.ProductID, change.Price, change.EffectiveAt, change.CreatedBy,
+	))
+	if err != nil {
+		return err
+	}
+	*change = *c
+	return nil
+}
+
+// CancelScheduled cancels a pending change of the product and returns it.
+func (r *priceRepository) CancelScheduled(productID, id int) (*model.ScheduledPriceChange, error) {
+	c, err := scanScheduledPrice(r.db.QueryRow(
+		`UPDATE scheduled_price_changes SET status = 'cancelled', cancelled_at = NOW()
+		WHERE id = $1 AND product_id = $2 AND status = 'pending' RETURNING `+scheduledPriceColumns,
+		id, productID,
+	))
+	if err != sql.ErrNoRows {
+		return c, err
+	}
+
+	var exists bool
+	err = r.db.QueryRow("SELECT EXISTS (SELECT 1 FROM scheduled_price_changes WHERE id = $1 AND product_id = $2)",
+		id, productID).Scan(&exists)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrScheduledPriceNotFound
+	}
+	return nil, ErrScheduledPriceNotPending
+}
+
+// ApplyDue runs in a single transaction so that a price is never changed
+// without its history entry. SKIP LOCKED lets several workers share the
+// schedule without applying the same change twice.
+func (r *priceRepository) ApplyDue(limit int) ([]model.ScheduledPriceChange, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT `+scheduledPriceColumns+` FROM scheduled_price_changes
+		WHERE status = 'pending' AND effective_at <= NOW()
+		ORDER BY effective_at, id
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED`, limit)
+	if err != nil {
+		return nil, err
+	}
+	var due []model.ScheduledPriceChange
+	for rows.Next() {
+		c, err := scanScheduledPrice(rows)
+		if err != nil {
+			rows.Close()
+			return nil, err
+		}
+		due = append(due, *c)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range due {
+		c := &due[i]
+		oldPrice, err := lockPrice(tx, c.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.Exec("UPDATE products SET price = $1, version = version + 1 WHERE id = $2", c.Price, c.ProductID); err != nil {
+			return nil, err
+		}
+		if err := recordPriceChange(tx, c.ProductID, nil, oldPrice, c.Price, model.PriceSourceScheduled, c.CreatedBy, &c.ID); err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRow("UPDATE scheduled_price_changes SET status = 'applied', applied_at = NOW() WHERE id = $1 RETURNING status, applied_at",
+			c.ID).Scan(&c.Status, &c.AppliedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return due, nil
+}
+
+// lockPrice locks the product row for a write that may change its price and
+// returns the current price.
+func lockPrice(q queryRower, productID int) (int, error) {
+	var price int
+	err := q.QueryRow("SELECT price FROM products WHERE id = $1 FOR UPDATE", productID).Scan(&price)
+	return price, err
+}
+
+// recordPriceChange adds an entry to the price history when the price
+// actually changed. outletID is set for the price charged at one outlet
+// and nil for the catalog price. It must run in the transaction that
+// changed it.
+func recordPriceChange(db execer, productID int, outletID *int, oldPrice, newPrice int, source, changedBy string, scheduledChangeID *int) error {
+	if oldPrice == newPrice {
+		return nil
+	}
+	_, err := db.Exec(
+		`INSERT INTO product_price_history (product_id, outlet_id, old_price, new_price, source, changed_by, scheduled_change_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		productID, outletID, oldPrice, newPrice, source, changedBy, scheduledChangeID,
+	)
+	return err
+}
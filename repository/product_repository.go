@@ -2,18 +2,39 @@ package repository
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"kasir-api/model"
+	"strings"
+
+	"github.com/lib/pq"
 )
 
 type ProductRepository interface {
-	GetAll() ([]model.Product, error)
-	GetAllWithCategory() ([]model.Product, error)
+	GetAll(filter model.ProductFilter) ([]model.Product, error)
+	GetAllWithCategory(filter model.ProductFilter) ([]model.Product, error)
 	GetByID(id int) (*model.Product, error)
 	GetByIDWithCategory(id int) (*model.Product, error)
 	GetByCategoryID(categoryID int) ([]model.Product, error)
-	Create(product *model.Product) error
-	Update(id int, product *model.Product) error
-	Delete(id int) error
+	GetByCategoryTree(categoryID int) ([]model.Product, error)
+	GetByPLU(plu string) (*model.Product, error)
+	GetByBarcode(barcode string) (*model.Product, error)
+	// Create, Update, Patch, Archive, Restore, Delete and an applied
+	// Import record the write with audit in the same transaction.
+	Create(product *model.Product, audit Audit) error
+	// Update, Patch, Archive, Restore and Delete fail with
+	// ErrVersionConflict unless version is 0 or the product's current
+	// version. Writes bump the version. A price change made by Update,
+	// Patch or Import is recorded in the price history against changedBy.
+	Update(id, version int, product *model.Product, changedBy string, audit Audit) error
+	// Patch sets only the columns in changes, keyed by column name, and
+	// returns the new version.
+	Patch(id, version int, changes map[string]any, changedBy string, audit Audit) (int, error)
+	Archive(id, version int, audit Audit) error
+	Restore(id, version int, audit Audit) error
+	Delete(id, version int, audit Audit) error
+	Import(rows []model.ProductImportRow, dryRun bool, changedBy string, audit Audit) (*model.ProductImportResult, error)
 }
 
 type productRepository struct {
@@ -24,33 +45,51 @@ func NewProductRepository(db *sql.DB) ProductRepository {
 	return &productRepository{db: db}
 }
 
-func (r *productRepository) GetAll() ([]model.Product, error) {
-	rows, err := r.db.Query("SELECT id, name, price, stock, category_id FROM products ORDER BY id")
-	if err != nil {
+const productColumns = `p.id, p.name, p.sku, p.barcode, p.price, p.cost, ` + productStock + `, p.is_weighted, p.plu_code,
+			   p.parent_id, p.attributes, p.category_id, p.deleted_at, p.version`
+
+const productWithCategoryQuery = `
+		SELECT ` + productColumns + `,
+			   c.id, c.name, c.description, c.version
+		FROM products p
+		LEFT JOIN categories c ON p.category_id = c.id`
+
+// scanProduct scans a row selected with productColumns, optionally followed
+// by the joined category columns.
+func scanProduct(row rowScanner, withCategory bool) (*model.Product, error) {
+	var p model.Product
+	var attributes []byte
+	var catIDFromJoin, catVersion sql.NullInt64
+	var catName, catDesc sql.NullString
+
+	dest := []any{&p.ID, &p.Name, &p.SKU, &p.Barcode, &p.Price, &p.Cost, &p.Stock, &p.IsWeighted, &p.PLUCode,
+		&p.ParentID, &attributes, &p.CategoryID, &p.DeletedAt, &p.Version}
+	if withCategory {
+		dest = append(dest, &catIDFromJoin, &catName, &catDesc, &catVersion)
+	}
+	if err := row.Scan(dest...); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
 
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &p.CategoryID); err != nil {
+	if len(attributes) > 0 {
+		if err := json.Unmarshal(attributes, &p.Attributes); err != nil {
 			return nil, err
 		}
-		products = append(products, p)
 	}
-	return products, nil
-}
 
-func (r *productRepository) GetAllWithCategory() ([]model.Product, error) {
-	query := `
-		SELECT p.id, p.name, p.price, p.stock, p.category_id,
-			   c.id, c.name, c.description
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		ORDER BY p.id`
+	if catIDFromJoin.Valid {
+		p.Category = &model.Category{
+			ID:          int(catIDFromJoin.Int64),
+			Name:        catName.String,
+			Description: catDesc.String,
+			Version:     int(catVersion.Int64),
+		}
+	}
+	return &p, nil
+}
 
-	rows, err := r.db.Query(query)
+func (r *productRepository) queryProducts(query string, withCategory bool, args ...any) ([]model.Product, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -58,171 +97,418 @@ func (r *productRepository) GetAllWithCategory() ([]model.Product, error) {
 
 	var products []model.Product
 	for rows.Next() {
-		var p model.Product
-		var catID, catIDFromJoin sql.NullInt64
-		var catName, catDesc sql.NullString
-
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &catID,
-			&catIDFromJoin, &catName, &catDesc); err != nil {
+		p, err := scanProduct(rows, withCategory)
+		if err != nil {
 			return nil, err
 		}
-
-		if catID.Valid {
-			categoryID := int(catID.Int64)
-			p.CategoryID = &categoryID
-		}
-
-		if catIDFromJoin.Valid {
-			p.Category = &model.Category{
-				ID:          int(catIDFromJoin.Int64),
-				Name:        catName.String,
-				Description: catDesc.String,
-			}
-		}
-		products = append(products, p)
+		products = append(products, *p)
 	}
-	return products, nil
+	return products, rows.Err()
 }
 
-func (r *productRepository) GetByID(id int) (*model.Product, error) {
-	var p model.Product
-	var catID sql.NullInt64
-
-	err := r.db.QueryRow("SELECT id, name, price, stock, category_id FROM products WHERE id = $1", id).
-		Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &catID)
+func (r *productRepository) queryProduct(query string, withCategory bool, args ...any) (*model.Product, error) {
+	p, err := scanProduct(r.db.QueryRow(query, args...), withCategory)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
 		}
 		return nil, err
 	}
+	return p, nil
+}
 
-	if catID.Valid {
-		categoryID := int(catID.Int64)
-		p.CategoryID = &categoryID
+// filterClause renders the WHERE clause and arguments for a ProductFilter.
+func filterClause(filter model.ProductFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	if !filter.IncludeArchived {
+		conditions = append(conditions, "p.deleted_at IS NULL")
 	}
-	return &p, nil
+	if filter.Search != "" {
+		args = append(args, "%"+filter.Search+"%")
+		conditions = append(conditions, fmt.Sprintf(`(p.name ILIKE $%[1]d OR p.sku ILIKE $%[1]d OR p.barcode ILIKE $%[1]d
+			OR p.parent_id IN (SELECT id FROM parent_products WHERE name ILIKE $%[1]d))`, len(args)))
+	}
+	if filter.ParentID != nil {
+		args = append(args, *filter.ParentID)
+		conditions = append(conditions, fmt.Sprintf("p.parent_id = $%d", len(args)))
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
 }
 
-func (r *productRepository) GetByIDWithCategory(id int) (*model.Product, error) {
-	query := `
-		SELECT p.id, p.name, p.price, p.stock, p.category_id,
-			   c.id, c.name, c.description
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.id = $1`
+func (r *productRepository) GetAll(filter model.ProductFilter) ([]model.Product, error) {
+	where, args := filterClause(filter)
+	return r.queryProducts("SELECT "+productColumns+" FROM products p"+where+" ORDER BY p.id", false, args...)
+}
 
-	var p model.Product
-	var catID, catIDFromJoin sql.NullInt64
-	var catName, catDesc sql.NullString
+func (r *productRepository) GetAllWithCategory(filter model.ProductFilter) ([]model.Product, error) {
+	where, args := filterClause(filter)
+	return r.queryProducts(productWithCategoryQuery+where+" ORDER BY p.id", true, args...)
+}
 
-	err := r.db.QueryRow(query, id).Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &catID,
-		&catIDFromJoin, &catName, &catDesc)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, nil
-		}
-		return nil, err
-	}
+func (r *productRepository) GetByID(id int) (*model.Product, error) {
+	return getProduct(r.db, id)
+}
 
-	if catID.Valid {
-		categoryID := int(catID.Int64)
-		p.CategoryID = &categoryID
+// getProduct returns the product, or nil when it does not exist.
+func getProduct(q queryRower, id int) (*model.Product, error) {
+	p, err := scanProduct(q.QueryRow("SELECT "+productColumns+" FROM products p WHERE p.id = $1", id), false)
+	if err == sql.ErrNoRows {
+		return nil, nil
 	}
+	return p, err
+}
 
-	if catIDFromJoin.Valid {
-		p.Category = &model.Category{
-			ID:          int(catIDFromJoin.Int64),
-			Name:        catName.String,
-			Description: catDesc.String,
-		}
-	}
-	return &p, nil
+func (r *productRepository) GetByIDWithCategory(id int) (*model.Product, error) {
+	return r.queryProduct(productWithCategoryQuery+" WHERE p.id = $1", true, id)
 }
 
 func (r *productRepository) GetByCategoryID(categoryID int) ([]model.Product, error) {
+	return r.queryProducts(productWithCategoryQuery+" WHERE p.category_id = $1 AND p.deleted_at IS NULL ORDER BY p.id", true, categoryID)
+}
+
+// GetByCategoryTree returns the products of categoryID and of all of its
+// non-archived descendant categories.
+func (r *productRepository) GetByCategoryTree(categoryID int) ([]model.Product, error) {
 	query := `
-		SELECT p.id, p.name, p.price, p.stock, p.category_id,
-			   c.id, c.name, c.description
-		FROM products p
-		LEFT JOIN categories c ON p.category_id = c.id
-		WHERE p.category_id = $1
+		WITH RECURSIVE subtree AS (
+			SELECT id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id FROM categories c JOIN subtree s ON c.parent_id = s.id
+			WHERE c.deleted_at IS NULL
+		)` + productWithCategoryQuery + `
+		WHERE p.category_id IN (SELECT id FROM subtree) AND p.deleted_at IS NULL
 		ORDER BY p.id`
+	return r.queryProducts(query, true, categoryID)
+}
+
+func (r *productRepository) GetByPLU(plu string) (*model.Product, error) {
+	return r.queryProduct("SELECT "+productColumns+" FROM products p WHERE p.plu_code = $1 AND p.deleted_at IS NULL", false, plu)
+}
+
+func (r *productRepository) GetByBarcode(barcode string) (*model.Product, error) {
+	return r.queryProduct("SELECT "+productColumns+" FROM products p WHERE p.barcode = $1 AND p.deleted_at IS NULL", false, barcode)
+}
 
-	rows, err := r.db.Query(query, categoryID)
+func (r *productRepository) Create(product *model.Product, audit Audit) error {
+	attributes, err := marshalAttributes(product.Attributes)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer rows.Close()
 
-	var products []model.Product
-	for rows.Next() {
-		var p model.Product
-		var catID, catIDFromJoin sql.NullInt64
-		var catName, catDesc sql.NullString
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
+		`INSERT INTO products (name, sku, barcode, price, cost, is_weighted, plu_code, parent_id, attributes, category_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10) RETURNING id, version`,
+		product.Name, product.SKU, product.Barcode, product.Price, product.Cost, product.IsWeighted,
+		product.PLUCode, product.ParentID, attributes, product.CategoryID,
+	).Scan(&product.ID, &product.Version)
+	if err != nil {
+		return err
+	}
+	if err := setDefaultStock(tx, product.ID, product.Stock); err != nil {
+		return err
+	}
+	created, err := getProduct(tx, product.ID)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, audit, product.ID, nil, created); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-		if err := rows.Scan(&p.ID, &p.Name, &p.Price, &p.Stock, &catID,
-			&catIDFromJoin, &catName, &catDesc); err != nil {
-			return nil, err
+func (r *productRepository) Update(id, version int, product *model.Product, changedBy string, audit Audit) error {
+	attributes, err := marshalAttributes(product.Attributes)
+	if err != nil {
+		return err
+	}
+
+	return r.audited(id, audit, func(tx *sql.Tx, oldPrice int) error {
+		newVersion, err := updateVersioned(tx, "products",
+			`name = $1, sku = $2, barcode = $3, price = $4, cost = $5, is_weighted = $6,
+				plu_code = $7, parent_id = $8, attributes = $9, category_id = $10`,
+			[]any{product.Name, product.SKU, product.Barcode, product.Price, product.Cost, product.IsWeighted,
+				product.PLUCode, product.ParentID, attributes, product.CategoryID},
+			id, version,
+		)
+		if err != nil {
+			return err
+		}
+		if err := setDefaultStock(tx, id, product.Stock); err != nil {
+			return err
+		}
+		if err := recordPriceChange(tx, id, nil, oldPrice, product.Price, model.PriceSourceManual, changedBy, nil); err != nil {
+			return err
 		}
 
-		if catID.Valid {
-			cID := int(catID.Int64)
-			p.CategoryID = &cID
+		product.ID = id
+		product.Version = newVersion
+		return nil
+	})
+}
+
+// productPatchColumns are the product columns Patch may set. "stock" is not
+// a column: it sets the stock at the default outlet.
+var productPatchColumns = map[string]bool{
+	"name": true, "sku": true, "barcode": true, "price": true, "cost": true, "is_weighted": true,
+	"plu_code": true, "parent_id": true, "attributes": true, "category_id": true,
+}
+
+func (r *productRepository) Patch(id, version int, changes map[string]any, changedBy string, audit Audit) (int, error) {
+	if attrs, ok := changes["attributes"].(map[string]string); ok {
+		attributes, err := marshalAttributes(attrs)
+		if err != nil {
+			return 0, err
 		}
+		changes["attributes"] = attributes
+	}
 
-		if catIDFromJoin.Valid {
-			p.Category = &model.Category{
-				ID:          int(catIDFromJoin.Int64),
-				Name:        catName.String,
-				Description: catDesc.String,
+	stock, hasStock := changes["stock"].(int)
+	delete(changes, "stock")
+	newPrice, hasPrice := changes["price"].(int)
+
+	var newVersion int
+	err := r.audited(id, audit, func(tx *sql.Tx, oldPrice int) error {
+		var err error
+		newVersion, err = updateColumns(tx, "products", productPatchColumns, id, version, changes)
+		if err != nil {
+			return err
+		}
+		if hasPrice {
+			if err := recordPriceChange(tx, id, nil, oldPrice, newPrice, model.PriceSourceManual, changedBy, nil); err != nil {
+				return err
 			}
 		}
-		products = append(products, p)
-	}
-	return products, nil
+		if hasStock {
+			return setDefaultStock(tx, id, stock)
+		}
+		return nil
+	})
+	return newVersion, err
 }
 
-func (r *productRepository) Create(product *model.Product) error {
-	return r.db.QueryRow(
-		"INSERT INTO products (name, price, stock, category_id) VALUES ($1, $2, $3, $4) RETURNING id",
-		product.Name, product.Price, product.Stock, product.CategoryID,
-	).Scan(&product.ID)
+// Archive soft-deletes the product, hiding it from listings and checkout
+// while keeping its sales history intact.
+func (r *productRepository) Archive(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx, _ int) error {
+		_, err := updateVersioned(tx, "products", "deleted_at = COALESCE(deleted_at, NOW())", nil, id, version)
+		return err
+	})
 }
 
-func (r *productRepository) Update(id int, product *model.Product) error {
-	result, err := r.db.Exec(
-		"UPDATE products SET name = $1, price = $2, stock = $3, category_id = $4 WHERE id = $5",
-		product.Name, product.Price, product.Stock, product.CategoryID, id,
-	)
+func (r *productRepository) Restore(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx, _ int) error {
+		_, err := updateVersioned(tx, "products", "deleted_at = NULL", nil, id, version)
+		return err
+	})
+}
+
+// Delete permanently removes the product. It returns a *ReferencedError when
+// the product already appears on transactions or carts.
+func (r *productRepository) Delete(id, version int, audit Audit) error {
+	return r.audited(id, audit, func(tx *sql.Tx, _ int) error {
+		if err := lockVersioned(tx, "products", id, version); err != nil {
+			return err
+		}
+
+		dependents := map[string]int{}
+		counts := map[string]string{
+			"transaction_details": "SELECT COUNT(*) FROM transaction_details WHERE product_id = $1",
+			"cart_items":          "SELECT COUNT(*) FROM cart_items WHERE product_id = $1",
+		}
+		for table, query := range counts {
+			var n int
+			if err := tx.QueryRow(query, id).Scan(&n); err != nil {
+				return err
+			}
+			if n > 0 {
+				dependents[table] = n
+			}
+		}
+		if len(dependents) > 0 {
+			return &ReferencedError{Dependents: dependents}
+		}
+
+		return execAffectingOne(tx, "DELETE FROM products WHERE id = $1", id)
+	})
+}
+
+// audited runs write, a change to product id, in a transaction that first
+// locks the product, and records it with audit. write is given the price
+// the product had. It returns sql.ErrNoRows when the product does not
+// exist.
+func (r *productRepository) audited(id int, audit Audit, write func(tx *sql.Tx, oldPrice int) error) error {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	oldPrice, err := lockPrice(tx, id)
 	if err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	before, err := getProduct(tx, id)
+	if err != nil {
+		return err
 	}
-
-	product.ID = id
-	return nil
+	if err := write(tx, oldPrice); err != nil {
+		return err
+	}
+	after, err := getProduct(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, audit, id, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-func (r *productRepository) Delete(id int) error {
-	result, err := r.db.Exec("DELETE FROM products WHERE id = $1", id)
+// Import upserts the rows by SKU inside a single transaction, creating any
+// category that does not exist yet. Each row runs under its own savepoint so
+// that database errors are reported per row. The transaction is only
+// committed when it is not a dry run and every row succeeded.
+func (r *productRepository) Import(rows []model.ProductImportRow, dryRun bool, changedBy string, audit Audit) (*model.ProductImportResult, error) {
+	result := &model.ProductImportResult{DryRun: dryRun, TotalRows: len(rows), Errors: []model.ImportRowError{}}
+
+	tx, err := r.db.Begin()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	categoryIDs := map[string]int{}
+	for _, row := range rows {
+		if _, err := tx.Exec("SAVEPOINT import_row"); err != nil {
+			return nil, err
+		}
+
+		created, newCategoryID, err := importRow(tx, row, categoryIDs, changedBy)
+		if err != nil {
+			if _, rbErr := tx.Exec("ROLLBACK TO SAVEPOINT import_row"); rbErr != nil {
+				return nil, rbErr
+			}
+			result.Errors = append(result.Errors, importRowError(row.Row, err))
+			continue
+		}
+
+		if created {
+			result.Created++
+		} else {
+			result.Updated++
+		}
+		if newCategoryID != 0 {
+			categoryIDs[strings.ToLower(row.CategoryName)] = newCategoryID
+			result.CategoriesCreated++
+		}
+	}
+
+	if dryRun || len(result.Errors) > 0 {
+		return result, nil
+	}
+	// Rows are not traced to product IDs, so the import is recorded as one
+	// entry with its counts.
+	err = writeAudit(tx, audit, 0, nil, map[string]int{
+		"total_rows":         result.TotalRows,
+		"created":            result.Created,
+		"updated":            result.Updated,
+		"categories_created": result.CategoriesCreated,
+	})
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	result.Applied = true
+	return result, nil
+}
+
+// importRow upserts a single row, restoring archived products matched by
+// SKU and recording any price change. categoryIDs caches existing
+// categories by lower-cased name; the ID of a category created for this
+// row is returned so the caller can cache it once the row is known to have
+// succeeded.
+func importRow(tx *sql.Tx, row model.ProductImportRow, categoryIDs map[string]int, changedBy string) (created bool, newCategoryID int, err error) {
+	var categoryID *int
+	if row.CategoryName != "" {
+		key := strings.ToLower(row.CategoryName)
+		id, ok := categoryIDs[key]
+		if !ok {
+			err = tx.QueryRow(
+				"SELECT id FROM categories WHERE LOWER(name) = $1 AND deleted_at IS NULL ORDER BY id LIMIT 1", key,
+			).Scan(&id)
+			switch {
+			case err == sql.ErrNoRows:
+				err = tx.QueryRow("INSERT INTO categories (name, description) VALUES ($1, '') RETURNING id", row.CategoryName).Scan(&id)
+				if err != nil {
+					return false, 0, err
+				}
+				newCategoryID = id
+			case err != nil:
+				return false, 0, err
+			default:
+				categoryIDs[key] = id
+			}
+		}
+		categoryID = &id
+	}
+
+	// The CTE sees the row as it was before the upsert, giving the old price.
+	var productID int
+	var oldPrice sql.NullInt64
+	err = tx.QueryRow(`
+		WITH old AS (SELECT price FROM products WHERE sku = $2)
+		INSERT INTO products (name, sku, barcode, price, cost, category_id)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (sku) DO UPDATE SET name = EXCLUDED.name, barcode = EXCLUDED.barcode,
+			price = EXCLUDED.price, cost = EXCLUDED.cost,
+			category_id = EXCLUDED.category_id, deleted_at = NULL, version = products.version + 1
+		RETURNING xmax = 0, id, (SELECT price FROM old)`,
+		row.Name, row.SKU, row.Barcode, row.Price, row.Cost, categoryID,
+	).Scan(&created, &productID, &oldPrice)
+	if err != nil {
+		return false, 0, err
+	}
+	if err := setDefaultStock(tx, productID, row.Stock); err != nil {
+		return false, 0, err
+	}
+	if oldPrice.Valid {
+		err = recordPriceChange(tx, productID, nil, int(oldPrice.Int64), row.Price, model.PriceSourceImport, changedBy, nil)
+		if err != nil {
+			return false, 0, err
+		}
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	return created, newCategoryID, nil
+}
+
+// importRowError turns a database error into a row error, naming the column
+// behind unique constraint violations such as a barcode already in use.
+func importRowError(row int, err error) model.ImportRowError {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		field := strings.TrimSuffix(strings.TrimPrefix(pqErr.Constraint, "products_"), "_key")
+		return model.ImportRowError{Row: row, Field: field, Message: field + " is already used by another product"}
+	}
+	return model.ImportRowError{Row: row, Message: err.Error()}
+}
+
+func marshalAttributes(attributes map[string]string) ([]byte, error) {
+	if attributes == nil {
+		return []byte("{}"), nil
 	}
-	return nil
+	return json.Marshal(attributes)
 }
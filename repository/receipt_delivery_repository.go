@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/model"
+	"time"
+)
+
+type ReceiptDeliveryRepository interface {
+	Create(delivery *model.ReceiptDelivery) error
+	GetByTransactionID(transactionID int) ([]model.ReceiptDelivery, error)
+	ClaimDue(limit int) ([]model.ReceiptDelivery, error)
+	MarkSent(id int) error
+	MarkFailed(id int, lastError string, retryAfter time.Duration) error
+	ResetInFlight() error
+}
+
+type receiptDeliveryRepository struct {
+	db *sql.DB
+}
+
+func NewReceiptDeliveryRepository(db *sql.DB) ReceiptDeliveryRepository {
+	return &receiptDeliveryRepository{db: db}
+}
+
+const receiptDeliveryColumns = `id, transaction_id, channel, recipient, status, attempts, last_error,
+		next_attempt_at, sent_at, created_at`
+
+func scanReceiptDelivery(row rowScanner) (*model.ReceiptDelivery, error) {
+	var d model.ReceiptDelivery
+	err := row.Scan(&d.ID, &d.TransactionID, &d.Channel, &d.Recipient, &d.Status, &d.Attempts, &d.LastError,
+		&d.NextAttemptAt, &d.SentAt, &d.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *receiptDeliveryRepository) queryDeliveries(query string, args ...any) ([]model.ReceiptDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []model.ReceiptDelivery
+	for rows.Next() {
+		d, err := scanReceiptDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (r *receiptDeliveryRepository) Create(delivery *model.ReceiptDelivery) error {
+	d, err := scanReceiptDelivery(r.db.QueryRow(
+		`INSERT INTO receipt_deliveries (transaction_id, channel, recipient)
+		VALUES ($1, $2, $3) RETURNING `+receiptDeliveryColumns,
+		delivery.TransactionID, delivery.Channel, delivery.Recipient,
+	))
+	if err != nil {
+		return err
+	}
+	*delivery = *d
+	return nil
+}
+
+func (r *receiptDeliveryRepository) GetByTransactionID(transactionID int) ([]model.ReceiptDelivery, error) {
+	return r.queryDeliveries("SELECT "+receiptDeliveryColumns+" FROM receipt_deliveries WHERE transaction_id = $1 ORDER BY id", transactionID)
+}
+
+// ClaimDue marks up to limit pending deliveries whose next attempt is due
+// as sending and returns them. SKIP LOCKED lets several workers share the
+// queue without claiming the same delivery twice.
+func (r *receiptDeliveryRepository) ClaimDue(limit int) ([]model.ReceiptDelivery, error) {
+	return r.queryDeliveries(`
+		UPDATE receipt_deliveries SET status = 'sending', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM receipt_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING `+receiptDeliveryColumns, limit)
+}
+
+func (r *receiptDeliveryRepository) MarkSent(id int) error {
+	return execAffectingOne(r.db,
+		"UPDATE receipt_deliveries SET status = 'sent', last_error = '', sent_at = NOW() WHERE id = $1", id)
+}
+
+// MarkFailed records a failed attempt. The delivery is retried after
+// retryAfter, or given up on when retryAfter is not positive.
+func (r *receiptDeliveryRepository) MarkFailed(id int, lastError string, retryAfter time.Duration) error {
+	if retryAfter <= 0 {
+		return execAffectingOne(r.db,
+			"UPDATE receipt_deliveries SET status = 'failed', last_error = $1 WHERE id = $2", lastError, id)
+	}
+	return execAffectingOne(r.db,
+		"UPDATE receipt_deliveries SET status = 'pending', last_error = $1, next_attempt_at = NOW() + $2 * INTERVAL '1 second' WHERE id = $3",
+		lastError, retryAfter.Seconds(), id)
+}
+
+// ResetInFlight puts deliveries left in sending by a crashed worker back in
+// the queue.
+func (r *receiptDeliveryRepository) ResetInFlight() error {
+	_, err := r.db.Exec("UPDATE receipt_deliveries SET status = 'pending' WHERE status = 'sending'")
+	return err
+}
@@ -6,9 +6,15 @@ import (
 	"time"
 )
 
+// ReportRepository computes sales reports. An outletID of 0 covers every
+// outlet; any other limits the report to that outlet's sales.
 type ReportRepository interface {
-	GetTodaySummary() (*model.SalesSummary, error)
-	GetSummaryByDateRange(startDate, endDate time.Time) (*model.SalesSummary, error)
+	GetTodaySummary(outletID int) (*model.SalesSummary, error)
+	GetSummaryByDateRange(startDate, endDate time.Time, outletID int) (*model.SalesSummary, error)
+	GetTodayCategorySales(outletID int) ([]model.CategorySales, error)
+	GetCategorySalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CategorySales, error)
+	GetTodayCashierSales(outletID int) ([]model.CashierSales, error)
+	GetCashierSalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CashierSales, error)
 }
 
 type reportRepository struct {
@@ -19,25 +25,25 @@ func NewReportRepository(db *sql.DB) ReportRepository {
 	return &reportRepository{db: db}
 }
 
-func (r *reportRepository) GetTodaySummary() (*model.SalesSummary, error) {
+func (r *reportRepository) GetTodaySummary(outletID int) (*model.SalesSummary, error) {
 	now := time.Now()
 	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-	return r.getSummary(startOfDay, startOfDay.Add(24*time.Hour))
+	return r.getSummary(startOfDay, startOfDay.Add(24*time.Hour), outletID)
 }
 
-func (r *reportRepository) GetSummaryByDateRange(startDate, endDate time.Time) (*model.SalesSummary, error) {
+func (r *reportRepository) GetSummaryByDateRange(startDate, endDate time.Time, outletID int) (*model.SalesSummary, error) {
 	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
-	return r.getSummary(startDate, endDate)
+	return r.getSummary(startDate, endDate, outletID)
 }
 
-func (r *reportRepository) getSummary(startDate, endDate time.Time) (*model.SalesSummary, error) {
+func (r *reportRepository) getSummary(startDate, endDate time.Time, outletID int) (*model.SalesSummary, error) {
 	summary := &model.SalesSummary{}
 
 	err := r.db.QueryRow(`
 		SELECT COALESCE(SUM(total_amount), 0), COUNT(*)
 		FROM transactions
-		WHERE created_at >= $1 AND created_at < $2`,
-		startDate, endDate,
+		WHERE created_at >= $1 AND created_at < $2 AND voided_at IS NULL AND ($3 = 0 OR outlet_id = $3)`,
+		startDate, endDate, outletID,
 	).Scan(&summary.TotalRevenue, &summary.TotalTransactions)
 	if err != nil {
 		return nil, err
@@ -48,11 +54,12 @@ func (r *reportRepository) getSummary(startDate, endDate time.Time) (*model.Sale
 		FROM transaction_details td
 		JOIN transactions t ON td.transaction_id = t.id
 		JOIN products p ON td.product_id = p.id
-		WHERE t.created_at >= $1 AND t.created_at < $2
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.voided_at IS NULL AND ($3 = 0 OR t.outlet_id = $3)
+			AND NOT p.is_weighted
 		GROUP BY td.product_id, p.name
 		ORDER BY total_sold DESC
 		LIMIT 5`,
-		startDate, endDate,
+		startDate, endDate, outletID,
 	)
 	if err != nil {
 		return nil, err
@@ -71,5 +78,184 @@ func (r *reportRepository) getSummary(startDate, endDate time.Time) (*model.Sale
 		summary.TopProducts = []model.TopProduct{}
 	}
 
+	// Weighted quantities are grams or millilitres, so they are ranked on
+	// their own rather than against item counts.
+	weightedRows, err := r.db.Query(`
+		SELECT td.product_id, p.name, SUM(td.quantity) as total_weight
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN products p ON td.product_id = p.id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.voided_at IS NULL AND ($3 = 0 OR t.outlet_id = $3)
+			AND p.is_weighted
+		GROUP BY td.product_id, p.name
+		ORDER BY total_weight DESC
+		LIMIT 5`,
+		startDate, endDate, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer weightedRows.Close()
+
+	for weightedRows.Next() {
+		var tp model.TopWeightedProduct
+		if err := weightedRows.Scan(&tp.ProductID, &tp.ProductName, &tp.TotalWeight); err != nil {
+			return nil, err
+		}
+		summary.TopWeightedProducts = append(summary.TopWeightedProducts, tp)
+	}
+
+	if summary.TopWeightedProducts == nil {
+		summary.TopWeightedProducts = []model.TopWeightedProduct{}
+	}
+
+	parentRows, err := r.db.Query(`
+		SELECT pp.id, pp.name, SUM(td.quantity) as total_sold
+		FROM transaction_details td
+		JOIN transactions t ON td.transaction_id = t.id
+		JOIN products p ON td.product_id = p.id
+		JOIN parent_products pp ON p.parent_id = pp.id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND t.voided_at IS NULL AND ($3 = 0 OR t.outlet_id = $3)
+			AND NOT p.is_weighted
+		GROUP BY pp.id, pp.name
+		ORDER BY total_sold DESC
+		LIMIT 5`,
+		startDate, endDate, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer parentRows.Close()
+
+	for parentRows.Next() {
+		var tp model.TopParentProduct
+		if err := parentRows.Scan(&tp.ParentProductID, &tp.Name, &tp.TotalSold); err != nil {
+			return nil, err
+		}
+		summary.TopParentProducts = append(summary.TopParentProducts, tp)
+	}
+
+	if summary.TopParentProducts == nil {
+		summary.TopParentProducts = []model.TopParentProduct{}
+	}
+
+	outletRows, err := r.db.Query(`
+		SELECT o.id, o.name, COALESCE(SUM(t.total_amount), 0), COUNT(t.id)
+		FROM outlets o
+		LEFT JOIN transactions t ON t.outlet_id = o.id
+			AND t.created_at >= $1 AND t.created_at < $2 AND t.voided_at IS NULL
+		WHERE $3 = 0 OR o.id = $3
+		GROUP BY o.id, o.name
+		ORDER BY o.id`,
+		startDate, endDate, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer outletRows.Close()
+
+	summary.Outlets = []model.OutletSales{}
+	for outletRows.Next() {
+		var sales model.OutletSales
+		if err := outletRows.Scan(&sales.OutletID, &sales.Name, &sales.Revenue, &sales.Transactions); err != nil {
+			return nil, err
+		}
+		summary.Outlets = append(summary.Outlets, sales)
+	}
+	if err := outletRows.Err(); err != nil {
+		return nil, err
+	}
+
 	return summary, nil
 }
+
+func (r *reportRepository) GetTodayCategorySales(outletID int) ([]model.CategorySales, error) {
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return r.getCategorySales(startOfDay, startOfDay.Add(24*time.Hour), outletID)
+}
+
+func (r *reportRepository) GetCategorySalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CategorySales, error) {
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+	return r.getCategorySales(startDate, endDate, outletID)
+}
+
+// getCategorySales returns every category with the sales of the products
+// assigned directly to it, counting weighted products by weight rather than
+// as items. Totals are left for the caller to roll up.
+func (r *reportRepository) getCategorySales(startDate, endDate time.Time, outletID int) ([]model.CategorySales, error) {
+	rows, err := r.db.Query(`
+		SELECT c.id, c.name, c.parent_id,
+			   COALESCE(SUM(s.quantity) FILTER (WHERE NOT s.is_weighted), 0),
+			   COALESCE(SUM(s.quantity) FILTER (WHERE s.is_weighted), 0),
+			   COALESCE(SUM(s.subtotal), 0)
+		FROM categories c
+		LEFT JOIN (
+			SELECT p.category_id, p.is_weighted, td.quantity, td.subtotal
+			FROM transaction_details td
+			JOIN transactions t ON td.transaction_id = t.id
+			JOIN products p ON td.product_id = p.id
+			WHERE t.created_at >= $1 AND t.created_at < $2 AND t.voided_at IS NULL AND ($3 = 0 OR t.outlet_id = $3)
+		) s ON s.category_id = c.id
+		GROUP BY c.id, c.name, c.parent_id
+		ORDER BY c.id`,
+		startDate, endDate, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sales []model.CategorySales
+	for rows.Next() {
+		var cs model.CategorySales
+		if err := rows.Scan(&cs.CategoryID, &cs.CategoryName, &cs.ParentID, &cs.Quantity, &cs.Weight, &cs.Revenue); err != nil {
+			return nil, err
+		}
+		sales = append(sales, cs)
+	}
+	return sales, rows.Err()
+}
+
+func (r *reportRepository) GetTodayCashierSales(outletID int) ([]model.CashierSales, error) {
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	return r.getCashierSales(startOfDay, startOfDay.Add(24*time.Hour), outletID)
+}
+
+func (r *reportRepository) GetCashierSalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CashierSales, error) {
+	endDate = time.Date(endDate.Year(), endDate.Month(), endDate.Day(), 23, 59, 59, 999999999, endDate.Location())
+	return r.getCashierSales(startDate, endDate, outletID)
+}
+
+// getCashierSales returns the cashiers who made sales in the range, by
+// revenue. Transactions made before cashiers were recorded are left out.
+func (r *reportRepository) getCashierSales(startDate, endDate time.Time, outletID int) ([]model.CashierSales, error) {
+	rows, err := r.db.Query(`
+		SELECT u.id, u.username, u.name,
+			   COALESCE(SUM(t.total_amount) FILTER (WHERE t.voided_at IS NULL), 0),
+			   COUNT(*) FILTER (WHERE t.voided_at IS NULL),
+			   COUNT(*) FILTER (WHERE t.voided_at IS NOT NULL),
+			   COALESCE(SUM(t.total_amount) FILTER (WHERE t.voided_at IS NOT NULL), 0)
+		FROM transactions t
+		JOIN users u ON t.cashier_id = u.id
+		WHERE t.created_at >= $1 AND t.created_at < $2 AND ($3 = 0 OR t.outlet_id = $3)
+		GROUP BY u.id, u.username, u.name
+		ORDER BY 4 DESC, u.id`,
+		startDate, endDate, outletID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var sales []model.CashierSales
+	for rows.Next() {
+		var cs model.CashierSales
+		if err := rows.Scan(&cs.CashierID, &cs.Username, &cs.Name, &cs.Revenue, &cs.Transactions, &cs.Voids, &cs.VoidedAmount); err != nil {
+			return nil, err
+		}
+		sales = append(sales, cs)
+	}
+	return sales, rows.Err()
+}
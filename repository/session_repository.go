@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"database/sql"
+	"kasir-api/model"
+	"time"
+)
+
+// SessionRepository stores login sessions. A session holds the hash of its
+// current refresh token; access tokens name the session they belong to, so
+// revoking it ends them too.
+type SessionRepository interface {
+	Create(userID int, refreshHash string, expiresAt time.Time) (int, error)
+	// Rotate replaces the refresh token of the live session holding
+	// refreshHash and returns the session ID and its user. It returns
+	// sql.ErrNoRows when no live session holds that token.
+	Rotate(refreshHash, newHash string, expiresAt time.Time) (int, *model.User, error)
+	// GetUser returns the active user of a live session, or nil.
+	GetUser(sessionID int) (*model.User, error)
+	Revoke(sessionID int) error
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+func NewSessionRepository(db *sql.DB) SessionRepository {
+	return &sessionRepository{db: db}
+}
+
+const liveSession = "s.revoked_at IS NULL AND s.expires_at > NOW() AND u.active"
+
+func (r *sessionRepository) Create(userID int, refreshHash string, expiresAt time.Time) (int, error) {
+	var id int
+	err := r.db.QueryRow(
+		"INSERT INTO auth_sessions (user_id, refresh_token_hash, expires_at) VALUES ($1, $2, $3) RETURNING id",
+		userID, refreshHash, expiresAt,
+	).Scan(&id)
+	return id, err
+}
+
+func (r *sessionRepository) Rotate(refreshHash, newHash string, expiresAt time.Time) (int, *model.User, error) {
+	var sessionID, userID int
+	err := r.db.QueryRow(`
+		UPDATE auth_sessions s SET refresh_token_hash = $2, expires_at = $3, last_used_at = NOW()
+		FROM users u
+		WHERE u.id = s.user_id AND s.refresh_token_hash = $1 AND `+liveSession+`
+		RETURNING s.id, s.user_id`,
+		refreshHash, newHash, expiresAt,
+	).Scan(&sessionID, &userID)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	user, err := scanUser(r.db.QueryRow("SELECT "+userColumns+" FROM users u WHERE u.id = $1", userID))
+	if err != nil {
+		return 0, nil, err
+	}
+	return sessionID, user, nil
+}
+
+func (r *sessionRepository) GetUser(sessionID int) (*model.User, error) {
+	user, err := scanUser(r.db.QueryRow(`
+		SELECT `+userColumns+`
+		FROM auth_sessions s JOIN users u ON u.id = s.user_id
+		WHERE s.id = $1 AND `+liveSession, sessionID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return user, err
+}
+
+func (r *sessionRepository) Revoke(sessionID int) error {
+	return execAffectingOne(r.db,
+		"UPDATE auth_sessions SET revoked_at = COALESCE(revoked_at, NOW()) WHERE id = $1", sessionID)
+}
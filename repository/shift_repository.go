@@ -0,0 +1,262 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"kasir-api/model"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+var ErrShiftAlreadyOpen = errors.New("cashier already has an open shift")
+var ErrTerminalInUse = errors.New("terminal already has an open shift")
+var ErrShiftClosed = errors.New("shift is closed")
+var ErrNoOpenShift = errors.New("cashier has no open shift on this terminal")
+
+type ShiftRepository interface {
+	// Open starts the shift. It returns ErrShiftAlreadyOpen or
+	// ErrTerminalInUse when the cashier or the terminal already has an open
+	// shift.
+	Open(shift *model.Shift) error
+	// GetByID returns the shift with its cash events.
+	GetByID(id int) (*model.Shift, error)
+	// GetOpen returns the cashier's open shift, or nil.
+	GetOpen(cashierID int) (*model.Shift, error)
+	GetAll(filter model.ShiftFilter) ([]model.Shift, error)
+	// AddCashEvent records cash put into or taken out of an open shift's
+	// drawer.
+	AddCashEvent(event *model.CashEvent) error
+	// Close records the counted cash and fixes the shift's totals. It waits
+	// for checkouts in progress on the shift to finish.
+	Close(id, closedBy, countedCash int, note string) error
+}
+
+type shiftRepository struct {
+	db *sql.DB
+}
+
+func NewShiftRepository(db *sql.DB) ShiftRepository {
+	return &shiftRepository{db: db}
+}
+
+// Live totals of a shift s. Cash sales are what cash payments left in the
+// drawer, i.e. the total of each cash sale that has not been voided.
+const (
+	shiftCashSales = `(SELECT COALESCE(SUM(total_amount), 0) FROM transactions
+		WHERE shift_id = s.id AND payment_method = 'cash' AND voided_at IS NULL)`
+	shiftCashIn  = `(SELECT COALESCE(SUM(amount), 0) FROM shift_cash_events WHERE shift_id = s.id AND type = 'cash_in')`
+	shiftCashOut = `(SELECT COALESCE(SUM(amount), 0) FROM shift_cash_events WHERE shift_id = s.id AND type = 'cash_out')`
+)
+
+// shiftSelect reads the stored totals of closed shifts and the live totals
+// of open ones.
+const shiftSelect = `SELECT s.id, s.cashier_id, s.terminal_id, s.opening_float,
+		COALESCE(s.cash_sales, ` + shiftCashSales + `),
+		COALESCE(s.cash_in, ` + shiftCashIn + `),
+		COALESCE(s.cash_out, ` + shiftCashOut + `),
+		s.counted_cash, s.note, s.opened_at, s.closed_at, s.closed_by
+	FROM shifts s`
+
+func scanShift(row rowScanner) (*model.Shift, error) {
+	var s model.Shift
+	err := row.Scan(&s.ID, &s.CashierID, &s.TerminalID, &s.OpeningFloat, &s.CashSales, &s.CashIn, &s.CashOut,
+		&s.CountedCash, &s.Note, &s.OpenedAt, &s.ClosedAt, &s.ClosedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Status = model.ShiftOpen
+	if s.ClosedAt != nil {
+		s.Status = model.ShiftClosed
+	}
+	s.ExpectedCash = s.OpeningFloat + s.CashSales + s.CashIn - s.CashOut
+	if s.CountedCash != nil {
+		variance := *s.CountedCash - s.ExpectedCash
+		s.Variance = &variance
+	}
+	return &s, nil
+}
+
+func (r *shiftRepository) Open(shift *model.Shift) error {
+	var id int
+	err := r.db.QueryRow(
+		"INSERT INTO shifts (cashier_id, terminal_id, opening_float) VALUES ($1, $2, $3) RETURNING id",
+		shift.CashierID, shift.TerminalID, shift.OpeningFloat,
+	).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		if pqErr.Constraint == "idx_shifts_open_terminal" {
+			return ErrTerminalInUse
+		}
+		return ErrShiftAlreadyOpen
+	}
+	if err != nil {
+		return err
+	}
+
+	s, err := r.GetByID(id)
+	if err != nil {
+		return err
+	}
+	*shift = *s
+	return nil
+}
+
+func (r *shiftRepository) GetByID(id int) (*model.Shift, error) {
+	shift, err := scanShift(r.db.QueryRow(shiftSelect+" WHERE s.id = $1", id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.Query(
+		"SELECT id, shift_id, type, amount, reason, created_by, created_at FROM shift_cash_events WHERE shift_id = $1 ORDER BY id",
+		id,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	shift.Events = []model.CashEvent{}
+	for rows.Next() {
+		var e model.CashEvent
+		if err := rows.Scan(&e.ID, &e.ShiftID, &e.Type, &e.Amount, &e.Reason, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		shift.Events = append(shift.Events, e)
+	}
+	return shift, rows.Err()
+}
+
+func (r *shiftRepository) GetOpen(cashierID int) (*model.Shift, error) {
+	var id int
+	err := r.db.QueryRow("SELECT id FROM shifts WHERE cashier_id = $1 AND closed_at IS NULL", cashierID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+func (r *shiftRepository) GetAll(filter model.ShiftFilter) ([]model.Shift, error) {
+	var conditions []string
+	var args []any
+	if filter.CashierID != 0 {
+		args = append(args, filter.CashierID)
+		conditions = append(conditions, fmt.Sprintf("s.cashier_id = $%d", len(args)))
+	}
+	if filter.TerminalID != "" {
+		args = append(args, filter.TerminalID)
+		conditions = append(conditions, fmt.Sprintf("s.terminal_id = $%d", len(args)))
+	}
+	switch filter.Status {
+	case model.ShiftOpen:
+		conditions = append(conditions, "s.closed_at IS NULL")
+	case model.ShiftClosed:
+		conditions = append(conditions, "s.closed_at IS NOT NULL")
+	}
+
+	query := shiftSelect
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := r.db.Query(query+" ORDER BY s.opened_at DESC LIMIT 200", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var shifts []model.Shift
+	for rows.Next() {
+		s, err := scanShift(rows)
+		if err != nil {
+			return nil, err
+		}
+		shifts = append(shifts, *s)
+	}
+	return shifts, rows.Err()
+}
+
+func (r *shiftRepository) AddCashEvent(event *model.CashEvent) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenShift(tx, event.ShiftID, "FOR SHARE"); err != nil {
+		return err
+	}
+
+	err = tx.QueryRow(
+		`INSERT INTO shift_cash_events (shift_id, type, amount, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id, created_at`,
+		event.ShiftID, event.Type, event.Amount, event.Reason, event.CreatedBy,
+	).Scan(&event.ID, &event.CreatedAt)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *shiftRepository) Close(id, closedBy, countedCash int, note string) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// The lock waits for checkouts holding the shift, and the update that
+	// follows sees what they committed.
+	if err := lockOpenShift(tx, id, "FOR UPDATE"); err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE shifts s SET closed_at = NOW(), closed_by = $2, counted_cash = $3, note = $4,
+			cash_sales = `+shiftCashSales+`, cash_in = `+shiftCashIn+`, cash_out = `+shiftCashOut+`
+		WHERE s.id = $1`,
+		id, closedBy, countedCash, note,
+	)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// lockOpenShift locks the shift with the given locking clause. It returns
+// sql.ErrNoRows when the shift does not exist and ErrShiftClosed when it
+// has been closed.
+func lockOpenShift(q queryRower, id int, lock string) error {
+	var open bool
+	err := q.QueryRow("SELECT closed_at IS NULL FROM shifts WHERE id = $1 "+lock, id).Scan(&open)
+	if err != nil {
+		return err
+	}
+	if !open {
+		return ErrShiftClosed
+	}
+	return nil
+}
+
+// lockCashierShift returns the cashier's open shift on the terminal, locked
+// so that it cannot be closed before the caller's transaction ends.
+func lockCashierShift(q queryRower, cashierID int, terminalID string) (int, error) {
+	var id int
+	err := q.QueryRow(
+		"SELECT id FROM shifts WHERE cashier_id = $1 AND terminal_id = $2 AND closed_at IS NULL FOR SHARE",
+		cashierID, terminalID,
+	).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, ErrNoOpenShift
+	}
+	return id, err
+}
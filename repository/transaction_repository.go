@@ -3,14 +3,33 @@ package repository
 import (
 	"database/sql"
 	"errors"
+	"fmt"
 	"kasir-api/model"
+	"slices"
+	"strings"
 )
 
 var ErrInsufficientStock = errors.New("insufficient stock")
 var ErrProductNotFound = errors.New("product not found")
+var ErrInsufficientPayment = errors.New("paid amount is less than the total")
+var ErrTransactionVoided = errors.New("transaction has already been voided")
 
 type TransactionRepository interface {
-	Checkout(items []model.CheckoutItem) (*model.Transaction, error)
+	// Checkout and Void record the sale or void with audit in the same
+	// transaction. Checkout passes the priced lines to approve before
+	// selling them, which returns the supervisor who approved their price
+	// overrides and discounts, if one had to, or the error to fail with.
+	Checkout(req model.CheckoutRequest, approve func([]model.CheckoutLine) (approvedBy *int, err error),
+		audit Audit) (*model.Transaction, error)
+	// Preview prices items as Checkout would at the terminal's outlet.
+	Preview(items []model.CheckoutItem, terminalID string) ([]model.CheckoutLine, error)
+	GetByID(id int) (*model.Transaction, error)
+	// GetAll lists transactions, newest first, without their details.
+	GetAll(filter model.TransactionFilter) ([]model.Transaction, error)
+	// Void marks the transaction voided and returns its items to the stock
+	// of the outlet it was sold at.
+	// approvedBy is the supervisor who approved it, if one had to.
+	Void(id, voidedBy int, approvedBy *int, reason string, audit Audit) error
 }
 
 type transactionRepository struct {
@@ -21,7 +40,12 @@ func NewTransactionRepository(db *sql.DB) TransactionRepository {
 	return &transactionRepository{db: db}
 }
 
-func (r *transactionRepository) Checkout(items []model.CheckoutItem) (*model.Transaction, error) {
+// Checkout records a sale and deducts stock in a single database
+// transaction. The sale is priced at, and takes stock from, the outlet of
+// the terminal. When req.CartID is set the cart's lines are sold instead of
+// req.Items at the cart's outlet, and the cart is marked as converted.
+func (r *transactionRepository) Checkout(req model.CheckoutRequest, approve func([]model.CheckoutLine) (*int, error),
+	audit Audit) (*model.Transaction, error) {
 	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
@@ -32,47 +56,101 @@ func (r *transactionRepository) Checkout(items []model.CheckoutItem) (*model.Tra
 		}
 	}()
 
-	var totalAmount int
-	var details []model.TransactionDetail
-
-	for _, item := range items {
-		var productID int
-		var productName string
-		var productPrice int
-		var productStock int
-
-		err = tx.QueryRow("SELECT id, name, price, stock FROM products WHERE id = $1", item.ProductID).
-			Scan(&productID, &productName, &productPrice, &productStock)
+	var outletID int
+	if req.CartID != 0 {
+		var cartItems []model.CheckoutItem
+		cartItems, err = lockCartItems(tx, req.CartID)
 		if err != nil {
-			if err == sql.ErrNoRows {
-				return nil, ErrProductNotFound
-			}
 			return nil, err
 		}
+		req.Items, err = adjustCartItems(cartItems, req.Items)
+		if err != nil {
+			return nil, err
+		}
+		// The sale is made on the terminal the cart was opened on.
+		err = tx.QueryRow("SELECT terminal_id, outlet_id FROM carts WHERE id = $1", req.CartID).Scan(&req.TerminalID, &outletID)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		outletID, err = terminalOutlet(tx, req.TerminalID)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		if productStock < item.Quantity {
-			return nil, ErrInsufficientStock
+	// Cash goes into the drawer of the cashier's shift on the terminal.
+	// Sales made through an API key have no cashier and no drawer.
+	var shiftID *int
+	if req.CashierID != 0 {
+		var id int
+		id, err = lockCashierShift(tx, req.CashierID, req.TerminalID)
+		if err != nil {
+			return nil, err
 		}
+		shiftID = &id
+	}
 
-		subtotal := productPrice * item.Quantity
-		totalAmount += subtotal
+	var lines []model.CheckoutLine
+	lines, err = priceLines(tx, req.Items, outletID, req.CartID, true)
+	if err != nil {
+		return nil, err
+	}
+	if err = checkoutError(lines); err != nil {
+		return nil, err
+	}
+	var discountApprovedBy *int
+	discountApprovedBy, err = approve(lines)
+	if err != nil {
+		return nil, err
+	}
+
+	var totalAmount int
+	var details []model.TransactionDetail
+
+	for _, line := range lines {
+		totalAmount += line.Subtotal
 
-		_, err = tx.Exec("UPDATE products SET stock = stock - $1 WHERE id = $2", item.Quantity, item.ProductID)
+		_, err = tx.Exec("UPDATE outlet_stock SET stock = stock - $1 WHERE outlet_id = $2 AND product_id = $3",
+			line.Quantity, outletID, line.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		_, err = tx.Exec("UPDATE products SET version = version + 1 WHERE id = $1 AND $2 = "+defaultOutlet, line.ProductID, outletID)
 		if err != nil {
 			return nil, err
 		}
 
 		details = append(details, model.TransactionDetail{
-			ProductID:   item.ProductID,
-			ProductName: productName,
-			Quantity:    item.Quantity,
-			Subtotal:    subtotal,
+			ProductID:    line.ProductID,
+			ProductName:  line.ProductName,
+			IsWeighted:   line.IsWeighted,
+			Price:        line.Price,
+			RegularPrice: line.RegularPrice,
+			Quantity:     line.Quantity,
+			Discount:     line.Discount,
+			Subtotal:     line.Subtotal,
 		})
 	}
 
+	paymentMethod := req.PaymentMethod
+	if paymentMethod == "" {
+		paymentMethod = model.PaymentCash
+	}
+	paidAmount := req.PaidAmount
+	if paidAmount == 0 || paymentMethod != model.PaymentCash {
+		paidAmount = totalAmount
+	}
+	if paidAmount < totalAmount {
+		err = ErrInsufficientPayment
+		return nil, err
+	}
+
 	var transactionID int
-	err = tx.QueryRow("INSERT INTO transactions (total_amount) VALUES ($1) RETURNING id", totalAmount).
-		Scan(&transactionID)
+	err = tx.QueryRow(insertTransaction,
+		totalAmount, paymentMethod, paidAmount, paidAmount-totalAmount, req.CashierID, req.TerminalID, outletID, shiftID,
+		discountApprovedBy,
+	).Scan(&transactionID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,8 +158,10 @@ func (r *transactionRepository) Checkout(items []model.CheckoutItem) (*model.Tra
 	for i := range details {
 		var detailID int
 		err = tx.QueryRow(
-			"INSERT INTO transaction_details (transaction_id, product_id, quantity, subtotal) VALUES ($1, $2, $3, $4) RETURNING id",
-			transactionID, details[i].ProductID, details[i].Quantity, details[i].Subtotal,
+			`INSERT INTO transaction_details (transaction_id, product_id, price, regular_price, quantity, discount, subtotal)
+			VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+			transactionID, details[i].ProductID, details[i].Price, details[i].RegularPrice, details[i].Quantity,
+			details[i].Discount, details[i].Subtotal,
 		).Scan(&detailID)
 		if err != nil {
 			return nil, err
@@ -90,17 +170,345 @@ func (r *transactionRepository) Checkout(items []model.CheckoutItem) (*model.Tra
 		details[i].TransactionID = transactionID
 	}
 
+	if req.CartID != 0 {
+		_, err = tx.Exec("UPDATE carts SET status = 'converted', transaction_id = $1, reserved_until = NULL, updated_at = NOW() WHERE id = $2",
+			transactionID, req.CartID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var transaction *model.Transaction
+	transaction, err = scanTransaction(tx.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE id = $1", transactionID))
+	if err != nil {
+		return nil, err
+	}
+	transaction.Details = details
+
+	if err = writeAudit(tx, audit, transactionID, nil, transaction); err != nil {
+		return nil, err
+	}
 	if err = tx.Commit(); err != nil {
 		return nil, err
 	}
+	return transaction, nil
+}
 
-	var transaction model.Transaction
-	err = r.db.QueryRow("SELECT id, total_amount, created_at FROM transactions WHERE id = $1", transactionID).
-		Scan(&transaction.ID, &transaction.TotalAmount, &transaction.CreatedAt)
+// insertTransaction and voidTransaction stamp the sale and the void with
+// closingClock; see there.
+const (
+	insertTransaction = `INSERT INTO transactions
+		(total_amount, payment_method, paid_amount, change_amount, cashier_id, terminal_id, outlet_id, shift_id,
+		discount_approved_by, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, 0), $6, $7, $8, $9, ` + closingClock + `) RETURNING id`
+	voidTransaction = `UPDATE transactions
+		SET voided_at = ` + closingClock + `, voided_by = NULLIF($2, 0), void_approved_by = $3, void_reason = $4
+		WHERE id = $1`
+)
+
+const transactionColumns = `id, total_amount, payment_method, paid_amount, change_amount, cashier_id, terminal_id,
+		outlet_id, shift_id, discount_approved_by, created_at, voided_at, voided_by, void_approved_by, void_reason`
+
+func scanTransaction(row rowScanner) (*model.Transaction, error) {
+	var t model.Transaction
+	err := row.Scan(&t.ID, &t.TotalAmount, &t.PaymentMethod, &t.PaidAmount, &t.ChangeAmount, &t.CashierID, &t.TerminalID,
+		&t.OutletID, &t.ShiftID, &t.DiscountApprovedBy, &t.CreatedAt, &t.VoidedAt, &t.VoidedBy, &t.VoidApprovedBy, &t.VoidReason)
 	if err != nil {
 		return nil, err
 	}
-	transaction.Details = details
+	return &t, nil
+}
+
+// Preview prices items exactly as Checkout would, without locking rows or
+// writing anything. Lines that cannot be sold carry a Problem.
+func (r *transactionRepository) Preview(items []model.CheckoutItem, terminalID string) ([]model.CheckoutLine, error) {
+	outletID, err := terminalOutlet(r.db, terminalID)
+	if err != nil {
+		return nil, err
+	}
+	return priceLines(r.db, items, outletID, 0, false)
+}
+
+func (r *transactionRepository) GetByID(id int) (*model.Transaction, error) {
+	return getTransaction(r.db, id)
+}
+
+// getTransaction returns the transaction with its details, or nil when it
+// does not exist.
+func getTransaction(q querier, id int) (*model.Transaction, error) {
+	transaction, err := scanTransaction(q.QueryRow("SELECT "+transactionColumns+" FROM transactions WHERE id = $1", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
 
-	return &transaction, nil
+	rows, err := q.Query(`
+		SELECT td.id, td.transaction_id, td.product_id, p.name, p.is_weighted, td.price, td.regular_price, td.quantity,
+			td.discount, td.subtotal
+		FROM transaction_details td
+		JOIN products p ON td.product_id = p.id
+		WHERE td.transaction_id = $1
+		ORDER BY td.id`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transaction.Details = []model.TransactionDetail{}
+	for rows.Next() {
+		var d model.TransactionDetail
+		if err := rows.Scan(&d.ID, &d.TransactionID, &d.ProductID, &d.ProductName, &d.IsWeighted,
+			&d.Price, &d.RegularPrice, &d.Quantity, &d.Discount, &d.Subtotal); err != nil {
+			return nil, err
+		}
+		transaction.Details = append(transaction.Details, d)
+	}
+	return transaction, rows.Err()
+}
+
+func (r *transactionRepository) GetAll(filter model.TransactionFilter) ([]model.Transaction, error) {
+	var conditions []string
+	var args []any
+	if filter.CashierID != 0 {
+		args = append(args, filter.CashierID)
+		conditions = append(conditions, fmt.Sprintf("cashier_id = $%d", len(args)))
+	}
+	if filter.TerminalID != "" {
+		args = append(args, filter.TerminalID)
+		conditions = append(conditions, fmt.Sprintf("terminal_id = $%d", len(args)))
+	}
+	if filter.OutletID != 0 {
+		args = append(args, filter.OutletID)
+		conditions = append(conditions, fmt.Sprintf("outlet_id = $%d", len(args)))
+	}
+	if filter.StartDate != nil {
+		args = append(args, *filter.StartDate)
+		conditions = append(conditions, fmt.Sprintf("created_at >= $%d", len(args)))
+	}
+	if filter.EndDate != nil {
+		args = append(args, filter.EndDate.AddDate(0, 0, 1))
+		conditions = append(conditions, fmt.Sprintf("created_at < $%d", len(args)))
+	}
+
+	query := "SELECT " + transactionColumns + " FROM transactions"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	args = append(args, filter.Limit, filter.Offset)
+	query += fmt.Sprintf(" ORDER BY id DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []model.Transaction
+	for rows.Next() {
+		t, err := scanTransaction(rows)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, *t)
+	}
+	return transactions, rows.Err()
+}
+
+func (r *transactionRepository) Void(id, voidedBy int, approvedBy *int, reason string, audit Audit) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var voided bool
+	var outletID int
+	err = tx.QueryRow("SELECT voided_at IS NOT NULL, outlet_id FROM transactions WHERE id = $1 FOR UPDATE", id).Scan(&voided, &outletID)
+	if err != nil {
+		return err
+	}
+	if voided {
+		return ErrTransactionVoided
+	}
+	before, err := getTransaction(tx, id)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(`
+		UPDATE products p SET version = p.version + 1
+		FROM transaction_details d
+		WHERE d.transaction_id = $1 AND p.id = d.product_id AND $2 = `+defaultOutlet, id, outletID)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO outlet_stock (outlet_id, product_id, stock)
+		SELECT $2, product_id, SUM(quantity) FROM transaction_details
+		WHERE transaction_id = $1 GROUP BY product_id
+		ON CONFLICT (outlet_id, product_id) DO UPDATE SET stock = outlet_stock.stock + EXCLUDED.stock`, id, outletID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(voidTransaction, id, voidedBy, approvedBy, reason)
+	if err != nil {
+		return err
+	}
+	after, err := getTransaction(tx, id)
+	if err != nil {
+		return err
+	}
+	if err := writeAudit(tx, audit, id, before, after); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// adjustCartItems gives a cart's items the price overrides and discounts of
+// the matching adjustments. An adjustment for a product the cart does not
+// hold is reported as a problem of its line.
+func adjustCartItems(items, adjustments []model.CheckoutItem) ([]model.CheckoutItem, error) {
+	var problems []model.CheckoutProblem
+	for i, adjustment := range adjustments {
+		j := slices.IndexFunc(items, func(item model.CheckoutItem) bool { return item.ProductID == adjustment.ProductID })
+		if j < 0 {
+			problems = append(problems, model.CheckoutProblem{
+				Line:      i,
+				ProductID: adjustment.ProductID,
+				Code:      model.ProblemProductNotFound,
+				Message:   "Product is not in the cart",
+			})
+			continue
+		}
+		items[j].Price = adjustment.Price
+		items[j].Discount = adjustment.Discount
+	}
+	if len(problems) > 0 {
+		return nil, &CheckoutError{Problems: problems}
+	}
+	return items, nil
+}
+
+// reservedStock returns how much of a product's stock at an outlet is held
+// by parked carts with an unexpired reservation, ignoring the cart
+// identified by exceptCartID.
+func reservedStock(q queryRower, productID, outletID, exceptCartID int) (int, error) {
+	var reserved int
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(ci.quantity), 0)
+		FROM cart_items ci
+		JOIN carts c ON ci.cart_id = c.id
+		WHERE ci.product_id = $1 AND c.outlet_id = $2 AND c.id <> $3
+		  AND c.status = 'parked' AND c.reserved_until > NOW()`,
+		productID, outletID, exceptCartID,
+	).Scan(&reserved)
+	return reserved, err
+}
+
+// priceLines prices each checkout item at the product's current price at
+// the outlet and checks it against the outlet's stock left after
+// reservations and earlier lines for the same product. Every line is
+// checked; an unsellable line gets a Problem rather than stopping the loop.
+// The item's price override and discount are applied after the outlet's
+// price, so a price-embedded label's weight is still worked out from it.
+// forUpdate locks the product rows.
+func priceLines(q queryRower, items []model.CheckoutItem, outletID, exceptCartID int, forUpdate bool) ([]model.CheckoutLine, error) {
+	query := `SELECT p.id, p.name, COALESCE(op.price, p.price), COALESCE(s.stock, 0), p.is_weighted
+		FROM products p
+		LEFT JOIN outlet_prices op ON op.product_id = p.id AND op.outlet_id = $2
+		LEFT JOIN outlet_stock s ON s.product_id = p.id AND s.outlet_id = $2
+		WHERE p.id = $1 AND p.deleted_at IS NULL`
+	if forUpdate {
+		query += " FOR UPDATE OF p"
+	}
+
+	lines := make([]model.CheckoutLine, len(items))
+	requested := make(map[int]int)
+	for i, item := range items {
+		line := model.CheckoutLine{ProductID: item.ProductID, Quantity: item.Quantity}
+
+		var product model.Product
+		err := q.QueryRow(query, item.ProductID, outletID).
+			Scan(&product.ID, &product.Name, &product.Price, &product.Stock, &product.IsWeighted)
+		if err == sql.ErrNoRows {
+			line.Problem = &model.CheckoutProblem{
+				Line:      i,
+				ProductID: item.ProductID,
+				Barcode:   item.Barcode,
+				Code:      model.ProblemProductNotFound,
+				Message:   "Product not found",
+				Requested: item.Quantity,
+			}
+			lines[i] = line
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		line.ProductName = product.Name
+		line.IsWeighted = product.IsWeighted
+		line.Price = product.Price
+		line.Subtotal = product.LineSubtotal(item.Quantity)
+		if item.FixedSubtotal > 0 {
+			// A price-embedded scale label charges its amount for the
+			// weight that buys at this outlet's price.
+			line.Quantity = product.WeightFor(item.FixedSubtotal)
+			line.Subtotal = item.FixedSubtotal
+			if line.Quantity <= 0 {
+				line.Problem = &model.CheckoutProblem{
+					Line:      i,
+					ProductID: item.ProductID,
+					Barcode:   item.Barcode,
+					Code:      model.ProblemInvalidBarcode,
+					Message:   "Invalid barcode",
+				}
+				lines[i] = line
+				continue
+			}
+		}
+		if item.Price != nil {
+			regularPrice := product.Price
+			line.RegularPrice = &regularPrice
+			product.Price = *item.Price
+			line.Price = product.Price
+			line.Subtotal = product.LineSubtotal(line.Quantity)
+		}
+		if item.Discount > line.Subtotal {
+			line.Problem = &model.CheckoutProblem{
+				Line:      i,
+				ProductID: item.ProductID,
+				Barcode:   item.Barcode,
+				Code:      model.ProblemInvalidDiscount,
+				Message:   "Discount exceeds the line's subtotal",
+			}
+			lines[i] = line
+			continue
+		}
+		line.Discount = item.Discount
+		line.Subtotal -= item.Discount
+
+		reserved, err := reservedStock(q, item.ProductID, outletID, exceptCartID)
+		if err != nil {
+			return nil, err
+		}
+		available := max(product.Stock-reserved-requested[item.ProductID], 0)
+		if line.Quantity > available {
+			line.Problem = &model.CheckoutProblem{
+				Line:      i,
+				ProductID: item.ProductID,
+				Barcode:   item.Barcode,
+				Code:      model.ProblemInsufficientStock,
+				Message:   "Insufficient stock",
+				Requested: line.Quantity,
+				Available: &available,
+			}
+		}
+		requested[item.ProductID] += line.Quantity
+		lines[i] = line
+	}
+	return lines, nil
 }
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"kasir-api/model"
+
+	"github.com/lib/pq"
+)
+
+var ErrUsernameTaken = errors.New("username is already taken")
+
+type UserRepository interface {
+	GetAll() ([]model.User, error)
+	GetByID(id int) (*model.User, error)
+	GetByUsername(username string) (*model.User, error)
+	Create(user *model.User) error
+	SetPIN(id int, pinHash string) error
+	Count() (int, error)
+}
+
+type userRepository struct {
+	db *sql.DB
+}
+
+func NewUserRepository(db *sql.DB) UserRepository {
+	return &userRepository{db: db}
+}
+
+const userColumns = "u.id, u.username, u.name, u.role, u.password_hash, u.pin_hash, u.active, u.created_at"
+
+func scanUser(row rowScanner) (*model.User, error) {
+	var u model.User
+	if err := row.Scan(&u.ID, &u.Username, &u.Name, &u.Role, &u.PasswordHash, &u.PINHash, &u.Active, &u.CreatedAt); err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (r *userRepository) queryUser(query string, args ...any) (*model.User, error) {
+	u, err := scanUser(r.db.QueryRow(query, args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return u, err
+}
+
+func (r *userRepository) GetAll() ([]model.User, error) {
+	rows, err := r.db.Query("SELECT " + userColumns + " FROM users u ORDER BY u.id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []model.User
+	for rows.Next() {
+		u, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *u)
+	}
+	return users, rows.Err()
+}
+
+func (r *userRepository) GetByID(id int) (*model.User, error) {
+	return r.queryUser("SELECT "+userColumns+" FROM users u WHERE u.id = $1", id)
+}
+
+// GetByUsername matches usernames case-insensitively.
+func (r *userRepository) GetByUsername(username string) (*model.User, error) {
+	return r.queryUser("SELECT "+userColumns+" FROM users u WHERE LOWER(u.username) = LOWER($1)", username)
+}
+
+func (r *userRepository) Create(user *model.User) error {
+	u, err := scanUser(r.db.QueryRow(
+		"INSERT INTO users AS u (username, name, role, password_hash, pin_hash) VALUES ($1, $2, $3, $4, $5) RETURNING "+userColumns,
+		user.Username, user.Name, user.Role, user.PasswordHash, user.PINHash,
+	))
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		return ErrUsernameTaken
+	}
+	if err != nil {
+		return err
+	}
+	*user = *u
+	return nil
+}
+
+func (r *userRepository) SetPIN(id int, pinHash string) error {
+	return execAffectingOne(r.db, "UPDATE users SET pin_hash = $1 WHERE id = $2", pinHash, id)
+}
+
+func (r *userRepository) Count() (int, error) {
+	var n int
+	err := r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n)
+	return n, err
+}
@@ -1,37 +1,450 @@
+-- Applied in one transaction, so that a database being migrated is either
+-- fully migrated or left as it was.
+BEGIN;
+
+-- Rejects changes to rows of append-only tables
+CREATE OR REPLACE FUNCTION reject_row_change() RETURNS trigger AS $$
+BEGIN
+    RAISE EXCEPTION '% is append-only', TG_TABLE_NAME;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Staff accounts; passwords are stored as bcrypt hashes
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    username VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    -- cashier, supervisor or owner; see model.DefaultRolePermissions
+    role VARCHAR(20) NOT NULL DEFAULT 'cashier',
+    password_hash VARCHAR(100) NOT NULL,
+    -- bcrypt hash of the PIN used to approve restricted actions; '' when unset
+    pin_hash VARCHAR(100) NOT NULL DEFAULT '',
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_username ON users (LOWER(username));
+
+-- Login sessions. Only a hash of the current refresh token is kept; access
+-- tokens carry the session ID, so revoking a session ends them as well.
+CREATE TABLE IF NOT EXISTS auth_sessions (
+    id SERIAL PRIMARY KEY,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    refresh_token_hash CHAR(64) NOT NULL UNIQUE,
+    expires_at TIMESTAMPTZ NOT NULL,
+    revoked_at TIMESTAMP,
+    last_used_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- API keys for integrations. Only a hash of the key is kept, with its first
+-- characters in prefix so that admins can tell keys apart. An empty
+-- allowed_ips lets the key be used from anywhere.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    prefix VARCHAR(16) NOT NULL,
+    key_hash CHAR(64) NOT NULL UNIQUE,
+    permissions TEXT[] NOT NULL,
+    allowed_ips TEXT[] NOT NULL DEFAULT '{}',
+    created_by INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP,
+    last_used_ip VARCHAR(45),
+    revoked_at TIMESTAMP
+);
+
+-- Step-up approvals. A challenge is created when a user attempts an action
+-- their role does not allow; a supervisor's PIN turns it into a one-time
+-- token for that action and resource. Rows are kept as the approval trail.
+CREATE TABLE IF NOT EXISTS approvals (
+    id CHAR(32) PRIMARY KEY,
+    action VARCHAR(50) NOT NULL,
+    resource_id INT NOT NULL,
+    requested_by INT NOT NULL REFERENCES users(id),
+    session_id INT NOT NULL REFERENCES auth_sessions(id) ON DELETE CASCADE,
+    failed_attempts INT NOT NULL DEFAULT 0,
+    approved_by INT REFERENCES users(id),
+    approved_at TIMESTAMP,
+    token_hash CHAR(64) UNIQUE,
+    used_at TIMESTAMP,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Stores of the business. The catalog is shared; stock and price overrides
+-- are held per outlet. Exactly one outlet is the default, used for terminals
+-- not assigned to any outlet and for the stock shown on products.
+CREATE TABLE IF NOT EXISTS outlets (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    address VARCHAR(255) NOT NULL DEFAULT '',
+    is_default BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_outlets_default ON outlets (is_default) WHERE is_default;
+
+-- Created here rather than with the sample data: the migrations below and
+-- everything selling without an assigned terminal need it.
+INSERT INTO outlets (name, is_default)
+SELECT 'Toko Utama', TRUE
+WHERE NOT EXISTS (SELECT 1 FROM outlets WHERE is_default);
+
+-- The outlet each terminal sells from
+CREATE TABLE IF NOT EXISTS terminals (
+    id VARCHAR(64) PRIMARY KEY,
+    outlet_id INT NOT NULL REFERENCES outlets(id)
+);
+
 CREATE TABLE IF NOT EXISTS categories (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
-    description TEXT
+    description TEXT,
+    parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    -- Archived (soft-deleted) when set
+    deleted_at TIMESTAMP,
+    -- Bumped on every write; exposed as the ETag for optimistic concurrency
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Migration for databases created from the original schema, whose tables
+-- CREATE TABLE IF NOT EXISTS leaves as they were
+ALTER TABLE categories
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Groups product variants (size, flavour, ...) under a single parent
+CREATE TABLE IF NOT EXISTS parent_products (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL
 );
 
 CREATE TABLE IF NOT EXISTS products (
     id SERIAL PRIMARY KEY,
     name VARCHAR(255) NOT NULL,
+    sku VARCHAR(64) UNIQUE,
+    barcode VARCHAR(64) UNIQUE,
     price INTEGER NOT NULL DEFAULT 0,
+    cost INTEGER NOT NULL DEFAULT 0,
+    -- Weighted products are priced per kg/litre; stock is in grams/millilitres
+    is_weighted BOOLEAN NOT NULL DEFAULT FALSE,
+    plu_code VARCHAR(5) UNIQUE,
+    parent_id INTEGER REFERENCES parent_products(id) ON DELETE SET NULL,
+    attributes JSONB NOT NULL DEFAULT '{}',
+    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL,
+    -- Archived (soft-deleted) when set
+    deleted_at TIMESTAMP,
+    -- Bumped on every write; exposed as the ETag for optimistic concurrency
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+-- Migration for databases created from the original schema, as for
+-- categories
+ALTER TABLE products
+    ADD COLUMN IF NOT EXISTS sku VARCHAR(64) UNIQUE,
+    ADD COLUMN IF NOT EXISTS barcode VARCHAR(64) UNIQUE,
+    ADD COLUMN IF NOT EXISTS cost INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS is_weighted BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN IF NOT EXISTS plu_code VARCHAR(5) UNIQUE,
+    ADD COLUMN IF NOT EXISTS parent_id INTEGER REFERENCES parent_products(id) ON DELETE SET NULL,
+    ADD COLUMN IF NOT EXISTS attributes JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS version INTEGER NOT NULL DEFAULT 1;
+
+-- Stock of each product at each outlet; a missing row means none
+CREATE TABLE IF NOT EXISTS outlet_stock (
+    outlet_id INT NOT NULL REFERENCES outlets(id),
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
     stock INTEGER NOT NULL DEFAULT 0,
-    category_id INTEGER REFERENCES categories(id) ON DELETE SET NULL
+    PRIMARY KEY (outlet_id, product_id)
+);
+
+-- Migration for databases from before outlets, which kept stock in
+-- products.stock: the stock moves to the default outlet, and the column is
+-- dropped only once it has been copied.
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM information_schema.columns
+               WHERE table_schema = current_schema() AND table_name = 'products' AND column_name = 'stock') THEN
+        INSERT INTO outlet_stock (outlet_id, product_id, stock)
+        SELECT (SELECT id FROM outlets WHERE is_default), id, stock FROM products
+        ON CONFLICT (outlet_id, product_id) DO NOTHING;
+        ALTER TABLE products DROP COLUMN stock;
+    END IF;
+END $$;
+
+-- Prices an outlet charges instead of the product's catalog price
+CREATE TABLE IF NOT EXISTS outlet_prices (
+    outlet_id INT NOT NULL REFERENCES outlets(id),
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    PRIMARY KEY (outlet_id, product_id)
+);
+
+-- Price changes scheduled ahead of time, applied by a background job
+CREATE TABLE IF NOT EXISTS scheduled_price_changes (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    price INT NOT NULL,
+    -- With time zone: clients schedule an absolute instant
+    effective_at TIMESTAMPTZ NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    created_by VARCHAR(100) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    applied_at TIMESTAMP,
+    cancelled_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_scheduled_price_changes_due ON scheduled_price_changes (effective_at) WHERE status = 'pending';
+
+-- Every change to a product's price, whether manual, imported or scheduled
+CREATE TABLE IF NOT EXISTS product_price_history (
+    id SERIAL PRIMARY KEY,
+    product_id INT NOT NULL REFERENCES products(id) ON DELETE CASCADE,
+    old_price INT NOT NULL,
+    new_price INT NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    changed_by VARCHAR(100) NOT NULL DEFAULT '',
+    scheduled_change_id INT REFERENCES scheduled_price_changes(id) ON DELETE SET NULL,
+    -- Set for the price charged at one outlet; NULL for the catalog price
+    outlet_id INT REFERENCES outlets(id) ON DELETE CASCADE,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
 );
 
+ALTER TABLE product_price_history ADD COLUMN IF NOT EXISTS outlet_id INT REFERENCES outlets(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_product_price_history_product ON product_price_history (product_id, changed_at);
+
+-- Cashier shifts at a terminal's cash drawer. The cash totals are filled in
+-- when the shift closes; until then they are computed from its sales and
+-- cash events.
+CREATE TABLE IF NOT EXISTS shifts (
+    id SERIAL PRIMARY KEY,
+    cashier_id INT NOT NULL REFERENCES users(id),
+    terminal_id VARCHAR(64) NOT NULL,
+    opening_float INT NOT NULL CHECK (opening_float >= 0),
+    opened_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    closed_at TIMESTAMP,
+    closed_by INT REFERENCES users(id),
+    counted_cash INT,
+    cash_sales INT,
+    cash_in INT,
+    cash_out INT,
+    note VARCHAR(255) NOT NULL DEFAULT ''
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_cashier ON shifts (cashier_id) WHERE closed_at IS NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shifts_open_terminal ON shifts (terminal_id) WHERE closed_at IS NULL;
+
+-- Cash put into or taken out of a shift's drawer outside of sales
+CREATE TABLE IF NOT EXISTS shift_cash_events (
+    id SERIAL PRIMARY KEY,
+    shift_id INT NOT NULL REFERENCES shifts(id),
+    type VARCHAR(20) NOT NULL,
+    amount INT NOT NULL CHECK (amount > 0),
+    reason VARCHAR(255) NOT NULL,
+    created_by INT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_shift_cash_events_shift ON shift_cash_events (shift_id);
+
 CREATE TABLE IF NOT EXISTS transactions (
     id SERIAL PRIMARY KEY,
     total_amount INT NOT NULL,
-    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+    payment_method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    paid_amount INT NOT NULL DEFAULT 0,
+    change_amount INT NOT NULL DEFAULT 0,
+    -- Who made the sale, on which terminal and at which outlet
+    cashier_id INT REFERENCES users(id),
+    terminal_id VARCHAR(64) NOT NULL DEFAULT '',
+    outlet_id INT NOT NULL REFERENCES outlets(id),
+    shift_id INT REFERENCES shifts(id),
+    -- The supervisor who approved the sale's price overrides and discounts
+    discount_approved_by INT REFERENCES users(id),
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    -- Set when the sale is voided; voided sales are left out of reports
+    voided_at TIMESTAMP,
+    voided_by INT REFERENCES users(id),
+    void_approved_by INT REFERENCES users(id),
+    void_reason TEXT NOT NULL DEFAULT ''
 );
 
+-- Migration for databases created from the original schema, as for
+-- categories
+ALTER TABLE transactions
+    ADD COLUMN IF NOT EXISTS payment_method VARCHAR(20) NOT NULL DEFAULT 'cash',
+    ADD COLUMN IF NOT EXISTS paid_amount INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS change_amount INT NOT NULL DEFAULT 0,
+    ADD COLUMN IF NOT EXISTS cashier_id INT REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS terminal_id VARCHAR(64) NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS shift_id INT REFERENCES shifts(id),
+    ADD COLUMN IF NOT EXISTS discount_approved_by INT REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS voided_at TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS voided_by INT REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS void_approved_by INT REFERENCES users(id),
+    ADD COLUMN IF NOT EXISTS void_reason TEXT NOT NULL DEFAULT '';
+
+-- Migration for databases from before outlets: earlier sales were all made
+-- at what is now the default outlet.
+ALTER TABLE transactions ADD COLUMN IF NOT EXISTS outlet_id INT REFERENCES outlets(id);
+UPDATE transactions SET outlet_id = (SELECT id FROM outlets WHERE is_default) WHERE outlet_id IS NULL;
+ALTER TABLE transactions ALTER COLUMN outlet_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_transactions_cashier ON transactions (cashier_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_transactions_shift ON transactions (shift_id);
+CREATE INDEX IF NOT EXISTS idx_transactions_outlet ON transactions (outlet_id, created_at);
+
 CREATE TABLE IF NOT EXISTS transaction_details (
     id SERIAL PRIMARY KEY,
     transaction_id INT REFERENCES transactions(id) ON DELETE CASCADE,
     product_id INT REFERENCES products(id),
+    -- Unit price at the time of sale (per kg/litre for weighted products),
+    -- and the outlet's price when the cashier overrode it
+    price INT NOT NULL DEFAULT 0,
+    regular_price INT,
     quantity INT NOT NULL,
+    -- Taken off price × quantity to give the subtotal
+    discount INT NOT NULL DEFAULT 0,
     subtotal INT NOT NULL
 );
 
-INSERT INTO categories (name, description) VALUES 
-    ('Makanan', 'Produk makanan dan snack'),
-    ('Minuman', 'Produk minuman'),
-    ('Bumbu Dapur', 'Produk bumbu masak');
+-- Migration for databases created from the original schema, as for
+-- categories. Their sales were all of unit-priced products.
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM information_schema.columns
+                   WHERE table_schema = current_schema() AND table_name = 'transaction_details' AND column_name = 'price') THEN
+        ALTER TABLE transaction_details ADD COLUMN price INT NOT NULL DEFAULT 0;
+        UPDATE transaction_details SET price = subtotal / quantity WHERE quantity > 0;
+    END IF;
+END $$;
+
+ALTER TABLE transaction_details
+    ADD COLUMN IF NOT EXISTS regular_price INT,
+    ADD COLUMN IF NOT EXISTS discount INT NOT NULL DEFAULT 0;
+
+-- Z-reports closing the business day. The report is stored as generated
+-- and never changed.
+CREATE TABLE IF NOT EXISTS z_reports (
+    number INT PRIMARY KEY,
+    period_start TIMESTAMP,
+    period_end TIMESTAMP NOT NULL,
+    generated_by INT NOT NULL REFERENCES users(id),
+    report JSONB NOT NULL
+);
+
+DROP TRIGGER IF EXISTS z_reports_immutable ON z_reports;
+CREATE TRIGGER z_reports_immutable BEFORE UPDATE OR DELETE ON z_reports
+    FOR EACH ROW EXECUTE FUNCTION reject_row_change();
+
+-- Server-side carts that can be parked and resumed. A parked cart holds its
+-- stock only while reserved_until is in the future.
+CREATE TABLE IF NOT EXISTS carts (
+    id SERIAL PRIMARY KEY,
+    terminal_id VARCHAR(64) NOT NULL,
+    -- The outlet whose stock and prices the cart uses
+    outlet_id INT NOT NULL REFERENCES outlets(id),
+    label VARCHAR(255) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'open',
+    reserved_until TIMESTAMP,
+    transaction_id INT REFERENCES transactions(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- Migration for databases from before outlets, as for transactions
+ALTER TABLE carts ADD COLUMN IF NOT EXISTS outlet_id INT REFERENCES outlets(id);
+UPDATE carts SET outlet_id = (SELECT id FROM outlets WHERE is_default) WHERE outlet_id IS NULL;
+ALTER TABLE carts ALTER COLUMN outlet_id SET NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_carts_terminal_status ON carts (terminal_id, status);
+
+CREATE TABLE IF NOT EXISTS cart_items (
+    cart_id INT NOT NULL REFERENCES carts(id) ON DELETE CASCADE,
+    product_id INT NOT NULL REFERENCES products(id),
+    quantity INT NOT NULL CHECK (quantity > 0),
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (cart_id, product_id)
+);
+
+-- Digital receipts queued for sending; retried with backoff while pending
+CREATE TABLE IF NOT EXISTS receipt_deliveries (
+    id SERIAL PRIMARY KEY,
+    transaction_id INT NOT NULL REFERENCES transactions(id) ON DELETE CASCADE,
+    channel VARCHAR(20) NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at TIMESTAMP,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_receipt_deliveries_due ON receipt_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- Who changed what. Entries are only ever added.
+CREATE TABLE IF NOT EXISTS audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    actor_id INT REFERENCES users(id),
+    actor_name VARCHAR(64) NOT NULL DEFAULT '',
+    action VARCHAR(20) NOT NULL,
+    entity_type VARCHAR(20) NOT NULL,
+    entity_id INT NOT NULL,
+    changes JSONB NOT NULL,
+    request_id VARCHAR(64) NOT NULL DEFAULT '',
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log (entity_type, entity_id);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log (actor_id, created_at);
+
+DROP TRIGGER IF EXISTS audit_log_append_only ON audit_log;
+CREATE TRIGGER audit_log_append_only BEFORE UPDATE OR DELETE ON audit_log
+    FOR EACH ROW EXECUTE FUNCTION reject_row_change();
+
+-- Sample data, for a database with an empty catalog
+DO $$
+BEGIN
+    IF NOT EXISTS (SELECT 1 FROM categories) AND NOT EXISTS (SELECT 1 FROM products) THEN
+        INSERT INTO categories (name, description) VALUES
+            ('Makanan', 'Produk makanan dan snack'),
+            ('Minuman', 'Produk minuman'),
+            ('Bumbu Dapur', 'Produk bumbu masak');
+
+        INSERT INTO parent_products (name, description, category_id) VALUES
+            ('Vit', 'Air mineral Vit', 2);
+
+        INSERT INTO products (name, price, category_id) VALUES
+            ('Indomie Goreng', 3500, 1),
+            ('Kecap ABC', 12000, 3);
+
+        INSERT INTO products (name, sku, price, parent_id, attributes, category_id) VALUES
+            ('Vit 1000ml', 'VIT-1000', 3000, 1, '{"size": "1000ml"}', 2),
+            ('Vit 600ml', 'VIT-600', 2500, 1, '{"size": "600ml"}', 2),
+            ('Vit 330ml', 'VIT-330', 2000, 1, '{"size": "330ml"}', 2);
+
+        INSERT INTO products (name, price, is_weighted, plu_code, category_id) VALUES
+            ('Gula Pasir Curah', 17500, TRUE, '00101', 3),
+            ('Minyak Goreng Curah', 16000, TRUE, '00102', 3);
+
+        INSERT INTO outlet_stock (outlet_id, product_id, stock)
+        SELECT o.id, p.id, s.stock
+        FROM outlets o, products p
+        JOIN (VALUES
+            ('Indomie Goreng', 10), ('Kecap ABC', 20),
+            ('Vit 1000ml', 40), ('Vit 600ml', 40), ('Vit 330ml', 40),
+            ('Gula Pasir Curah', 50000), ('Minyak Goreng Curah', 40000)
+        ) s (name, stock) ON s.name = p.name
+        WHERE o.is_default
+        ON CONFLICT (outlet_id, product_id) DO NOTHING;
+    END IF;
+END $$;
 
-INSERT INTO products (name, price, stock, category_id) VALUES 
-    ('Indomie Goreng', 3500, 10, 1),
-    ('Vit 1000ml', 3000, 40, 2),
-    ('Kecap ABC', 12000, 20, 3);
+COMMIT;
@@ -0,0 +1,174 @@
+package service
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"slices"
+	"strings"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+var ErrInvalidAPIKey = errors.New("invalid or revoked API key")
+var ErrIPNotAllowed = errors.New("API key is not allowed from this address")
+var ErrAPIKeyNotPermitted = errors.New("API key lacks the permission")
+
+// API keys read "kasir_" followed by 64 hex digits. The first
+// apiKeyPrefixLength characters are kept in the clear so that a key can be
+// recognised in the list without storing it.
+const (
+	apiKeyScheme       = "kasir_"
+	apiKeyPrefixLength = len(apiKeyScheme) + 8
+)
+
+// IsAPIKey reports whether token has the form of an API key rather than an
+// access token.
+func IsAPIKey(token string) bool {
+	return strings.HasPrefix(token, apiKeyScheme)
+}
+
+// APIKeyFingerprint identifies a key as presented, without looking it up,
+// so that its requests can be told apart before the key is checked.
+func APIKeyFingerprint(key string) string {
+	return hashToken(key)[:16]
+}
+
+type APIKeyService interface {
+	GetAll() ([]model.APIKey, error)
+	// Create issues a key for actor. The key itself is only ever returned
+	// here.
+	Create(req model.APIKeyRequest, actor model.Actor) (*model.CreatedAPIKey, error)
+	Revoke(id int) error
+	// Authenticate returns the active key presented from ip. It returns
+	// ErrInvalidAPIKey for unknown or revoked keys and ErrIPNotAllowed when
+	// ip is outside the key's allowlist.
+	Authenticate(key, ip string) (*model.APIKey, error)
+}
+
+type apiKeyService struct {
+	repo repository.APIKeyRepository
+}
+
+func NewAPIKeyService(repo repository.APIKeyRepository) APIKeyService {
+	return &apiKeyService{repo: repo}
+}
+
+func (s *apiKeyService) GetAll() ([]model.APIKey, error) {
+	return s.repo.GetAll()
+}
+
+func (s *apiKeyService) Create(req model.APIKeyRequest, actor model.Actor) (*model.CreatedAPIKey, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	v := &ValidationError{}
+	checkName(v, "name", req.Name)
+	if len(req.Permissions) == 0 {
+		v.Add("permissions", "must list at least one permission")
+	}
+	for _, p := range req.Permissions {
+		if !slices.Contains(model.APIKeyPermissions, p) {
+			v.Add("permissions", fmt.Sprintf("%q cannot be granted to an API key; use %s",
+				p, strings.Join(model.APIKeyPermissions, ", ")))
+		}
+	}
+	allowedIPs := make([]string, 0, len(req.AllowedIPs))
+	for _, entry := range req.AllowedIPs {
+		normalized, ok := normalizeIPEntry(entry)
+		if !ok {
+			v.Add("allowed_ips", fmt.Sprintf("%q is not an IP address or CIDR range", entry))
+			continue
+		}
+		allowedIPs = append(allowedIPs, normalized)
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	key := apiKeyScheme + hex.EncodeToString(b)
+
+	permissions := slices.Clone(req.Permissions)
+	slices.Sort(permissions)
+	slices.Sort(allowedIPs)
+	apiKey := &model.APIKey{
+		Name:        req.Name,
+		Prefix:      key[:apiKeyPrefixLength],
+		Permissions: slices.Compact(permissions),
+		AllowedIPs:  slices.Compact(allowedIPs),
+		CreatedBy:   actor.UserID,
+	}
+	if err := s.repo.Create(apiKey, hashToken(key)); err != nil {
+		return nil, err
+	}
+	return &model.CreatedAPIKey{APIKey: *apiKey, Key: key}, nil
+}
+
+func (s *apiKeyService) Revoke(id int) error {
+	return s.repo.Revoke(id)
+}
+
+func (s *apiKeyService) Authenticate(key, ip string) (*model.APIKey, error) {
+	if !IsAPIKey(key) {
+		return nil, ErrInvalidAPIKey
+	}
+	apiKey, err := s.repo.GetActive(hashToken(key))
+	if err != nil {
+		return nil, err
+	}
+	if apiKey == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	if len(apiKey.AllowedIPs) > 0 && !ipAllowed(apiKey.AllowedIPs, ip) {
+		return nil, ErrIPNotAllowed
+	}
+
+	// A failure to record the use must not turn the request away.
+	if err := s.repo.TouchLastUsed(apiKey.ID, ip); err != nil {
+		log.Printf("API key %d: failed to record last use: %v", apiKey.ID, err)
+	}
+	return apiKey, nil
+}
+
+// normalizeIPEntry returns the canonical form of an IP address or CIDR
+// range, e.g. "10.0.0.0/8" for "10.1.2.3/8".
+func normalizeIPEntry(entry string) (string, bool) {
+	entry = strings.TrimSpace(entry)
+	if strings.Contains(entry, "/") {
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return "", false
+		}
+		return network.String(), true
+	}
+	ip := net.ParseIP(entry)
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// ipAllowed reports whether ip is one of the allowed addresses or falls
+// within one of the allowed ranges.
+func ipAllowed(allowed []string, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(addr) {
+				return true
+			}
+		} else if allowedAddr := net.ParseIP(entry); allowedAddr != nil && allowedAddr.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,189 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+// Approval limits. A challenge must be approved soon after the attempt and
+// the resulting token used right away; a few wrong PINs close it. Wrong
+// PINs across challenges lock out the supervisor and the requester too.
+const (
+	challengeTTL        = 5 * time.Minute
+	approvalTokenTTL    = 2 * time.Minute
+	maxApprovalAttempts = 3
+	minPINLength        = 4
+	maxPINLength        = 8
+)
+
+var ErrChallengeNotFound = errors.New("approval challenge not found, expired or closed")
+var ErrInvalidPIN = errors.New("invalid supervisor or PIN")
+var ErrApproverNotPermitted = errors.New("supervisor is not allowed to approve this action")
+var ErrInvalidApproval = errors.New("approval token is invalid, expired or already used")
+
+// PINLockedError is returned when too many wrong PINs were entered for the
+// supervisor or by the requester. RetryAfter is how long until the next
+// attempt is accepted.
+type PINLockedError struct {
+	RetryAfter time.Duration
+}
+
+func (e *PINLockedError) Error() string {
+	return fmt.Sprintf("too many wrong PINs; retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// ApprovalRequiredError is returned when the actor may only perform an
+// action with a supervisor's approval. Challenge identifies the attempt.
+type ApprovalRequiredError struct {
+	Challenge model.ApprovalChallenge
+}
+
+func (e *ApprovalRequiredError) Error() string {
+	return fmt.Sprintf("%s on %d requires supervisor approval", e.Challenge.Action, e.Challenge.ResourceID)
+}
+
+// ApprovalService gates restricted cashier actions behind a supervisor's
+// PIN: voids, bound to the transaction, and price overrides and large
+// discounts at checkout, bound to the sale's lines.
+type ApprovalService interface {
+	// Authorize lets actor perform action, a permission name, on the given
+	// resource. Actors whose role grants the permission pass directly.
+	// Others must present an approval token for exactly this action and
+	// resource, which is used up and whose approver is returned; without
+	// one an *ApprovalRequiredError carrying a new challenge is returned.
+	// API keys cannot be approved and get ErrAPIKeyNotPermitted instead.
+	Authorize(actor model.Actor, action string, resourceID int, approvalToken string) (approvedBy *int, err error)
+	// Approve checks a supervisor's PIN against a challenge of actor's and
+	// issues the one-time approval token. Repeated wrong PINs for the
+	// supervisor or by actor, whatever the challenge, return a
+	// *PINLockedError for a while.
+	Approve(challengeID string, actor model.Actor, req model.ApprovalRequest) (*model.Approval, error)
+}
+
+type approvalService struct {
+	repo        repository.ApprovalRepository
+	users       repository.UserRepository
+	permissions model.RolePermissions
+	lockout     Lockout
+}
+
+func NewApprovalService(repo repository.ApprovalRepository, users repository.UserRepository,
+	permissions model.RolePermissions, lockout Lockout) ApprovalService {
+	return &approvalService{repo: repo, users: users, permissions: permissions, lockout: lockout}
+}
+
+func (s *approvalService) Authorize(actor model.Actor, action string, resourceID int, approvalToken string) (*int, error) {
+	if s.permissions.ActorAllows(actor, action) {
+		return nil, nil
+	}
+	// Approvals are tied to a login session, which API keys do not have.
+	if actor.APIKeyID != 0 {
+		return nil, ErrAPIKeyNotPermitted
+	}
+
+	if approvalToken != "" {
+		approvedBy, err := s.repo.Consume(hashToken(approvalToken), action, resourceID, actor.UserID, actor.SessionID)
+		if err == sql.ErrNoRows {
+			return nil, ErrInvalidApproval
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &approvedBy, nil
+	}
+
+	id, _, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	challenge := model.ApprovalChallenge{
+		ID:          id[:32],
+		Action:      action,
+		ResourceID:  resourceID,
+		RequestedBy: actor.UserID,
+		ExpiresAt:   time.Now().Add(challengeTTL),
+	}
+	if err := s.repo.Create(&challenge, actor.SessionID); err != nil {
+		return nil, err
+	}
+	return nil, &ApprovalRequiredError{Challenge: challenge}
+}
+
+func (s *approvalService) Approve(challengeID string, actor model.Actor, req model.ApprovalRequest) (*model.Approval, error) {
+	challenge, err := s.repo.GetOpen(challengeID, actor.SessionID, maxApprovalAttempts)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil || challenge.RequestedBy != actor.UserID {
+		return nil, ErrChallengeNotFound
+	}
+
+	// A new challenge is only a retry away, so wrong PINs are also counted
+	// against the supervisor being guessed at and the user guessing.
+	supervisorKey := "pin:supervisor:" + strings.ToLower(strings.TrimSpace(req.Supervisor))
+	requesterKey := "pin:requester:" + strconv.Itoa(actor.UserID)
+	if wait := max(s.lockout.Check(supervisorKey), s.lockout.Check(requesterKey)); wait > 0 {
+		return nil, &PINLockedError{RetryAfter: wait}
+	}
+
+	supervisor, err := s.users.GetByUsername(req.Supervisor)
+	if err != nil {
+		return nil, err
+	}
+	hash := dummyHash
+	if supervisor != nil && supervisor.PINHash != "" {
+		hash = []byte(supervisor.PINHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.PIN)) != nil || supervisor == nil || supervisor.PINHash == "" || !supervisor.Active {
+		s.lockout.Fail(supervisorKey)
+		s.lockout.Fail(requesterKey)
+		if err := s.repo.RecordFailure(challenge.ID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidPIN
+	}
+	s.lockout.Succeed(supervisorKey)
+	// Checked only once the PIN is right, so that it tells nothing about a
+	// supervisor to whoever does not know their PIN.
+	if supervisor.ID == actor.UserID || !s.permissions.Allows(supervisor.Role, challenge.Action) {
+		return nil, ErrApproverNotPermitted
+	}
+
+	token, tokenHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	expiresAt := time.Now().Add(approvalTokenTTL)
+	err = s.repo.Approve(challenge.ID, supervisor.ID, tokenHash, expiresAt, maxApprovalAttempts)
+	if err == sql.ErrNoRows {
+		return nil, ErrChallengeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.Approval{
+		ChallengeID: challenge.ID,
+		Action:      challenge.Action,
+		ResourceID:  challenge.ResourceID,
+		ApprovedBy:  supervisor.ID,
+		Token:       token,
+		ExpiresAt:   expiresAt,
+	}, nil
+}
+
+// checkPIN validates a new PIN: digits only, of a length a keypad can take.
+func checkPIN(v *ValidationError, field, pin string) {
+	if len(pin) < minPINLength || len(pin) > maxPINLength || !isDigits(pin) {
+		v.Add(field, fmt.Sprintf("must be %d to %d digits", minPINLength, maxPINLength))
+	}
+}
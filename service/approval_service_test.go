@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type fakeApprovalRepo struct {
+	repository.ApprovalRepository
+	failures int
+	approved bool
+}
+
+func (r *fakeApprovalRepo) GetOpen(id string, sessionID, maxAttempts int) (*model.ApprovalChallenge, error) {
+	return &model.ApprovalChallenge{ID: id, Action: model.PermTransactionsVoid, ResourceID: 1042, RequestedBy: 1}, nil
+}
+
+func (r *fakeApprovalRepo) RecordFailure(id string) error {
+	r.failures++
+	return nil
+}
+
+func (r *fakeApprovalRepo) Approve(id string, approvedBy int, tokenHash string, expiresAt time.Time, maxAttempts int) error {
+	r.approved = true
+	return nil
+}
+
+type fakeUserRepo struct {
+	repository.UserRepository
+	users map[string]*model.User
+}
+
+func (r fakeUserRepo) GetByUsername(username string) (*model.User, error) {
+	return r.users[username], nil
+}
+
+// fakeLockout never locks anything out and records the failed keys.
+type fakeLockout struct {
+	failed []string
+}
+
+func (l *fakeLockout) Check(key string) time.Duration { return 0 }
+
+func (l *fakeLockout) Fail(key string) time.Duration {
+	l.failed = append(l.failed, key)
+	return 0
+}
+
+func (l *fakeLockout) Succeed(key string) {}
+
+func TestApprove(t *testing.T) {
+	pinHash, err := bcrypt.GenerateFromPassword([]byte("1234"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	users := fakeUserRepo{users: map[string]*model.User{
+		"budi": {ID: 2, Username: "budi", Role: model.RoleSupervisor, PINHash: string(pinHash), Active: true},
+		"sari": {ID: 3, Username: "sari", Role: model.RoleCashier, PINHash: string(pinHash), Active: true},
+	}}
+	cashier := model.Actor{UserID: 1, Role: model.RoleCashier, SessionID: 9}
+
+	tests := []struct {
+		name       string
+		supervisor string
+		pin        string
+		wantErr    error
+		// wantFailed is whether the attempt counts against the challenge
+		// and against both the supervisor and the requester.
+		wantFailed bool
+	}{
+		{"approved", "budi", "1234", nil, false},
+		{"wrong PIN", "budi", "9999", ErrInvalidPIN, true},
+		{"unknown supervisor", "nobody", "1234", ErrInvalidPIN, true},
+		// Without the PIN nothing is told about whether sari may approve.
+		{"not permitted, wrong PIN", "sari", "9999", ErrInvalidPIN, true},
+		{"not permitted", "sari", "1234", ErrApproverNotPermitted, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &fakeApprovalRepo{}
+			lockout := &fakeLockout{}
+			s := NewApprovalService(repo, users, model.DefaultRolePermissions, lockout)
+
+			approval, err := s.Approve("challenge", cashier, model.ApprovalRequest{Supervisor: tt.supervisor, PIN: tt.pin})
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if failed := len(lockout.failed) == 2 && repo.failures == 1; failed != tt.wantFailed ||
+				!failed && (len(lockout.failed) != 0 || repo.failures != 0) {
+				t.Errorf("lockout failures = %v, challenge failures = %d; want counted: %v",
+					lockout.failed, repo.failures, tt.wantFailed)
+			}
+			if (approval != nil) != (tt.wantErr == nil) || repo.approved != (tt.wantErr == nil) {
+				t.Errorf("approval = %+v, approved = %v", approval, repo.approved)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type AuditService interface {
+	// Audit returns the repository.Audit that records actor making a write
+	// of the given action to an entity. Only the fields that differ between
+	// the entity as it was and as it is are kept. The repository writes the
+	// entry in the write's own transaction.
+	Audit(actor model.Actor, action, entityType string) repository.Audit
+	// GetAll lists entries, newest first. The limit defaults to 50 and is
+	// capped at 200.
+	GetAll(filter model.AuditFilter) ([]model.AuditEntry, error)
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+// unauditedFields change on every write and say nothing about it.
+var unauditedFields = map[string]bool{"version": true, "created_at": true, "updated_at": true}
+
+func (s *auditService) Audit(actor model.Actor, action, entityType string) repository.Audit {
+	return func(entityID int, before, after any) (*model.AuditEntry, error) {
+		changes, err := diffFields(before, after)
+		if err != nil {
+			return nil, err
+		}
+		entry := &model.AuditEntry{
+			ActorName:  actor.Name,
+			Action:     action,
+			EntityType: entityType,
+			EntityID:   entityID,
+			Changes:    changes,
+			RequestID:  actor.RequestID,
+			IP:         actor.IP,
+		}
+		if actor.UserID != 0 {
+			entry.ActorID = &actor.UserID
+		}
+		return entry, nil
+	}
+}
+
+func (s *auditService) GetAll(filter model.AuditFilter) ([]model.AuditEntry, error) {
+	v := &ValidationError{}
+	filter.Limit = checkPage(v, filter.Limit, filter.Offset)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(filter)
+}
+
+// diffFields compares the JSON representations of before and after field
+// by field.
+func diffFields(before, after any) (map[string]model.FieldChange, error) {
+	beforeFields, err := jsonFields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := jsonFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := map[string]model.FieldChange{}
+	for name, value := range afterFields {
+		if !unauditedFields[name] && !bytes.Equal(beforeFields[name], value) {
+			changes[name] = model.FieldChange{Before: beforeFields[name], After: value}
+		}
+	}
+	for name, value := range beforeFields {
+		if _, ok := afterFields[name]; !ok && !unauditedFields[name] {
+			changes[name] = model.FieldChange{Before: value}
+		}
+	}
+	return changes, nil
+}
+
+// jsonFields returns the top-level fields of v's JSON object, or none when
+// v is nil.
+func jsonFields(v any) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	err = json.Unmarshal(data, &fields)
+	return fields, err
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"testing"
+
+	"kasir-api/model"
+)
+
+func TestAuditKeepsChangedFields(t *testing.T) {
+	actor := model.Actor{UserID: 2, Name: "budi", RequestID: "req-1", IP: "10.0.0.5"}
+	audit := NewAuditService(nil).Audit(actor, model.AuditUpdate, model.EntityCategory)
+
+	before := &model.Category{ID: 7, Name: "Minuman", Description: "Dingin", Version: 3}
+	after := &model.Category{ID: 7, Name: "Minuman", Description: "Dingin dan panas", Version: 4}
+	entry, err := audit(7, before, after)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.ActorID == nil || *entry.ActorID != 2 || entry.ActorName != "budi" || entry.RequestID != "req-1" ||
+		entry.Action != model.AuditUpdate || entry.EntityType != model.EntityCategory || entry.EntityID != 7 {
+		t.Errorf("entry = %+v, want update of category 7 by budi", entry)
+	}
+	// The version changes on every write and is left out.
+	change, ok := entry.Changes["description"]
+	if len(entry.Changes) != 1 || !ok {
+		t.Fatalf("changes = %v, want only description", entry.Changes)
+	}
+	if string(change.Before) != `"Dingin"` || string(change.After) != `"Dingin dan panas"` {
+		t.Errorf("description changed from %s to %s", change.Before, change.After)
+	}
+}
+
+func TestAuditDeletedEntity(t *testing.T) {
+	audit := NewAuditService(nil).Audit(model.Actor{Name: "api-key"}, model.AuditDelete, model.EntityCategory)
+
+	var deleted *model.Category
+	entry, err := audit(7, &model.Category{ID: 7, Name: "Minuman"}, deleted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.ActorID != nil {
+		t.Errorf("actor ID = %d, want none for an actor without a user", *entry.ActorID)
+	}
+	if change := entry.Changes["name"]; string(change.Before) != `"Minuman"` || change.After != nil {
+		t.Errorf("name changed from %s to %s, want removed", change.Before, change.After)
+	}
+}
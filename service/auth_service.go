@@ -0,0 +1,200 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+var ErrInvalidToken = errors.New("token is invalid, expired or revoked")
+
+// ErrForgedToken is returned for an access token this server did not sign,
+// as opposed to one that expired or was revoked. It wraps ErrInvalidToken.
+var ErrForgedToken = fmt.Errorf("%w: bad signature", ErrInvalidToken)
+
+// AuthConfig configures token issuing. Secret signs access tokens and must
+// be kept private.
+type AuthConfig struct {
+	Secret          []byte
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+type AuthService interface {
+	// Login checks the credentials and starts a session.
+	Login(req model.LoginRequest) (*model.TokenPair, error)
+	// Refresh exchanges a refresh token for a new pair. The old refresh
+	// token stops working.
+	Refresh(refreshToken string) (*model.TokenPair, error)
+	// Logout ends the session, revoking its refresh token and every access
+	// token issued for it.
+	Logout(sessionID int) error
+	// Authenticate returns the user and session of a valid access token.
+	Authenticate(accessToken string) (*model.User, int, error)
+	// TokenUserID returns the ID of the user an access token was issued
+	// to, or 0 when it is not validly signed or has expired. Unlike
+	// Authenticate it does not look the session up.
+	TokenUserID(accessToken string) int
+}
+
+type authService struct {
+	users    repository.UserRepository
+	sessions repository.SessionRepository
+	cfg      AuthConfig
+}
+
+func NewAuthService(users repository.UserRepository, sessions repository.SessionRepository, cfg AuthConfig) AuthService {
+	return &authService{users: users, sessions: sessions, cfg: cfg}
+}
+
+// accessClaims are the claims of an access token. The session ID lets a
+// logout revoke tokens that have not expired yet.
+type accessClaims struct {
+	SessionID int `json:"sid"`
+	jwt.RegisteredClaims
+}
+
+// dummyHash is compared against when the username is unknown, so that a
+// failed login takes as long whether or not the user exists.
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("kasir-api"), bcrypt.DefaultCost)
+
+func (s *authService) Login(req model.LoginRequest) (*model.TokenPair, error) {
+	user, err := s.users.GetByUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	hash := dummyHash
+	if user != nil {
+		hash = []byte(user.PasswordHash)
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(req.Password)) != nil || user == nil || !user.Active {
+		return nil, ErrInvalidCredentials
+	}
+
+	refreshToken, refreshHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+	sessionID, err := s.sessions.Create(user.ID, refreshHash, time.Now().Add(s.cfg.RefreshTokenTTL))
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(user, sessionID, refreshToken)
+}
+
+func (s *authService) Refresh(refreshToken string) (*model.TokenPair, error) {
+	newToken, newHash, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	sessionID, user, err := s.sessions.Rotate(hashToken(refreshToken), newHash, time.Now().Add(s.cfg.RefreshTokenTTL))
+	if err == sql.ErrNoRows {
+		return nil, ErrInvalidToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s.issue(user, sessionID, newToken)
+}
+
+func (s *authService) Logout(sessionID int) error {
+	return s.sessions.Revoke(sessionID)
+}
+
+func (s *authService) Authenticate(accessToken string) (*model.User, int, error) {
+	claims, err := s.parse(accessToken)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	user, err := s.sessions.GetUser(claims.SessionID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if user == nil || claims.Subject != strconv.Itoa(user.ID) {
+		return nil, 0, ErrInvalidToken
+	}
+	return user, claims.SessionID, nil
+}
+
+func (s *authService) TokenUserID(accessToken string) int {
+	claims, err := s.parse(accessToken)
+	if err != nil {
+		return 0
+	}
+	id, _ := strconv.Atoi(claims.Subject)
+	return id
+}
+
+// parse checks the access token's signature and expiry and returns its
+// claims.
+func (s *authService) parse(accessToken string) (*accessClaims, error) {
+	var claims accessClaims
+	_, err := jwt.ParseWithClaims(accessToken, &claims, func(*jwt.Token) (any, error) {
+		return s.cfg.Secret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrInvalidToken
+	case err != nil:
+		return nil, ErrForgedToken
+	}
+	return &claims, nil
+}
+
+// issue signs an access token for the session and pairs it with
+// refreshToken.
+func (s *authService) issue(user *model.User, sessionID int, refreshToken string) (*model.TokenPair, error) {
+	now := time.Now()
+	claims := accessClaims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   strconv.Itoa(user.ID),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.cfg.AccessTokenTTL)),
+		},
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.cfg.Secret)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.cfg.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newOpaqueToken returns a random opaque token and the hash under which
+// it is stored.
+func newOpaqueToken() (token, hash string, err error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+	token = hex.EncodeToString(b)
+	return token, hashToken(token), nil
+}
+
+// hashToken hashes a high-entropy token for storage. Unlike passwords such
+// tokens need no slow hash.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"fmt"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+// CartService manages server-side carts. Every mutation returns the cart as
+// it stands afterwards.
+type CartService interface {
+	Create(cart *model.Cart) error
+	GetByID(id int) (*model.Cart, error)
+	GetAll(filter model.CartFilter) ([]model.Cart, error)
+	AddItem(cartID, productID, quantity int) (*model.Cart, error)
+	SetItem(cartID, productID, quantity int) (*model.Cart, error)
+	RemoveItem(cartID, productID int) (*model.Cart, error)
+	Park(id int, req model.ParkCartRequest) (*model.Cart, error)
+	Resume(id int) (*model.Cart, error)
+	Cancel(id int) (*model.Cart, error)
+	Checkout(id int, req model.CheckoutRequest, actor model.Actor) (*model.Transaction, error)
+}
+
+type cartService struct {
+	repo                  repository.CartRepository
+	transactions          TransactionService
+	defaultReserveMinutes int
+	maxReserveMinutes     int
+}
+
+// NewCartService creates the service. Parked carts that ask for a
+// reservation without a duration hold their stock for
+// defaultReserveMinutes, and none may hold it for longer than
+// maxReserveMinutes.
+func NewCartService(repo repository.CartRepository, transactions TransactionService, defaultReserveMinutes, maxReserveMinutes int) CartService {
+	return &cartService{
+		repo:                  repo,
+		transactions:          transactions,
+		defaultReserveMinutes: defaultReserveMinutes,
+		maxReserveMinutes:     maxReserveMinutes,
+	}
+}
+
+func (s *cartService) Create(cart *model.Cart) error {
+	v := &ValidationError{}
+	checkTerminalID(v, cart.TerminalID)
+	if len(cart.Label) > maxNameLength {
+		v.Add("label", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	return s.repo.Create(cart)
+}
+
+func (s *cartService) GetByID(id int) (*model.Cart, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *cartService) GetAll(filter model.CartFilter) ([]model.Cart, error) {
+	return s.repo.GetAll(filter)
+}
+
+func (s *cartService) AddItem(cartID, productID, quantity int) (*model.Cart, error) {
+	v := &ValidationError{}
+	if productID <= 0 {
+		v.Add("product_id", "must be a valid product ID")
+	}
+	if quantity <= 0 {
+		v.Add("quantity", "must be greater than 0")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return s.afterUpdate(cartID, s.repo.AddItem(cartID, productID, quantity))
+}
+
+func (s *cartService) SetItem(cartID, productID, quantity int) (*model.Cart, error) {
+	if quantity < 0 {
+		return nil, NewFieldError("quantity", "must not be negative")
+	}
+	return s.afterUpdate(cartID, s.repo.SetItem(cartID, productID, quantity))
+}
+
+func (s *cartService) RemoveItem(cartID, productID int) (*model.Cart, error) {
+	return s.afterUpdate(cartID, s.repo.RemoveItem(cartID, productID))
+}
+
+func (s *cartService) Park(id int, req model.ParkCartRequest) (*model.Cart, error) {
+	v := &ValidationError{}
+	checkNotNegative(v, "reserve_minutes", req.ReserveMinutes)
+	if req.ReserveMinutes > s.maxReserveMinutes {
+		v.Add("reserve_minutes", fmt.Sprintf("must be at most %d", s.maxReserveMinutes))
+	}
+	if len(req.Label) > maxNameLength {
+		v.Add("label", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	reserveMinutes := 0
+	if req.Reserve {
+		reserveMinutes = req.ReserveMinutes
+		if reserveMinutes <= 0 {
+			reserveMinutes = min(s.defaultReserveMinutes, s.maxReserveMinutes)
+		}
+	}
+	return s.afterUpdate(id, s.repo.Park(id, req.Label, reserveMinutes))
+}
+
+func (s *cartService) Resume(id int) (*model.Cart, error) {
+	return s.afterUpdate(id, s.repo.Resume(id))
+}
+
+func (s *cartService) Cancel(id int) (*model.Cart, error) {
+	return s.afterUpdate(id, s.repo.Cancel(id))
+}
+
+// Checkout converts the cart into a transaction through the regular
+// checkout, so pricing, stock checks, approvals and receipts behave the
+// same. req.Items may give the cart's lines price overrides and discounts.
+func (s *cartService) Checkout(id int, req model.CheckoutRequest, actor model.Actor) (*model.Transaction, error) {
+	req.CartID = id
+	return s.transactions.Checkout(req, actor)
+}
+
+func (s *cartService) afterUpdate(id int, err error) (*model.Cart, error) {
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
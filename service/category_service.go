@@ -1,42 +1,201 @@
 package service
 
 import (
+	"database/sql"
+	"errors"
 	"kasir-api/model"
 	"kasir-api/repository"
 )
 
+var ErrCategoryCycle = errors.New("category cannot be moved under itself or its descendants")
+
 type CategoryService interface {
-	GetAll() ([]model.Category, error)
+	GetAll(includeArchived bool) ([]model.Category, error)
+	GetTree() ([]model.Category, error)
 	GetByID(id int) (*model.Category, error)
-	Create(category *model.Category) error
-	Update(id int, category *model.Category) error
-	Delete(id int) error
+	// Create, Update, Patch, Archive, Restore and Delete record the change
+	// in the audit log as made by actor.
+	Create(category *model.Category, actor model.Actor) error
+	Update(id, version int, category *model.Category, actor model.Actor) error
+	// Patch applies a JSON Merge Patch and returns the updated category.
+	Patch(id, version int, patch []byte, actor model.Actor) (*model.Category, error)
+	// Archive, Restore and Delete, like Update and Patch, fail with
+	// repository.ErrVersionConflict unless version is 0 or current.
+	Archive(id, version int, actor model.Actor) error
+	Restore(id, version int, actor model.Actor) error
+	Delete(id, version int, actor model.Actor) error
 }
 
 type categoryService struct {
-	repo repository.CategoryRepository
+	repo  repository.CategoryRepository
+	audit AuditService
+}
+
+func NewCategoryService(repo repository.CategoryRepository, audit AuditService) CategoryService {
+	return &categoryService{repo: repo, audit: audit}
 }
 
-func NewCategoryService(repo repository.CategoryRepository) CategoryService {
-	return &categoryService{repo: repo}
+func (s *categoryService) GetAll(includeArchived bool) ([]model.Category, error) {
+	return s.repo.GetAll(includeArchived)
 }
 
-func (s *categoryService) GetAll() ([]model.Category, error) {
-	return s.repo.GetAll()
+// GetTree returns the root categories with their descendants nested in
+// Children.
+func (s *categoryService) GetTree() ([]model.Category, error) {
+	categories, err := s.repo.GetAll(false)
+	if err != nil {
+		return nil, err
+	}
+	return BuildCategoryTree(categories), nil
 }
 
 func (s *categoryService) GetByID(id int) (*model.Category, error) {
 	return s.repo.GetByID(id)
 }
 
-func (s *categoryService) Create(category *model.Category) error {
-	return s.repo.Create(category)
+func (s *categoryService) Create(category *model.Category, actor model.Actor) error {
+	if err := s.validate(0, category); err != nil {
+		return err
+	}
+	return s.repo.Create(category, s.audited(actor, model.AuditCreate))
+}
+
+func (s *categoryService) Update(id, version int, category *model.Category, actor model.Actor) error {
+	if err := s.validate(id, category); err != nil {
+		return err
+	}
+	return s.repo.Update(id, version, category, s.audited(actor, model.AuditUpdate))
+}
+
+// categoryPatchFields are the category members clients can write, keyed
+// by JSON name.
+var categoryPatchFields = map[string]patchField{
+	"name":        {Column: "name"},
+	"description": {Column: "description"},
+	"parent_id":   {Column: "parent_id", Nullable: true},
+}
+
+// CategoryFields lists the members a full category representation must
+// contain, as required by PUT.
+var CategoryFields = fieldNames(categoryPatchFields)
+
+// Patch validates the category as it would be after the patch and then
+// updates only the supplied columns.
+func (s *categoryService) Patch(id, version int, patch []byte, actor model.Actor) (*model.Category, error) {
+	current, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	if version != 0 && current.Version != version {
+		return nil, repository.ErrVersionConflict
+	}
+
+	category := *current
+	members, err := mergePatch(patch, &category, categoryPatchFields)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(id, &category); err != nil {
+		return nil, err
+	}
+
+	changes := patchColumns(members, categoryPatchFields, func(name string) any {
+		switch name {
+		case "name":
+			return category.Name
+		case "description":
+			return category.Description
+		default:
+			return category.ParentID
+		}
+	})
+	if _, err := s.repo.Patch(id, version, changes, s.audited(actor, model.AuditUpdate)); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// validate checks category's fields and its parent. Field errors are
+// reported together; a cycle is reported on its own as ErrCategoryCycle.
+func (s *categoryService) validate(id int, category *model.Category) error {
+	v := validateCategory(category)
+	if category.ParentID != nil {
+		if err := checkCategoryExists(v, s.repo, "parent_id", *category.ParentID); err != nil {
+			return err
+		}
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	return s.checkCycle(id, category.ParentID)
+}
+
+func (s *categoryService) Archive(id, version int, actor model.Actor) error {
+	return s.repo.Archive(id, version, s.audited(actor, model.AuditArchive))
 }
 
-func (s *categoryService) Update(id int, category *model.Category) error {
-	return s.repo.Update(id, category)
+func (s *categoryService) Restore(id, version int, actor model.Actor) error {
+	return s.repo.Restore(id, version, s.audited(actor, model.AuditRestore))
 }
 
-func (s *categoryService) Delete(id int) error {
-	return s.repo.Delete(id)
+func (s *categoryService) Delete(id, version int, actor model.Actor) error {
+	return s.repo.Delete(id, version, s.audited(actor, model.AuditDelete))
+}
+
+// audited records a write to a category as action by actor.
+func (s *categoryService) audited(actor model.Actor, action string) repository.Audit {
+	return s.audit.Audit(actor, action, model.EntityCategory)
+}
+
+// checkCycle verifies that attaching category id to parentID would not
+// create a cycle. id is 0 for categories not yet created.
+func (s *categoryService) checkCycle(id int, parentID *int) error {
+	if parentID == nil || id == 0 {
+		return nil
+	}
+	if *parentID == id {
+		return ErrCategoryCycle
+	}
+
+	descendants, err := s.repo.GetDescendantIDs(id)
+	if err != nil {
+		return err
+	}
+	for _, descendantID := range descendants {
+		if descendantID == *parentID {
+			return ErrCategoryCycle
+		}
+	}
+	return nil
+}
+
+// BuildCategoryTree nests a flat category list by ParentID. Categories whose
+// parent is not in the list are treated as roots.
+func BuildCategoryTree(categories []model.Category) []model.Category {
+	children := map[int][]model.Category{}
+	known := map[int]bool{}
+	for _, c := range categories {
+		known[c.ID] = true
+	}
+
+	var roots []model.Category
+	for _, c := range categories {
+		if c.ParentID != nil && known[*c.ParentID] {
+			children[*c.ParentID] = append(children[*c.ParentID], c)
+		} else {
+			roots = append(roots, c)
+		}
+	}
+
+	var attach func(nodes []model.Category) []model.Category
+	attach = func(nodes []model.Category) []model.Category {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].ID])
+		}
+		return nodes
+	}
+	return attach(roots)
 }
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type ClosingService interface {
+	// GetXReport totals the day so far for one terminal, or all of them
+	// when terminalID is "", at one outlet, or all of them when outletID is
+	// 0. Nothing is stored or reset.
+	GetXReport(terminalID string, outletID int) (*model.ClosingReport, error)
+	// CloseDay generates and stores the next Z-report. The following
+	// reports start where it ends.
+	CloseDay(actor model.Actor) (*model.ClosingReport, error)
+	GetZReports() ([]model.ClosingReport, error)
+	GetZReport(number int) (*model.ClosingReport, error)
+}
+
+type closingService struct {
+	repo    repository.ClosingRepository
+	taxRate int
+}
+
+// NewClosingService creates the service. Prices include tax at taxRate
+// percent, which reports break out of net sales.
+func NewClosingService(repo repository.ClosingRepository, taxRate int) ClosingService {
+	return &closingService{repo: repo, taxRate: taxRate}
+}
+
+func (s *closingService) GetXReport(terminalID string, outletID int) (*model.ClosingReport, error) {
+	if len(terminalID) > maxTerminalIDLength {
+		v := &ValidationError{}
+		checkTerminalID(v, terminalID)
+		return nil, v.Err()
+	}
+
+	report, err := s.repo.GetXReport(terminalID, outletID)
+	if err != nil {
+		return nil, err
+	}
+	s.addTax(report)
+	return report, nil
+}
+
+func (s *closingService) CloseDay(actor model.Actor) (*model.ClosingReport, error) {
+	return s.repo.CreateZReport(actor.UserID, s.addTax)
+}
+
+func (s *closingService) GetZReports() ([]model.ClosingReport, error) {
+	return s.repo.GetZReports()
+}
+
+func (s *closingService) GetZReport(number int) (*model.ClosingReport, error) {
+	return s.repo.GetZReport(number)
+}
+
+// addTax fills in the tax included in the report's net sales, rounded
+// down.
+func (s *closingService) addTax(report *model.ClosingReport) {
+	report.TaxRate = s.taxRate
+	report.Tax = report.NetSales * s.taxRate / (100 + s.taxRate)
+}
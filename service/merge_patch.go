@@ -0,0 +1,79 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"sort"
+)
+
+var errPatchNotObject = errors.New("merge patch must be a JSON object")
+
+// patchField describes a member a merge patch may set. Column is the
+// database column it maps to; Nullable members may be removed with null.
+type patchField struct {
+	Column   string
+	Nullable bool
+}
+
+// mergePatch applies a JSON Merge Patch (RFC 7396) to target, a pointer to
+// the current representation. Only the members listed in fields may appear
+// in patch. It returns the supplied members, keyed by name, so the caller
+// can update just those columns and handle nested objects itself.
+func mergePatch(patch []byte, target any, fields map[string]patchField) (map[string]json.RawMessage, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(patch, &members); err != nil || members == nil {
+		return nil, NewFieldError("body", errPatchNotObject.Error())
+	}
+
+	v := &ValidationError{}
+	names := make([]string, 0, len(members))
+	for name := range members {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		field, ok := fields[name]
+		switch {
+		case !ok:
+			v.Add(name, "cannot be changed")
+		case isJSONNull(members[name]) && !field.Nullable:
+			v.Add(name, "must not be null")
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(patch, target); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) && typeErr.Field != "" {
+			return nil, NewFieldError(typeErr.Field, "has the wrong type")
+		}
+		return nil, err
+	}
+	return members, nil
+}
+
+// patchColumns returns the column for each supplied member, with its value
+// looked up by value.
+func patchColumns(members map[string]json.RawMessage, fields map[string]patchField, value func(name string) any) map[string]any {
+	changes := make(map[string]any, len(members))
+	for name := range members {
+		changes[fields[name].Column] = value(name)
+	}
+	return changes
+}
+
+func isJSONNull(raw json.RawMessage) bool {
+	return bytes.Equal(bytes.TrimSpace(raw), []byte("null"))
+}
+
+func fieldNames(fields map[string]patchField) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type OutletService interface {
+	GetAll() ([]model.Outlet, error)
+	GetByID(id int) (*model.Outlet, error)
+	Create(req model.OutletRequest) (*model.Outlet, error)
+	Update(id int, req model.OutletRequest) (*model.Outlet, error)
+	AssignTerminal(outletID int, terminalID string) error
+	UnassignTerminal(outletID int, terminalID string) error
+	GetProducts(outletID int) ([]model.OutletProduct, error)
+	// SetStock, SetPrice and DeletePrice record the change in the audit log
+	// as made by actor. SetPrice and DeletePrice also record it in the
+	// product's price history.
+	SetStock(outletID, productID int, req model.OutletStockRequest, actor model.Actor) error
+	SetPrice(outletID, productID int, req model.OutletPriceRequest, actor model.Actor) error
+	DeletePrice(outletID, productID int, actor model.Actor) error
+}
+
+type outletService struct {
+	repo  repository.OutletRepository
+	audit AuditService
+}
+
+func NewOutletService(repo repository.OutletRepository, audit AuditService) OutletService {
+	return &outletService{repo: repo, audit: audit}
+}
+
+func (s *outletService) GetAll() ([]model.Outlet, error) {
+	return s.repo.GetAll()
+}
+
+func (s *outletService) GetByID(id int) (*model.Outlet, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *outletService) Create(req model.OutletRequest) (*model.Outlet, error) {
+	if err := validateOutlet(req); err != nil {
+		return nil, err
+	}
+
+	outlet := &model.Outlet{Name: strings.TrimSpace(req.Name), Address: strings.TrimSpace(req.Address)}
+	if err := s.repo.Create(outlet); err != nil {
+		return nil, err
+	}
+	return outlet, nil
+}
+
+func (s *outletService) Update(id int, req model.OutletRequest) (*model.Outlet, error) {
+	if err := validateOutlet(req); err != nil {
+		return nil, err
+	}
+
+	outlet := &model.Outlet{Name: strings.TrimSpace(req.Name), Address: strings.TrimSpace(req.Address)}
+	if err := s.repo.Update(id, outlet); err != nil {
+		return nil, err
+	}
+	return outlet, nil
+}
+
+func (s *outletService) AssignTerminal(outletID int, terminalID string) error {
+	v := &ValidationError{}
+	checkTerminalID(v, terminalID)
+	if err := v.Err(); err != nil {
+		return err
+	}
+	return s.repo.AssignTerminal(outletID, terminalID)
+}
+
+func (s *outletService) UnassignTerminal(outletID int, terminalID string) error {
+	return s.repo.UnassignTerminal(outletID, terminalID)
+}
+
+func (s *outletService) GetProducts(outletID int) ([]model.OutletProduct, error) {
+	return s.repo.GetProducts(outletID)
+}
+
+func (s *outletService) SetStock(outletID, productID int, req model.OutletStockRequest, actor model.Actor) error {
+	v := &ValidationError{}
+	if req.Stock == nil {
+		v.Add("stock", "is required")
+	} else {
+		checkNotNegative(v, "stock", *req.Stock)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	return s.repo.SetStock(outletID, productID, *req.Stock, s.audited(actor))
+}
+
+func (s *outletService) SetPrice(outletID, productID int, req model.OutletPriceRequest, actor model.Actor) error {
+	v := &ValidationError{}
+	if req.Price == nil {
+		v.Add("price", "is required")
+	} else {
+		checkNotNegative(v, "price", *req.Price)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	return s.repo.SetPrice(outletID, productID, *req.Price, actor.Name, s.audited(actor))
+}
+
+func (s *outletService) DeletePrice(outletID, productID int, actor model.Actor) error {
+	return s.repo.DeletePrice(outletID, productID, actor.Name, s.audited(actor))
+}
+
+// audited records a change to an outlet's stock or prices by actor.
+func (s *outletService) audited(actor model.Actor) repository.Audit {
+	return s.audit.Audit(actor, model.AuditUpdate, model.EntityOutlet)
+}
+
+func validateOutlet(req model.OutletRequest) error {
+	v := &ValidationError{}
+	checkName(v, "name", req.Name)
+	if utf8.RuneCountInString(req.Address) > maxAddressLength {
+		v.Add("address", fmt.Sprintf("must be at most %d characters", maxAddressLength))
+	}
+	return v.Err()
+}
@@ -0,0 +1,60 @@
+package service
+
+import (
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type ParentProductService interface {
+	GetAll(search string) ([]model.ParentProduct, error)
+	GetByID(id int) (*model.ParentProduct, error)
+	Create(parent *model.ParentProduct) error
+	Update(id int, parent *model.ParentProduct) error
+	Delete(id int) error
+}
+
+type parentProductService struct {
+	repo         repository.ParentProductRepository
+	categoryRepo repository.CategoryRepository
+}
+
+func NewParentProductService(repo repository.ParentProductRepository, categoryRepo repository.CategoryRepository) ParentProductService {
+	return &parentProductService{repo: repo, categoryRepo: categoryRepo}
+}
+
+func (s *parentProductService) GetAll(search string) ([]model.ParentProduct, error) {
+	return s.repo.GetAll(search)
+}
+
+func (s *parentProductService) GetByID(id int) (*model.ParentProduct, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *parentProductService) Create(parent *model.ParentProduct) error {
+	if err := s.validate(parent); err != nil {
+		return err
+	}
+	return s.repo.Create(parent)
+}
+
+func (s *parentProductService) Update(id int, parent *model.ParentProduct) error {
+	if err := s.validate(parent); err != nil {
+		return err
+	}
+	return s.repo.Update(id, parent)
+}
+
+func (s *parentProductService) validate(parent *model.ParentProduct) error {
+	v := &ValidationError{}
+	checkName(v, "name", parent.Name)
+	if parent.CategoryID != nil {
+		if err := checkCategoryExists(v, s.categoryRepo, "category_id", *parent.CategoryID); err != nil {
+			return err
+		}
+	}
+	return v.Err()
+}
+
+func (s *parentProductService) Delete(id int) error {
+	return s.repo.Delete(id)
+}
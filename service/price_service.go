@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+type PriceService interface {
+	// GetHistory lists the product's price changes, newest first.
+	GetHistory(productID int) ([]model.PriceChange, error)
+	GetScheduled(productID int) ([]model.ScheduledPriceChange, error)
+	// Schedule sets the product's price at req.EffectiveAt. The change is
+	// applied by Run and recorded in the price history as made by actor.
+	Schedule(productID int, req model.ScheduledPriceRequest, actor model.Actor) (*model.ScheduledPriceChange, error)
+	CancelScheduled(productID, id int) (*model.ScheduledPriceChange, error)
+	// Run applies scheduled changes as they fall due until ctx is
+	// cancelled.
+	Run(ctx context.Context)
+}
+
+type priceService struct {
+	repo         repository.PriceRepository
+	productRepo  repository.ProductRepository
+	pollInterval time.Duration
+}
+
+// NewPriceService creates the service. Run checks for due changes every
+// pollInterval, so a change takes effect at most that long after its time.
+func NewPriceService(repo repository.PriceRepository, productRepo repository.ProductRepository, pollInterval time.Duration) PriceService {
+	return &priceService{repo: repo, productRepo: productRepo, pollInterval: pollInterval}
+}
+
+func (s *priceService) GetHistory(productID int) ([]model.PriceChange, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetHistory(productID)
+}
+
+func (s *priceService) GetScheduled(productID int) ([]model.ScheduledPriceChange, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetScheduled(productID)
+}
+
+func (s *priceService) Schedule(productID int, req model.ScheduledPriceRequest, actor model.Actor) (*model.ScheduledPriceChange, error) {
+	if err := s.checkProduct(productID); err != nil {
+		return nil, err
+	}
+
+	v := &ValidationError{}
+	checkNotNegative(v, "price", req.Price)
+	switch {
+	case req.EffectiveAt.IsZero():
+		v.Add("effective_at", "is required")
+	case !req.EffectiveAt.After(time.Now()):
+		v.Add("effective_at", "must be in the future")
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	change := &model.ScheduledPriceChange{
+		ProductID:   productID,
+		Price:       req.Price,
+		EffectiveAt: req.EffectiveAt,
+		CreatedBy:   actor.Name,
+	}
+	if err := s.repo.Schedule(change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+func (s *priceService) CancelScheduled(productID, id int) (*model.ScheduledPriceChange, error) {
+	return s.repo.CancelScheduled(productID, id)
+}
+
+// checkProduct returns sql.ErrNoRows when the product does not exist.
+func (s *priceService) checkProduct(productID int) error {
+	product, err := s.productRepo.GetByID(productID)
+	if err != nil {
+		return err
+	}
+	if product == nil {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *priceService) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.applyDue()
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *priceService) applyDue() {
+	for {
+		applied, err := s.repo.ApplyDue(10)
+		if err != nil {
+			log.Printf("Scheduled prices: failed to apply due changes: %v", err)
+			return
+		}
+		if len(applied) == 0 {
+			return
+		}
+
+		for _, c := range applied {
+			log.Printf("Scheduled prices: set product %d to %d (change %d)", c.ProductID, c.Price, c.ID)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"kasir-api/model"
+)
+
+// ProductFileColumns is the column layout used for product export and
+// expected (in any order) in the header row of an import file. Stock is the
+// stock at the default outlet.
+var ProductFileColumns = []string{"name", "sku", "barcode", "category", "price", "cost", "stock"}
+
+var requiredImportColumns = []string{"name", "sku", "price"}
+
+// Import validates the records of an import file, the first of which must
+// be the header row, and upserts the valid rows by SKU. Nothing is written
+// when dryRun is set or when any row is invalid; the result then lists every
+// problem found.
+func (s *productService) Import(records [][]string, dryRun bool, actor model.Actor) (*model.ProductImportResult, error) {
+	result := &model.ProductImportResult{DryRun: dryRun, Errors: []model.ImportRowError{}}
+	if len(records) == 0 {
+		result.Errors = append(result.Errors, model.ImportRowError{Row: 1, Message: "file is empty"})
+		return result, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range requiredImportColumns {
+		if _, ok := columns[name]; !ok {
+			result.Errors = append(result.Errors, model.ImportRowError{Row: 1, Field: name, Message: "missing column"})
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	var rows []model.ProductImportRow
+	var rowErrors []model.ImportRowError
+	seenSKU := map[string]int{}
+	for i, record := range records[1:] {
+		if isBlankRecord(record) {
+			continue
+		}
+		row, errs := parseImportRecord(i+2, record, columns)
+		if row.SKU != "" {
+			if first, ok := seenSKU[row.SKU]; ok {
+				errs = append(errs, model.ImportRowError{Row: row.Row, Field: "sku", Message: fmt.Sprintf("duplicate of row %d", first)})
+			} else {
+				seenSKU[row.SKU] = row.Row
+			}
+		}
+		if len(errs) > 0 {
+			rowErrors = append(rowErrors, errs...)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	// Valid rows still go through the repository so that a dry run (or a
+	// rejected import) also reports database-level problems.
+	imported, err := s.repo.Import(rows, dryRun || len(rowErrors) > 0, actor.Name, s.audited(actor, model.AuditImport))
+	if err != nil {
+		return nil, err
+	}
+	imported.DryRun = dryRun
+	imported.TotalRows = len(rows) + countRows(rowErrors)
+	imported.Errors = append(rowErrors, imported.Errors...)
+	return imported, nil
+}
+
+// ExportRecords renders products in the ProductFileColumns layout, header
+// row first, so that an export can be edited and imported again.
+func ExportRecords(products []model.Product) [][]string {
+	records := [][]string{ProductFileColumns}
+	for _, p := range products {
+		var category string
+		if p.Category != nil {
+			category = p.Category.Name
+		}
+		records = append(records, []string{
+			p.Name,
+			stringValue(p.SKU),
+			stringValue(p.Barcode),
+			category,
+			strconv.Itoa(p.Price),
+			strconv.Itoa(p.Cost),
+			strconv.Itoa(p.Stock),
+		})
+	}
+	return records
+}
+
+func parseImportRecord(rowNum int, record []string, columns map[string]int) (model.ProductImportRow, []model.ImportRowError) {
+	row := model.ProductImportRow{Row: rowNum}
+	var errs []model.ImportRowError
+
+	field := func(name string) string {
+		i, ok := columns[name]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+	number := func(name string, required bool) int {
+		value := field(name)
+		if value == "" {
+			if required {
+				errs = append(errs, model.ImportRowError{Row: rowNum, Field: name, Message: "is required"})
+			}
+			return 0
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			errs = append(errs, model.ImportRowError{Row: rowNum, Field: name, Message: "must be a whole number"})
+			return 0
+		}
+		if n < 0 {
+			errs = append(errs, model.ImportRowError{Row: rowNum, Field: name, Message: "must not be negative"})
+		}
+		return n
+	}
+
+	row.Name = field("name")
+	if row.Name == "" {
+		errs = append(errs, model.ImportRowError{Row: rowNum, Field: "name", Message: "is required"})
+	}
+	row.SKU = field("sku")
+	if row.SKU == "" {
+		errs = append(errs, model.ImportRowError{Row: rowNum, Field: "sku", Message: "is required"})
+	}
+	if barcode := field("barcode"); barcode != "" {
+		row.Barcode = &barcode
+	}
+	row.CategoryName = field("category")
+	row.Price = number("price", true)
+	row.Cost = number("cost", false)
+	row.Stock = number("stock", false)
+
+	return row, errs
+}
+
+func isBlankRecord(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// countRows returns the number of distinct rows mentioned in errs.
+func countRows(errs []model.ImportRowError) int {
+	seen := map[int]bool{}
+	for _, e := range errs {
+		seen[e.Row] = true
+	}
+	return len(seen)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -1,35 +1,54 @@
 package service
 
 import (
+	"database/sql"
+	"encoding/json"
+	"maps"
+
 	"kasir-api/model"
 	"kasir-api/repository"
 )
 
 type ProductService interface {
-	GetAll() ([]model.Product, error)
-	GetAllWithCategory() ([]model.Product, error)
+	GetAll(filter model.ProductFilter) ([]model.Product, error)
+	GetAllWithCategory(filter model.ProductFilter) ([]model.Product, error)
 	GetByID(id int) (*model.Product, error)
 	GetByIDWithCategory(id int) (*model.Product, error)
 	GetByCategoryID(categoryID int) ([]model.Product, error)
-	Create(product *model.Product) error
-	Update(id int, product *model.Product) error
-	Delete(id int) error
+	GetByCategoryTree(categoryID int) ([]model.Product, error)
+	// Create, Update, Patch, Archive, Restore, Delete and Import record
+	// the change in the audit log as made by actor. Update and Patch also
+	// record a price change in the price history.
+	Create(product *model.Product, actor model.Actor) error
+	Update(id, version int, product *model.Product, actor model.Actor) error
+	// Patch applies a JSON Merge Patch and returns the updated product.
+	Patch(id, version int, patch []byte, actor model.Actor) (*model.Product, error)
+	// Archive, Restore and Delete, like Update and Patch, fail with
+	// repository.ErrVersionConflict unless version is 0 or current.
+	Archive(id, version int, actor model.Actor) error
+	Restore(id, version int, actor model.Actor) error
+	Delete(id, version int, actor model.Actor) error
+	Import(records [][]string, dryRun bool, actor model.Actor) (*model.ProductImportResult, error)
 }
 
 type productService struct {
-	repo repository.ProductRepository
+	repo         repository.ProductRepository
+	categoryRepo repository.CategoryRepository
+	parentRepo   repository.ParentProductRepository
+	audit        AuditService
 }
 
-func NewProductService(repo repository.ProductRepository) ProductService {
-	return &productService{repo: repo}
+func NewProductService(repo repository.ProductRepository, categoryRepo repository.CategoryRepository,
+	parentRepo repository.ParentProductRepository, audit AuditService) ProductService {
+	return &productService{repo: repo, categoryRepo: categoryRepo, parentRepo: parentRepo, audit: audit}
 }
 
-func (s *productService) GetAll() ([]model.Product, error) {
-	return s.repo.GetAll()
+func (s *productService) GetAll(filter model.ProductFilter) ([]model.Product, error) {
+	return s.repo.GetAll(filter)
 }
 
-func (s *productService) GetAllWithCategory() ([]model.Product, error) {
-	return s.repo.GetAllWithCategory()
+func (s *productService) GetAllWithCategory(filter model.ProductFilter) ([]model.Product, error) {
+	return s.repo.GetAllWithCategory(filter)
 }
 
 func (s *productService) GetByID(id int) (*model.Product, error) {
@@ -44,14 +63,173 @@ func (s *productService) GetByCategoryID(categoryID int) ([]model.Product, error
 	return s.repo.GetByCategoryID(categoryID)
 }
 
-func (s *productService) Create(product *model.Product) error {
-	return s.repo.Create(product)
+func (s *productService) GetByCategoryTree(categoryID int) ([]model.Product, error) {
+	return s.repo.GetByCategoryTree(categoryID)
+}
+
+func (s *productService) Create(product *model.Product, actor model.Actor) error {
+	if err := s.validate(product); err != nil {
+		return err
+	}
+	return s.repo.Create(product, s.audited(actor, model.AuditCreate))
+}
+
+func (s *productService) Update(id, version int, product *model.Product, actor model.Actor) error {
+	if err := s.validate(product); err != nil {
+		return err
+	}
+	return s.repo.Update(id, version, product, actor.Name, s.audited(actor, model.AuditUpdate))
+}
+
+// productPatchFields are the product members clients can write, keyed by
+// JSON name.
+var productPatchFields = map[string]patchField{
+	"name":        {Column: "name"},
+	"sku":         {Column: "sku", Nullable: true},
+	"barcode":     {Column: "barcode", Nullable: true},
+	"price":       {Column: "price"},
+	"cost":        {Column: "cost"},
+	"stock":       {Column: "stock"},
+	"is_weighted": {Column: "is_weighted"},
+	"plu_code":    {Column: "plu_code", Nullable: true},
+	"parent_id":   {Column: "parent_id", Nullable: true},
+	"attributes":  {Column: "attributes", Nullable: true},
+	"category_id": {Column: "category_id", Nullable: true},
+}
+
+// ProductFields lists the members a full product representation must
+// contain, as required by PUT.
+var ProductFields = fieldNames(productPatchFields)
+
+// Patch validates the product as it would be after the patch and then
+// updates only the supplied columns. Attributes are merged key by key, so
+// {"attributes": {"size": null}} removes just that attribute.
+func (s *productService) Patch(id, version int, patch []byte, actor model.Actor) (*model.Product, error) {
+	current, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if current == nil {
+		return nil, sql.ErrNoRows
+	}
+	if version != 0 && current.Version != version {
+		return nil, repository.ErrVersionConflict
+	}
+
+	product := *current
+	product.Attributes = nil
+	members, err := mergePatch(patch, &product, productPatchFields)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, ok := members["attributes"]
+	switch {
+	case !ok:
+		product.Attributes = current.Attributes
+	case !isJSONNull(raw):
+		var attributePatch map[string]*string
+		if err := json.Unmarshal(raw, &attributePatch); err != nil {
+			return nil, NewFieldError("attributes", "must be an object of strings")
+		}
+		product.Attributes = maps.Clone(current.Attributes)
+		if product.Attributes == nil {
+			product.Attributes = map[string]string{}
+		}
+		for key, value := range attributePatch {
+			if value == nil {
+				delete(product.Attributes, key)
+			} else {
+				product.Attributes[key] = *value
+			}
+		}
+	}
+
+	if err := s.validate(&product); err != nil {
+		return nil, err
+	}
+
+	changes := patchColumns(members, productPatchFields, func(name string) any {
+		switch name {
+		case "name":
+			return product.Name
+		case "sku":
+			return product.SKU
+		case "barcode":
+			return product.Barcode
+		case "price":
+			return product.Price
+		case "cost":
+			return product.Cost
+		case "stock":
+			return product.Stock
+		case "is_weighted":
+			return product.IsWeighted
+		case "plu_code":
+			return product.PLUCode
+		case "parent_id":
+			return product.ParentID
+		case "attributes":
+			return product.Attributes
+		default:
+			return product.CategoryID
+		}
+	})
+	if _, err := s.repo.Patch(id, version, changes, actor.Name, s.audited(actor, model.AuditUpdate)); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// validate checks product's fields and that the category and parent product
+// it refers to exist, reporting every problem at once.
+func (s *productService) validate(product *model.Product) error {
+	v := validateProduct(product)
+	if product.CategoryID != nil {
+		if err := checkCategoryExists(v, s.categoryRepo, "category_id", *product.CategoryID); err != nil {
+			return err
+		}
+	}
+	if product.ParentID != nil {
+		parent, err := s.parentRepo.GetByID(*product.ParentID)
+		if err != nil {
+			return err
+		}
+		if parent == nil {
+			v.Add("parent_id", "parent product does not exist")
+		}
+	}
+	return v.Err()
+}
+
+// checkCategoryExists records a field error unless categoryID names a
+// category that is not archived.
+func checkCategoryExists(v *ValidationError, repo repository.CategoryRepository, field string, categoryID int) error {
+	category, err := repo.GetByID(categoryID)
+	if err != nil {
+		return err
+	}
+	if category == nil {
+		v.Add(field, "category does not exist")
+	} else if category.DeletedAt != nil {
+		v.Add(field, "category is archived")
+	}
+	return nil
+}
+
+func (s *productService) Archive(id, version int, actor model.Actor) error {
+	return s.repo.Archive(id, version, s.audited(actor, model.AuditArchive))
+}
+
+func (s *productService) Restore(id, version int, actor model.Actor) error {
+	return s.repo.Restore(id, version, s.audited(actor, model.AuditRestore))
 }
 
-func (s *productService) Update(id int, product *model.Product) error {
-	return s.repo.Update(id, product)
+func (s *productService) Delete(id, version int, actor model.Actor) error {
+	return s.repo.Delete(id, version, s.audited(actor, model.AuditDelete))
 }
 
-func (s *productService) Delete(id int) error {
-	return s.repo.Delete(id)
+// audited records a write to a product as action by actor.
+func (s *productService) audited(actor model.Actor, action string) repository.Audit {
+	return s.audit.Audit(actor, action, model.EntityProduct)
 }
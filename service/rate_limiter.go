@@ -0,0 +1,260 @@
+package service
+
+import (
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"kasir-api/model"
+)
+
+// RateLimitResult is the outcome of taking a request from a bucket.
+// Remaining is the number of requests left, Reset how long until the
+// bucket is full again and RetryAfter, when the request was refused, how
+// long until the next one is allowed.
+type RateLimitResult struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	Reset      time.Duration
+	RetryAfter time.Duration
+}
+
+// RateLimitStore keeps token buckets and failure counts by key. The
+// in-process store is enough for a single server; several servers behind a
+// load balancer need one backed by a shared store.
+type RateLimitStore interface {
+	// Take takes a token from key's bucket, refilled at limit's rate.
+	Take(key string, limit model.RateLimit, now time.Time) (RateLimitResult, error)
+	// RecordFailure counts a failure against key and returns the number of
+	// failures recorded. Failures are forgotten window after the last one.
+	RecordFailure(key string, window time.Duration, now time.Time) (int, error)
+	// Failures returns the failures recorded against key and when the last
+	// one was.
+	Failures(key string, now time.Time) (int, time.Time, error)
+	ResetFailures(key string) error
+}
+
+// RateLimiter limits requests per route group and caller.
+type RateLimiter interface {
+	// Allow takes a request of caller's from group's bucket. Groups without
+	// a limit always allow.
+	Allow(group, caller string) RateLimitResult
+}
+
+type rateLimiter struct {
+	store  RateLimitStore
+	limits map[string]model.RateLimit
+}
+
+func NewRateLimiter(store RateLimitStore, limits map[string]model.RateLimit) RateLimiter {
+	return &rateLimiter{store: store, limits: limits}
+}
+
+func (l *rateLimiter) Allow(group, caller string) RateLimitResult {
+	limit := l.limits[group]
+	if limit.Requests <= 0 {
+		return RateLimitResult{Allowed: true}
+	}
+	result, err := l.store.Take(group+":"+caller, limit, time.Now())
+	if err != nil {
+		// An unreachable store must not take the API down with it.
+		log.Printf("Rate limit: failed to check %s for %s: %v", group, caller, err)
+		return RateLimitResult{Allowed: true}
+	}
+	return result
+}
+
+// LockoutConfig sets when repeated failures lock a key out: from the
+// Threshold-th failure on, for BaseDelay doubling with each further
+// failure up to MaxDelay. Failures are forgotten Window after the last.
+type LockoutConfig struct {
+	Threshold int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Window    time.Duration
+}
+
+// Lockout slows down guessing, e.g. of passwords, by locking a key out for
+// exponentially longer after each failure past a threshold. Callers check
+// the key before an attempt and report its outcome after.
+type Lockout interface {
+	// Check returns how long key is still locked out, or 0.
+	Check(key string) time.Duration
+	// Fail records a failed attempt and returns how long key is now locked
+	// out, or 0.
+	Fail(key string) time.Duration
+	// Succeed forgets key's failures.
+	Succeed(key string)
+}
+
+type lockout struct {
+	store RateLimitStore
+	cfg   LockoutConfig
+}
+
+func NewLockout(store RateLimitStore, cfg LockoutConfig) Lockout {
+	// Failures are remembered at least as long as the longest lockout, so
+	// that a key locked out for MaxDelay stays at it.
+	cfg.Window = max(cfg.Window, cfg.MaxDelay)
+	return &lockout{store: store, cfg: cfg}
+}
+
+func (l *lockout) Check(key string) time.Duration {
+	if l.cfg.Threshold <= 0 {
+		return 0
+	}
+	now := time.Now()
+	failures, last, err := l.store.Failures(key, now)
+	if err != nil {
+		log.Printf("Lockout: failed to check %s: %v", key, err)
+		return 0
+	}
+	return max(0, last.Add(l.delay(failures)).Sub(now))
+}
+
+func (l *lockout) Fail(key string) time.Duration {
+	if l.cfg.Threshold <= 0 {
+		return 0
+	}
+	failures, err := l.store.RecordFailure(key, l.cfg.Window, time.Now())
+	if err != nil {
+		log.Printf("Lockout: failed to record failure of %s: %v", key, err)
+		return 0
+	}
+	return l.delay(failures)
+}
+
+func (l *lockout) Succeed(key string) {
+	if err := l.store.ResetFailures(key); err != nil {
+		log.Printf("Lockout: failed to reset %s: %v", key, err)
+	}
+}
+
+// delay returns how long failures failures lock a key out for.
+func (l *lockout) delay(failures int) time.Duration {
+	if failures < l.cfg.Threshold {
+		return 0
+	}
+	doublings := failures - l.cfg.Threshold
+	if doublings >= 32 {
+		return l.cfg.MaxDelay
+	}
+	return min(l.cfg.BaseDelay<<doublings, l.cfg.MaxDelay)
+}
+
+// sweepInterval is how often the memory store drops buckets that have
+// refilled and failures that have been forgotten.
+const sweepInterval = time.Minute
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+type failureRecord struct {
+	count   int
+	last    time.Time
+	expires time.Time
+}
+
+type memoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	failures  map[string]*failureRecord
+	lastSweep time.Time
+}
+
+// NewMemoryRateLimitStore returns a RateLimitStore kept in this process.
+func NewMemoryRateLimitStore() RateLimitStore {
+	return &memoryRateLimitStore{buckets: map[string]*bucket{}, failures: map[string]*failureRecord{}}
+}
+
+func (s *memoryRateLimitStore) Take(key string, limit model.RateLimit, now time.Time) (RateLimitResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	capacity := float64(limit.Requests)
+	perSecond := capacity / limit.Per.Seconds()
+
+	b := s.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: capacity, last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = min(capacity, b.tokens+now.Sub(b.last).Seconds()*perSecond)
+	b.last = now
+
+	result := RateLimitResult{Limit: limit.Requests}
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsDuration((1 - b.tokens) / perSecond)
+	}
+	result.Remaining = int(math.Floor(b.tokens))
+	result.Reset = secondsDuration((capacity - b.tokens) / perSecond)
+	b.full = now.Add(result.Reset)
+	return result, nil
+}
+
+func (s *memoryRateLimitStore) RecordFailure(key string, window time.Duration, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep(now)
+
+	f := s.failures[key]
+	if f == nil || !now.Before(f.expires) {
+		f = &failureRecord{}
+		s.failures[key] = f
+	}
+	f.count++
+	f.last = now
+	f.expires = now.Add(window)
+	return f.count, nil
+}
+
+func (s *memoryRateLimitStore) Failures(key string, now time.Time) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f := s.failures[key]
+	if f == nil || !now.Before(f.expires) {
+		return 0, time.Time{}, nil
+	}
+	return f.count, f.last, nil
+}
+
+func (s *memoryRateLimitStore) ResetFailures(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.failures, key)
+	return nil
+}
+
+// sweep drops what is no longer needed, at most once per sweepInterval.
+// The caller holds s.mu.
+func (s *memoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.full) {
+			delete(s.buckets, key)
+		}
+	}
+	for key, f := range s.failures {
+		if !now.Before(f.expires) {
+			delete(s.failures, key)
+		}
+	}
+}
+
+// secondsDuration converts seconds to a Duration.
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,188 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kasir-api/model"
+	"kasir-api/receipt"
+	"kasir-api/repository"
+)
+
+var ErrUnknownReceiptChannel = errors.New("receipt channel is not configured")
+var ErrTransactionNotFound = errors.New("transaction not found")
+
+// ReceiptDeliveryConfig tunes the delivery worker. Failed attempts are
+// retried with exponential backoff starting at RetryDelay until MaxAttempts
+// is reached.
+type ReceiptDeliveryConfig struct {
+	Store        receipt.Store
+	PaperWidth   int
+	MaxAttempts  int
+	RetryDelay   time.Duration
+	PollInterval time.Duration
+}
+
+type ReceiptDeliveryService interface {
+	// Enqueue queues a receipt for sending and returns immediately.
+	Enqueue(transactionID int, req model.ReceiptRequest) (*model.ReceiptDelivery, error)
+	GetByTransactionID(transactionID int) ([]model.ReceiptDelivery, error)
+	// HasChannel reports whether a sender is registered for channel.
+	HasChannel(channel string) bool
+	// Run processes the queue until ctx is cancelled.
+	Run(ctx context.Context)
+}
+
+type receiptDeliveryService struct {
+	repo            repository.ReceiptDeliveryRepository
+	transactionRepo repository.TransactionRepository
+	senders         map[string]ReceiptSender
+	cfg             ReceiptDeliveryConfig
+	wake            chan struct{}
+}
+
+// NewReceiptDeliveryService creates the service with one sender per
+// channel name, e.g. "email" or "whatsapp".
+func NewReceiptDeliveryService(repo repository.ReceiptDeliveryRepository, transactionRepo repository.TransactionRepository,
+	senders map[string]ReceiptSender, cfg ReceiptDeliveryConfig) ReceiptDeliveryService {
+	return &receiptDeliveryService{
+		repo:            repo,
+		transactionRepo: transactionRepo,
+		senders:         senders,
+		cfg:             cfg,
+		wake:            make(chan struct{}, 1),
+	}
+}
+
+func (s *receiptDeliveryService) HasChannel(channel string) bool {
+	_, ok := s.senders[channel]
+	return ok
+}
+
+func (s *receiptDeliveryService) Enqueue(transactionID int, req model.ReceiptRequest) (*model.ReceiptDelivery, error) {
+	switch recipient := strings.TrimSpace(req.Recipient); {
+	case recipient == "":
+		return nil, NewFieldError("recipient", "is required")
+	case len(recipient) > maxNameLength:
+		return nil, NewFieldError("recipient", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+	if !s.HasChannel(req.Channel) {
+		return nil, ErrUnknownReceiptChannel
+	}
+
+	transaction, err := s.transactionRepo.GetByID(transactionID)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	delivery := &model.ReceiptDelivery{
+		TransactionID: transactionID,
+		Channel:       req.Channel,
+		Recipient:     strings.TrimSpace(req.Recipient),
+	}
+	if err := s.repo.Create(delivery); err != nil {
+		return nil, err
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+	return delivery, nil
+}
+
+func (s *receiptDeliveryService) GetByTransactionID(transactionID int) ([]model.ReceiptDelivery, error) {
+	return s.repo.GetByTransactionID(transactionID)
+}
+
+func (s *receiptDeliveryService) Run(ctx context.Context) {
+	if err := s.repo.ResetInFlight(); err != nil {
+		log.Printf("Receipt delivery: failed to reset in-flight deliveries: %v", err)
+	}
+
+	ticker := time.NewTicker(s.cfg.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.processDue(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+func (s *receiptDeliveryService) processDue(ctx context.Context) {
+	for {
+		deliveries, err := s.repo.ClaimDue(10)
+		if err != nil {
+			log.Printf("Receipt delivery: failed to claim deliveries: %v", err)
+			return
+		}
+		if len(deliveries) == 0 {
+			return
+		}
+
+		for _, d := range deliveries {
+			s.deliver(ctx, d)
+		}
+	}
+}
+
+func (s *receiptDeliveryService) deliver(ctx context.Context, d model.ReceiptDelivery) {
+	err := s.send(ctx, d)
+	if err == nil {
+		if err := s.repo.MarkSent(d.ID); err != nil {
+			log.Printf("Receipt delivery %d: failed to mark as sent: %v", d.ID, err)
+		}
+		return
+	}
+
+	var retryAfter time.Duration
+	if d.Attempts < s.cfg.MaxAttempts {
+		retryAfter = s.cfg.RetryDelay << (d.Attempts - 1)
+	}
+	if err := s.repo.MarkFailed(d.ID, err.Error(), retryAfter); err != nil {
+		log.Printf("Receipt delivery %d: failed to record failure: %v", d.ID, err)
+	}
+}
+
+func (s *receiptDeliveryService) send(ctx context.Context, d model.ReceiptDelivery) error {
+	sender, ok := s.senders[d.Channel]
+	if !ok {
+		return ErrUnknownReceiptChannel
+	}
+
+	transaction, err := s.transactionRepo.GetByID(d.TransactionID)
+	if err != nil {
+		return err
+	}
+	if transaction == nil {
+		return ErrTransactionNotFound
+	}
+
+	doc := receipt.FromTransaction(transaction, s.cfg.Store)
+	html, err := receipt.HTML(doc, s.cfg.PaperWidth)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	return sender.Send(ctx, d.Recipient, ReceiptMessage{
+		Subject: fmt.Sprintf("Struk %s #%d", s.cfg.Store.Name, transaction.ID),
+		Text:    receipt.Text(doc, s.cfg.PaperWidth),
+		HTML:    html,
+	})
+}
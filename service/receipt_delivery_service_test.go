@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"kasir-api/model"
+	"kasir-api/receipt"
+	"kasir-api/repository"
+)
+
+// fakeDeliveryRepo records how deliveries end. Methods deliver does not
+// call panic through the nil embedded interface.
+type fakeDeliveryRepo struct {
+	repository.ReceiptDeliveryRepository
+	sent       []int
+	failed     []int
+	retryAfter []time.Duration
+}
+
+func (r *fakeDeliveryRepo) MarkSent(id int) error {
+	r.sent = append(r.sent, id)
+	return nil
+}
+
+func (r *fakeDeliveryRepo) MarkFailed(id int, lastError string, retryAfter time.Duration) error {
+	r.failed = append(r.failed, id)
+	r.retryAfter = append(r.retryAfter, retryAfter)
+	return nil
+}
+
+type fakeTransactionRepo struct {
+	repository.TransactionRepository
+}
+
+func (fakeTransactionRepo) GetByID(id int) (*model.Transaction, error) {
+	return &model.Transaction{ID: id, PaymentMethod: model.PaymentCash}, nil
+}
+
+type fakeSender struct {
+	err error
+}
+
+func (s fakeSender) Send(ctx context.Context, recipient string, msg ReceiptMessage) error {
+	return s.err
+}
+
+func newTestDeliveryService(repo *fakeDeliveryRepo, sendErr error) *receiptDeliveryService {
+	return NewReceiptDeliveryService(repo, fakeTransactionRepo{},
+		map[string]ReceiptSender{"email": fakeSender{err: sendErr}},
+		ReceiptDeliveryConfig{
+			PaperWidth:  receipt.Paper58mm,
+			MaxAttempts: 4,
+			RetryDelay:  30 * time.Second,
+		}).(*receiptDeliveryService)
+}
+
+func TestDeliverMarksSent(t *testing.T) {
+	repo := &fakeDeliveryRepo{}
+	s := newTestDeliveryService(repo, nil)
+
+	s.deliver(context.Background(), model.ReceiptDelivery{ID: 7, TransactionID: 1, Channel: "email", Attempts: 1})
+
+	if len(repo.sent) != 1 || repo.sent[0] != 7 || len(repo.failed) != 0 {
+		t.Errorf("sent = %v, failed = %v; want delivery 7 sent", repo.sent, repo.failed)
+	}
+}
+
+func TestDeliverBacksOffThenGivesUp(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		// The last attempt failed: no retry.
+		{4, 0},
+		{5, 0},
+	}
+	for _, tt := range tests {
+		repo := &fakeDeliveryRepo{}
+		s := newTestDeliveryService(repo, errors.New("mailbox unavailable"))
+
+		s.deliver(context.Background(), model.ReceiptDelivery{ID: 7, TransactionID: 1, Channel: "email", Attempts: tt.attempts})
+
+		if len(repo.sent) != 0 || len(repo.retryAfter) != 1 {
+			t.Fatalf("attempt %d: sent = %v, failed = %v; want one failure", tt.attempts, repo.sent, repo.failed)
+		}
+		if repo.retryAfter[0] != tt.want {
+			t.Errorf("attempt %d: retry after %v, want %v", tt.attempts, repo.retryAfter[0], tt.want)
+		}
+	}
+}
+
+func TestDeliverFailsOnUnknownChannel(t *testing.T) {
+	repo := &fakeDeliveryRepo{}
+	s := newTestDeliveryService(repo, nil)
+
+	s.deliver(context.Background(), model.ReceiptDelivery{ID: 7, TransactionID: 1, Channel: "sms", Attempts: 1})
+
+	// The channel may come back with the configuration, so the delivery is
+	// still retried rather than marked sent.
+	if len(repo.sent) != 0 || len(repo.failed) != 1 {
+		t.Errorf("sent = %v, failed = %v; want one failure", repo.sent, repo.failed)
+	}
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// ReceiptMessage is a rendered receipt ready to be sent to a customer.
+type ReceiptMessage struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// ReceiptSender delivers receipts through one channel, e.g. email.
+type ReceiptSender interface {
+	Send(ctx context.Context, recipient string, msg ReceiptMessage) error
+}
+
+// SMTPConfig configures SMTPReceiptSender. Authentication is skipped when
+// Username is empty.
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// SMTPReceiptSender emails receipts as multipart text and HTML messages.
+type SMTPReceiptSender struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPReceiptSender(cfg SMTPConfig) *SMTPReceiptSender {
+	return &SMTPReceiptSender{cfg: cfg}
+}
+
+func (s *SMTPReceiptSender) Send(ctx context.Context, recipient string, msg ReceiptMessage) error {
+	if strings.ContainsAny(recipient, "\r\n") {
+		return fmt.Errorf("invalid email address %q", recipient)
+	}
+
+	body, err := buildEmail(s.cfg.From, recipient, msg)
+	if err != nil {
+		return err
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(s.cfg.Host, s.cfg.Port))
+	if err != nil {
+		return err
+	}
+	// Closing the connection when ctx ends aborts the exchange wherever it
+	// is, so that a stalled server cannot keep the send running.
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	err = s.send(conn, recipient, body)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+// send delivers the email over conn the way smtp.SendMail does, upgrading
+// to TLS when the server offers it.
+func (s *SMTPReceiptSender) send(conn net.Conn, recipient string, body []byte) error {
+	c, err := smtp.NewClient(conn, s.cfg.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.cfg.Host}); err != nil {
+			return err
+		}
+	}
+	if s.cfg.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp: server does not support AUTH")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.cfg.Username, s.cfg.Password, s.cfg.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(s.cfg.From); err != nil {
+		return err
+	}
+	if err := c.Rcpt(recipient); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func buildEmail(from, to string, msg ReceiptMessage) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	parts := []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	}
+	for _, p := range parts {
+		if p.content == "" {
+			continue
+		}
+		part, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(part)
+		if _, err := qp.Write([]byte(p.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var email bytes.Buffer
+	fmt.Fprintf(&email, "From: %s\r\n", from)
+	fmt.Fprintf(&email, "To: %s\r\n", to)
+	fmt.Fprintf(&email, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&email, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	email.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&email, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	email.Write(body.Bytes())
+	return email.Bytes(), nil
+}
+
+// HTTPGatewayReceiptSender posts the plain text receipt to a messaging
+// gateway (such as a WhatsApp API provider) as
+// {"to": recipient, "subject": ..., "message": ...}.
+type HTTPGatewayReceiptSender struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func NewHTTPGatewayReceiptSender(url, token string) *HTTPGatewayReceiptSender {
+	return &HTTPGatewayReceiptSender{url: url, token: token, client: &http.Client{Timeout: 15 * time.Second}}
+}
+
+func (s *HTTPGatewayReceiptSender) Send(ctx context.Context, recipient string, msg ReceiptMessage) error {
+	payload, err := json.Marshal(map[string]string{
+		"to":      recipient,
+		"subject": msg.Subject,
+		"message": msg.Text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("gateway responded %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
@@ -0,0 +1,215 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testReceipt = ReceiptMessage{
+	Subject: "Struk Toko Kasir #1042",
+	Text:    "TOTAL                     61.750",
+	HTML:    `<div class="center bold">Toko Kasir</div>`,
+}
+
+func TestHTTPGatewayReceiptSenderPostsReceipt(t *testing.T) {
+	var got map[string]string
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	sender := NewHTTPGatewayReceiptSender(server.URL, "secret")
+	if err := sender.Send(context.Background(), "+6281234567890", testReceipt); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if auth != "Bearer secret" {
+		t.Errorf("Authorization = %q, want %q", auth, "Bearer secret")
+	}
+	want := map[string]string{"to": "+6281234567890", "subject": testReceipt.Subject, "message": testReceipt.Text}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+}
+
+func TestHTTPGatewayReceiptSenderReportsErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "number not registered", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	err := NewHTTPGatewayReceiptSender(server.URL, "").Send(context.Background(), "+62000", testReceipt)
+	if err == nil {
+		t.Fatal("Send succeeded, want an error")
+	}
+	if !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "number not registered") {
+		t.Errorf("error %q does not carry the status and response body", err)
+	}
+}
+
+// fakeSMTPServer accepts one SMTP session and sends the message data it
+// receives on the returned channel. With stall set it accepts the
+// connection but never greets.
+func fakeSMTPServer(t *testing.T, stall bool) (host, port string, data <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		if stall {
+			io.Copy(io.Discard, conn)
+			return
+		}
+
+		r := bufio.NewReader(conn)
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL"), strings.HasPrefix(cmd, "RCPT"):
+				reply("250 OK")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var msg strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					msg.WriteString(strings.TrimPrefix(l, "."))
+				}
+				received <- msg.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 not implemented")
+			}
+		}
+	}()
+
+	host, port, _ = net.SplitHostPort(ln.Addr().String())
+	return host, port, received
+}
+
+func TestSMTPReceiptSenderSendsMultipartEmail(t *testing.T) {
+	host, port, data := fakeSMTPServer(t, false)
+	sender := NewSMTPReceiptSender(SMTPConfig{Host: host, Port: port, From: "toko@example.com"})
+
+	if err := sender.Send(context.Background(), "pembeli@example.com", testReceipt); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	var raw string
+	select {
+	case raw = <-data:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the server received no message")
+	}
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := msg.Header.Get("To"); got != "pembeli@example.com" {
+		t.Errorf("To = %q", got)
+	}
+	if got, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err != nil || got != testReceipt.Subject {
+		t.Errorf("Subject = %q (%v), want %q", got, err, testReceipt.Subject)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q (%v), want multipart/alternative", msg.Header.Get("Content-Type"), err)
+	}
+	parts := map[string]string{}
+	mr := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contentType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		parts[contentType] = string(body)
+	}
+	if parts["text/plain"] != testReceipt.Text {
+		t.Errorf("text part = %q, want %q", parts["text/plain"], testReceipt.Text)
+	}
+	if parts["text/html"] != testReceipt.HTML {
+		t.Errorf("HTML part = %q, want %q", parts["text/html"], testReceipt.HTML)
+	}
+}
+
+func TestSMTPReceiptSenderRejectsHeaderInjection(t *testing.T) {
+	// No server is listening: the recipient must be refused before dialing.
+	sender := NewSMTPReceiptSender(SMTPConfig{Host: "127.0.0.1", Port: "1", From: "toko@example.com"})
+	for _, recipient := range []string{"a@example.com\r\nBcc: b@example.com", "a@example.com\nBcc: b@example.com"} {
+		err := sender.Send(context.Background(), recipient, testReceipt)
+		if err == nil || !strings.Contains(err.Error(), "invalid email address") {
+			t.Errorf("Send(%q) = %v, want an invalid address error", recipient, err)
+		}
+	}
+}
+
+func TestSMTPReceiptSenderStopsWhenContextEnds(t *testing.T) {
+	host, port, _ := fakeSMTPServer(t, true)
+	sender := NewSMTPReceiptSender(SMTPConfig{Host: host, Port: port, From: "toko@example.com"})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- sender.Send(ctx, "pembeli@example.com", testReceipt) }()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Send = %v, want %v", err, context.DeadlineExceeded)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Send kept running after its context ended")
+	}
+}
@@ -6,9 +6,14 @@ import (
 	"time"
 )
 
+// ReportService reports sales. An outletID of 0 covers every outlet.
 type ReportService interface {
-	GetTodaySummary() (*model.SalesSummary, error)
-	GetSummaryByDateRange(startDate, endDate time.Time) (*model.SalesSummary, error)
+	GetTodaySummary(outletID int) (*model.SalesSummary, error)
+	GetSummaryByDateRange(startDate, endDate time.Time, outletID int) (*model.SalesSummary, error)
+	GetTodayCategorySales(outletID int) ([]model.CategorySales, error)
+	GetCategorySalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CategorySales, error)
+	GetTodayCashierSales(outletID int) ([]model.CashierSales, error)
+	GetCashierSalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CashierSales, error)
 }
 
 type reportService struct {
@@ -19,10 +24,97 @@ func NewReportService(repo repository.ReportRepository) ReportService {
 	return &reportService{repo: repo}
 }
 
-func (s *reportService) GetTodaySummary() (*model.SalesSummary, error) {
-	return s.repo.GetTodaySummary()
+func (s *reportService) GetTodaySummary(outletID int) (*model.SalesSummary, error) {
+	return s.repo.GetTodaySummary(outletID)
 }
 
-func (s *reportService) GetSummaryByDateRange(startDate, endDate time.Time) (*model.SalesSummary, error) {
-	return s.repo.GetSummaryByDateRange(startDate, endDate)
+func (s *reportService) GetSummaryByDateRange(startDate, endDate time.Time, outletID int) (*model.SalesSummary, error) {
+	return s.repo.GetSummaryByDateRange(startDate, endDate, outletID)
+}
+
+func (s *reportService) GetTodayCategorySales(outletID int) ([]model.CategorySales, error) {
+	sales, err := s.repo.GetTodayCategorySales(outletID)
+	if err != nil {
+		return nil, err
+	}
+	return rollUpCategorySales(sales), nil
+}
+
+func (s *reportService) GetCategorySalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CategorySales, error) {
+	sales, err := s.repo.GetCategorySalesByDateRange(startDate, endDate, outletID)
+	if err != nil {
+		return nil, err
+	}
+	return rollUpCategorySales(sales), nil
+}
+
+func (s *reportService) GetTodayCashierSales(outletID int) ([]model.CashierSales, error) {
+	sales, err := s.repo.GetTodayCashierSales(outletID)
+	if err != nil {
+		return nil, err
+	}
+	return withAverageBasket(sales), nil
+}
+
+func (s *reportService) GetCashierSalesByDateRange(startDate, endDate time.Time, outletID int) ([]model.CashierSales, error) {
+	sales, err := s.repo.GetCashierSalesByDateRange(startDate, endDate, outletID)
+	if err != nil {
+		return nil, err
+	}
+	return withAverageBasket(sales), nil
+}
+
+// withAverageBasket fills in the average transaction value of each cashier,
+// rounded down.
+func withAverageBasket(sales []model.CashierSales) []model.CashierSales {
+	for i := range sales {
+		if sales[i].Transactions > 0 {
+			sales[i].AverageBasket = sales[i].Revenue / sales[i].Transactions
+		}
+	}
+	if sales == nil {
+		sales = []model.CashierSales{}
+	}
+	return sales
+}
+
+// rollUpCategorySales nests per-category sales into the category tree and
+// fills in the totals of each node from its own sales plus its subtree.
+func rollUpCategorySales(sales []model.CategorySales) []model.CategorySales {
+	children := map[int][]model.CategorySales{}
+	known := map[int]bool{}
+	for _, cs := range sales {
+		known[cs.CategoryID] = true
+	}
+
+	var roots []model.CategorySales
+	for _, cs := range sales {
+		if cs.ParentID != nil && known[*cs.ParentID] {
+			children[*cs.ParentID] = append(children[*cs.ParentID], cs)
+		} else {
+			roots = append(roots, cs)
+		}
+	}
+
+	var attach func(nodes []model.CategorySales) []model.CategorySales
+	attach = func(nodes []model.CategorySales) []model.CategorySales {
+		for i := range nodes {
+			nodes[i].Children = attach(children[nodes[i].CategoryID])
+			nodes[i].TotalQuantity = nodes[i].Quantity
+			nodes[i].TotalWeight = nodes[i].Weight
+			nodes[i].TotalRevenue = nodes[i].Revenue
+			for _, child := range nodes[i].Children {
+				nodes[i].TotalQuantity += child.TotalQuantity
+				nodes[i].TotalWeight += child.TotalWeight
+				nodes[i].TotalRevenue += child.TotalRevenue
+			}
+		}
+		return nodes
+	}
+
+	roots = attach(roots)
+	if roots == nil {
+		roots = []model.CategorySales{}
+	}
+	return roots
 }
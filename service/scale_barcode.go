@@ -0,0 +1,102 @@
+package service
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var ErrInvalidBarcode = errors.New("invalid scale barcode")
+
+// ScaleBarcodeKind tells what the value embedded in a scale barcode means.
+type ScaleBarcodeKind int
+
+const (
+	// ScaleBarcodeWeight embeds the weight in grams (or millilitres).
+	ScaleBarcodeWeight ScaleBarcodeKind = iota + 1
+	// ScaleBarcodePrice embeds the amount to charge in rupiah.
+	ScaleBarcodePrice
+)
+
+// ScaleBarcodeConfig lists the two-digit EAN-13 prefixes printed by the
+// in-store scales and whether each one embeds a weight or a price.
+type ScaleBarcodeConfig struct {
+	WeightPrefixes []string
+	PricePrefixes  []string
+}
+
+// ScaleBarcode is a decoded in-store EAN-13 label laid out as
+// PP IIIII VVVVV C: prefix, item PLU code, embedded value and check digit.
+type ScaleBarcode struct {
+	PLU   string
+	Kind  ScaleBarcodeKind
+	Value int
+}
+
+// IsScaleBarcode reports whether code looks like a label printed by one of
+// the configured in-store scales rather than a manufacturer barcode.
+func IsScaleBarcode(code string, cfg ScaleBarcodeConfig) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != 13 {
+		return false
+	}
+	prefix := code[:2]
+	return containsString(cfg.WeightPrefixes, prefix) || containsString(cfg.PricePrefixes, prefix)
+}
+
+// ParseScaleBarcode decodes an in-store EAN-13 label. It returns
+// ErrInvalidBarcode when the code is malformed, fails the check digit or
+// uses a prefix that is not configured for the scales.
+func ParseScaleBarcode(code string, cfg ScaleBarcodeConfig) (*ScaleBarcode, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != 13 {
+		return nil, ErrInvalidBarcode
+	}
+	for _, c := range code {
+		if c < '0' || c > '9' {
+			return nil, ErrInvalidBarcode
+		}
+	}
+	if ean13CheckDigit(code[:12]) != code[12] {
+		return nil, ErrInvalidBarcode
+	}
+
+	var kind ScaleBarcodeKind
+	prefix := code[:2]
+	switch {
+	case containsString(cfg.WeightPrefixes, prefix):
+		kind = ScaleBarcodeWeight
+	case containsString(cfg.PricePrefixes, prefix):
+		kind = ScaleBarcodePrice
+	default:
+		return nil, ErrInvalidBarcode
+	}
+
+	value, err := strconv.Atoi(code[7:12])
+	if err != nil || value <= 0 {
+		return nil, ErrInvalidBarcode
+	}
+
+	return &ScaleBarcode{PLU: code[2:7], Kind: kind, Value: value}, nil
+}
+
+func ean13CheckDigit(digits string) byte {
+	sum := 0
+	for i := 0; i < len(digits); i++ {
+		d := int(digits[i] - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return byte('0' + (10-sum%10)%10)
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
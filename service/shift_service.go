@@ -0,0 +1,155 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+var ErrNotShiftOwner = errors.New("shift belongs to another cashier")
+
+type ShiftService interface {
+	// Open starts a shift for actor at the terminal with the cash counted
+	// into the drawer.
+	Open(req model.OpenShiftRequest, actor model.Actor) (*model.Shift, error)
+	GetByID(id int, actor model.Actor) (*model.Shift, error)
+	// GetCurrent returns actor's open shift, or nil.
+	GetCurrent(actor model.Actor) (*model.Shift, error)
+	// GetAll lists shifts, newest first. Users who may not manage shifts
+	// only see their own.
+	GetAll(filter model.ShiftFilter, actor model.Actor) ([]model.Shift, error)
+	AddCashEvent(id int, req model.CashEventRequest, actor model.Actor) (*model.CashEvent, error)
+	// Close closes the shift with the cash counted in the drawer and
+	// returns it with its expected cash and variance.
+	Close(id int, req model.CloseShiftRequest, actor model.Actor) (*model.Shift, error)
+}
+
+type shiftService struct {
+	repo        repository.ShiftRepository
+	permissions model.RolePermissions
+}
+
+func NewShiftService(repo repository.ShiftRepository, permissions model.RolePermissions) ShiftService {
+	return &shiftService{repo: repo, permissions: permissions}
+}
+
+func (s *shiftService) Open(req model.OpenShiftRequest, actor model.Actor) (*model.Shift, error) {
+	v := &ValidationError{}
+	checkTerminalID(v, req.TerminalID)
+	checkNotNegative(v, "opening_float", req.OpeningFloat)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	shift := &model.Shift{CashierID: actor.UserID, TerminalID: req.TerminalID, OpeningFloat: req.OpeningFloat}
+	if err := s.repo.Open(shift); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+func (s *shiftService) GetByID(id int, actor model.Actor) (*model.Shift, error) {
+	shift, err := s.repo.GetByID(id)
+	if err != nil || shift == nil {
+		return nil, err
+	}
+	if err := s.checkOwner(shift, actor); err != nil {
+		return nil, err
+	}
+	return shift, nil
+}
+
+func (s *shiftService) GetCurrent(actor model.Actor) (*model.Shift, error) {
+	return s.repo.GetOpen(actor.UserID)
+}
+
+func (s *shiftService) GetAll(filter model.ShiftFilter, actor model.Actor) ([]model.Shift, error) {
+	if filter.Status != "" && filter.Status != model.ShiftOpen && filter.Status != model.ShiftClosed {
+		v := &ValidationError{}
+		v.Add("status", "must be open or closed")
+		return nil, v.Err()
+	}
+	if !s.permissions.Allows(actor.Role, model.PermShiftsManage) {
+		filter.CashierID = actor.UserID
+	}
+	return s.repo.GetAll(filter)
+}
+
+func (s *shiftService) AddCashEvent(id int, req model.CashEventRequest, actor model.Actor) (*model.CashEvent, error) {
+	v := &ValidationError{}
+	if req.Type != model.CashIn && req.Type != model.CashOut {
+		v.Add("type", "must be cash_in or cash_out")
+	}
+	if req.Amount <= 0 {
+		v.Add("amount", "must be greater than 0")
+	}
+	switch {
+	case strings.TrimSpace(req.Reason) == "":
+		v.Add("reason", "is required")
+	case utf8.RuneCountInString(req.Reason) > maxNameLength:
+		v.Add("reason", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwned(id, actor); err != nil {
+		return nil, err
+	}
+
+	event := &model.CashEvent{ShiftID: id, Type: req.Type, Amount: req.Amount, Reason: req.Reason, CreatedBy: actor.UserID}
+	if err := s.repo.AddCashEvent(event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+func (s *shiftService) Close(id int, req model.CloseShiftRequest, actor model.Actor) (*model.Shift, error) {
+	v := &ValidationError{}
+	if req.CountedCash == nil {
+		v.Add("counted_cash", "is required")
+	} else {
+		checkNotNegative(v, "counted_cash", *req.CountedCash)
+	}
+	if utf8.RuneCountInString(req.Note) > maxNameLength {
+		v.Add("note", fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := s.checkOwned(id, actor); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Close(id, actor.UserID, *req.CountedCash, req.Note); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
+}
+
+// checkOwned checks that actor may act on the shift. It returns
+// sql.ErrNoRows when the shift does not exist.
+func (s *shiftService) checkOwned(id int, actor model.Actor) error {
+	shift, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	if shift == nil {
+		return sql.ErrNoRows
+	}
+	return s.checkOwner(shift, actor)
+}
+
+// checkOwner lets cashiers act on their own shifts and users who manage
+// shifts on anyone's.
+func (s *shiftService) checkOwner(shift *model.Shift, actor model.Actor) error {
+	if shift.CashierID != actor.UserID && !s.permissions.Allows(actor.Role, model.PermShiftsManage) {
+		return ErrNotShiftOwner
+	}
+	return nil
+}
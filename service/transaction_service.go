@@ -1,22 +1,293 @@
 package service
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"log"
+	"math"
+	"strings"
+
 	"kasir-api/model"
 	"kasir-api/repository"
 )
 
 type TransactionService interface {
-	Checkout(req model.CheckoutRequest) (*model.Transaction, error)
+	// Checkout sells the request's items as actor, who is recorded as the
+	// cashier and in the audit log. Price overrides and discounts above
+	// the configured share of a line need the transactions:discount
+	// permission or a supervisor's approval in req.ApprovalToken; see
+	// ApprovalService.Authorize.
+	Checkout(req model.CheckoutRequest, actor model.Actor) (*model.Transaction, error)
+	Preview(req model.CheckoutRequest) (*model.CheckoutPreview, error)
+	GetByID(id int) (*model.Transaction, error)
+	// GetAll lists transactions, newest first. The limit defaults to 50
+	// and is capped at 200.
+	GetAll(filter model.TransactionFilter) ([]model.Transaction, error)
+	// Void voids the transaction as actor. Without the transactions:void
+	// permission it needs a supervisor's approvalToken; see
+	// ApprovalService.Authorize.
+	Void(id int, req model.VoidRequest, actor model.Actor, approvalToken string) (*model.Transaction, error)
 }
 
 type transactionService struct {
-	repo repository.TransactionRepository
+	repo        repository.TransactionRepository
+	productRepo repository.ProductRepository
+	deliveries  ReceiptDeliveryService
+	approvals   ApprovalService
+	audit       AuditService
+	scaleConfig ScaleBarcodeConfig
+	// discountApprovalPercent is the largest discount, in percent of a
+	// line's subtotal, given without the transactions:discount permission.
+	discountApprovalPercent int
+}
+
+func NewTransactionService(repo repository.TransactionRepository, productRepo repository.ProductRepository,
+	deliveries ReceiptDeliveryService, approvals ApprovalService, audit AuditService, scaleConfig ScaleBarcodeConfig,
+	discountApprovalPercent int) TransactionService {
+	return &transactionService{repo: repo, productRepo: productRepo, deliveries: deliveries, approvals: approvals,
+		audit: audit, scaleConfig: scaleConfig, discountApprovalPercent: discountApprovalPercent}
 }
 
-func NewTransactionService(repo repository.TransactionRepository) TransactionService {
-	return &transactionService{repo: repo}
+func (s *transactionService) Checkout(req model.CheckoutRequest, actor model.Actor) (*model.Transaction, error) {
+	req.CashierID = actor.UserID
+	if err := validateCheckout(req).Err(); err != nil {
+		return nil, err
+	}
+	if req.Receipt != nil && !s.deliveries.HasChannel(req.Receipt.Channel) {
+		return nil, ErrUnknownReceiptChannel
+	}
+
+	items := make([]model.CheckoutItem, len(req.Items))
+	for i, item := range req.Items {
+		if item.Barcode != "" {
+			resolved, err := s.resolveBarcode(item)
+			if err != nil {
+				if _, ok := barcodeProblem(i, item, err); !ok {
+					return nil, err
+				}
+				// Report the other lines' problems along with the barcode.
+				preview, err := s.Preview(req)
+				if err != nil {
+					return nil, err
+				}
+				return nil, &repository.CheckoutError{Problems: preview.Problems}
+			}
+			item = *resolved
+		}
+		items[i] = item
+	}
+	req.Items = items
+
+	approve := func(lines []model.CheckoutLine) (*int, error) {
+		if !needsDiscountApproval(lines, s.discountApprovalPercent) {
+			return nil, nil
+		}
+		return s.approvals.Authorize(actor, model.PermTransactionsDiscount, checkoutResource(req, lines), req.ApprovalToken)
+	}
+	transaction, err := s.repo.Checkout(req, approve, s.audit.Audit(actor, model.AuditCreate, model.EntityTransaction))
+	if err != nil {
+		return nil, err
+	}
+
+	// The sale is committed at this point, so a failure to queue the
+	// digital receipt must not fail the checkout; it can be resent later.
+	if req.Receipt != nil {
+		if _, err := s.deliveries.Enqueue(transaction.ID, *req.Receipt); err != nil {
+			log.Printf("Failed to queue receipt for transaction %d: %v", transaction.ID, err)
+		}
+	}
+	return transaction, nil
 }
 
-func (s *transactionService) Checkout(req model.CheckoutRequest) (*model.Transaction, error) {
-	return s.repo.Checkout(req.Items)
+// Preview prices a checkout request without committing it, reporting a
+// problem for every line that could not be sold instead of only the first.
+// Lines are priced at the terminal's outlet, or the default outlet when no
+// terminal is given.
+func (s *transactionService) Preview(req model.CheckoutRequest) (*model.CheckoutPreview, error) {
+	v := &ValidationError{}
+	validateCheckoutItems(v, req.Items)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	preview := &model.CheckoutPreview{Lines: make([]model.CheckoutLine, len(req.Items)), Problems: []model.CheckoutProblem{}}
+
+	// Lines whose barcode cannot be resolved are reported directly; the rest
+	// are priced together so stock is shared between lines as in Checkout.
+	var items []model.CheckoutItem
+	var positions []int
+	for i, item := range req.Items {
+		if item.Barcode != "" {
+			resolved, err := s.resolveBarcode(item)
+			if err != nil {
+				problem, ok := barcodeProblem(i, item, err)
+				if !ok {
+					return nil, err
+				}
+				preview.Lines[i] = model.CheckoutLine{Quantity: item.Quantity, Problem: problem}
+				continue
+			}
+			item = *resolved
+		}
+		items = append(items, item)
+		positions = append(positions, i)
+	}
+
+	lines, err := s.repo.Preview(items, req.TerminalID)
+	if err != nil {
+		return nil, err
+	}
+	for j, line := range lines {
+		if line.Problem != nil {
+			line.Problem.Line = positions[j]
+		}
+		preview.Lines[positions[j]] = line
+	}
+
+	for _, line := range preview.Lines {
+		if line.Problem != nil {
+			preview.Problems = append(preview.Problems, *line.Problem)
+			continue
+		}
+		preview.TotalAmount += line.Subtotal
+	}
+	preview.Valid = len(preview.Problems) == 0
+	return preview, nil
+}
+
+func (s *transactionService) GetByID(id int) (*model.Transaction, error) {
+	return s.repo.GetByID(id)
+}
+
+func (s *transactionService) GetAll(filter model.TransactionFilter) ([]model.Transaction, error) {
+	v := &ValidationError{}
+	filter.Limit = checkPage(v, filter.Limit, filter.Offset)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	return s.repo.GetAll(filter)
+}
+
+// resolveBarcode turns a scanned barcode into a checkout line. Scale labels
+// resolve to the weighted product registered under the label's PLU code;
+// any other code must match a product's own barcode.
+func (s *transactionService) resolveBarcode(item model.CheckoutItem) (*model.CheckoutItem, error) {
+	if !IsScaleBarcode(item.Barcode, s.scaleConfig) {
+		product, err := s.productRepo.GetByBarcode(item.Barcode)
+		if err != nil {
+			return nil, err
+		}
+		if product == nil {
+			return nil, repository.ErrProductNotFound
+		}
+		item.ProductID = product.ID
+		if item.Quantity == 0 {
+			item.Quantity = 1
+		}
+		return &item, nil
+	}
+
+	barcode, err := ParseScaleBarcode(item.Barcode, s.scaleConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	product, err := s.productRepo.GetByPLU(barcode.PLU)
+	if err != nil {
+		return nil, err
+	}
+	if product == nil {
+		return nil, repository.ErrProductNotFound
+	}
+	if !product.IsWeighted {
+		return nil, ErrInvalidBarcode
+	}
+
+	item.ProductID = product.ID
+	switch barcode.Kind {
+	case ScaleBarcodeWeight:
+		item.Quantity = barcode.Value
+	case ScaleBarcodePrice:
+		// The weight depends on the price charged at the outlet, so it is
+		// worked out when the line is priced.
+		item.Quantity = 0
+		item.FixedSubtotal = barcode.Value
+	}
+	if item.Quantity <= 0 && item.FixedSubtotal <= 0 {
+		return nil, ErrInvalidBarcode
+	}
+	return &item, nil
+}
+
+// needsDiscountApproval reports whether any line overrides its price or
+// takes more than percent off its subtotal.
+func needsDiscountApproval(lines []model.CheckoutLine, percent int) bool {
+	for _, line := range lines {
+		if line.RegularPrice != nil && *line.RegularPrice != line.Price {
+			return true
+		}
+		if line.Discount*100 > (line.Subtotal+line.Discount)*percent {
+			return true
+		}
+	}
+	return false
+}
+
+// checkoutResource stands for the sale a discount approval is bound to,
+// which has no ID until it is made. It hashes the cart or terminal and the
+// priced lines, so that the approval cannot be spent on another sale.
+func checkoutResource(req model.CheckoutRequest, lines []model.CheckoutLine) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d|%q", req.CartID, req.TerminalID)
+	for _, line := range lines {
+		fmt.Fprintf(h, "|%d,%d,%d,%d,%d", line.ProductID, line.Quantity, line.Price, line.Discount, line.Subtotal)
+	}
+	return int(h.Sum32() & math.MaxInt32)
+}
+
+// barcodeProblem describes a barcode that could not be resolved to a product.
+// It reports false for errors that are not the barcode's fault.
+func barcodeProblem(line int, item model.CheckoutItem, err error) (*model.CheckoutProblem, bool) {
+	problem := &model.CheckoutProblem{Line: line, Barcode: item.Barcode, Requested: item.Quantity}
+	switch {
+	case errors.Is(err, repository.ErrProductNotFound):
+		problem.Code = model.ProblemProductNotFound
+		problem.Message = "Product not found"
+	case errors.Is(err, ErrInvalidBarcode):
+		problem.Code = model.ProblemInvalidBarcode
+		problem.Message = "Invalid barcode"
+	default:
+		return nil, false
+	}
+	return problem, true
+}
+
+func (s *transactionService) Void(id int, req model.VoidRequest, actor model.Actor, approvalToken string) (*model.Transaction, error) {
+	v := &ValidationError{}
+	checkName(v, "reason", req.Reason)
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	transaction, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if transaction == nil {
+		return nil, ErrTransactionNotFound
+	}
+	if transaction.VoidedAt != nil {
+		return nil, repository.ErrTransactionVoided
+	}
+
+	approvedBy, err := s.approvals.Authorize(actor, model.PermTransactionsVoid, id, approvalToken)
+	if err != nil {
+		return nil, err
+	}
+	audit := s.audit.Audit(actor, model.AuditVoid, model.EntityTransaction)
+	if err := s.repo.Void(id, actor.UserID, approvedBy, strings.TrimSpace(req.Reason), audit); err != nil {
+		return nil, err
+	}
+	return s.repo.GetByID(id)
 }
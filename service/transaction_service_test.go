@@ -0,0 +1,30 @@
+package service
+
+import (
+	"testing"
+
+	"kasir-api/model"
+)
+
+func TestNeedsDiscountApproval(t *testing.T) {
+	regular := 5000
+	tests := []struct {
+		name string
+		line model.CheckoutLine
+		want bool
+	}{
+		{"no discount", model.CheckoutLine{Price: 5000, Quantity: 2, Subtotal: 10000}, false},
+		{"discount at the limit", model.CheckoutLine{Price: 5000, Quantity: 2, Discount: 1000, Subtotal: 9000}, false},
+		{"discount above the limit", model.CheckoutLine{Price: 5000, Quantity: 2, Discount: 1001, Subtotal: 8999}, true},
+		{"price override", model.CheckoutLine{Price: 4500, RegularPrice: &regular, Quantity: 2, Subtotal: 9000}, true},
+		{"override to the same price", model.CheckoutLine{Price: 5000, RegularPrice: &regular, Quantity: 2, Subtotal: 10000}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := []model.CheckoutLine{{Price: 3500, Quantity: 1, Subtotal: 3500}, tt.line}
+			if got := needsDiscountApproval(lines, 10); got != tt.want {
+				t.Errorf("needsDiscountApproval = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"kasir-api/model"
+	"kasir-api/repository"
+)
+
+// Password limits. bcrypt ignores anything past 72 bytes, so longer
+// passwords are refused rather than silently truncated.
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+	maxUsernameLength = 64
+)
+
+type UserService interface {
+	GetAll() ([]model.User, error)
+	Create(req model.UserRequest) (*model.User, error)
+	// SetPIN sets the PIN the user approves restricted actions with.
+	SetPIN(id int, pin string) error
+	// Bootstrap creates the first account, an owner, from configuration
+	// when there are no users yet, so that someone can log in to create the
+	// others.
+	Bootstrap(username, password string) error
+}
+
+type userService struct {
+	repo repository.UserRepository
+}
+
+func NewUserService(repo repository.UserRepository) UserService {
+	return &userService{repo: repo}
+}
+
+func (s *userService) GetAll() ([]model.User, error) {
+	return s.repo.GetAll()
+}
+
+func (s *userService) Create(req model.UserRequest) (*model.User, error) {
+	req.Username = strings.TrimSpace(req.Username)
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Role == "" {
+		req.Role = model.RoleCashier
+	}
+	if err := validateUser(req).Err(); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{Username: req.Username, Name: req.Name, Role: req.Role, PasswordHash: string(hash)}
+	if req.PIN != "" {
+		pinHash, err := bcrypt.GenerateFromPassword([]byte(req.PIN), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		user.PINHash = string(pinHash)
+	}
+	if err := s.repo.Create(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *userService) SetPIN(id int, pin string) error {
+	v := &ValidationError{}
+	checkPIN(v, "pin", pin)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(pin), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.repo.SetPIN(id, string(hash))
+}
+
+func (s *userService) Bootstrap(username, password string) error {
+	if username == "" || password == "" {
+		return nil
+	}
+	n, err := s.repo.Count()
+	if err != nil || n > 0 {
+		return err
+	}
+
+	if _, err := s.Create(model.UserRequest{Username: username, Name: username, Role: model.RoleOwner, Password: password}); err != nil {
+		return err
+	}
+	log.Printf("Created initial user %q", username)
+	return nil
+}
+
+func validateUser(req model.UserRequest) *ValidationError {
+	v := &ValidationError{}
+	switch {
+	case req.Username == "":
+		v.Add("username", "is required")
+	case len(req.Username) > maxUsernameLength:
+		v.Add("username", fmt.Sprintf("must be at most %d characters", maxUsernameLength))
+	case strings.ContainsFunc(req.Username, func(c rune) bool {
+		return !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '_' || c == '-')
+	}):
+		v.Add("username", "may only contain letters, digits, '.', '_' and '-'")
+	}
+	checkName(v, "name", req.Name)
+	if !slices.Contains(model.Roles, req.Role) {
+		v.Add("role", "must be one of "+strings.Join(model.Roles, ", "))
+	}
+	switch {
+	case len(req.Password) < minPasswordLength:
+		v.Add("password", fmt.Sprintf("must be at least %d characters", minPasswordLength))
+	case len(req.Password) > maxPasswordLength:
+		v.Add("password", fmt.Sprintf("must be at most %d bytes", maxPasswordLength))
+	}
+	if req.PIN != "" {
+		checkPIN(v, "pin", req.PIN)
+	}
+	return v
+}
@@ -0,0 +1,251 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"kasir-api/model"
+)
+
+// Column limits from schema.sql, checked before the database sees a value.
+const (
+	maxNameLength       = 255
+	maxCodeLength       = 64
+	pluCodeLength       = 5
+	maxAttributes       = 20
+	maxAttributeLength  = 100
+	maxCheckoutItems    = 500
+	maxTerminalIDLength = 64
+	maxAddressLength    = 255
+)
+
+// Page sizes of paged lists.
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 200
+)
+
+// FieldError describes one invalid field of a request. Field uses the JSON
+// name, with an index for list elements, e.g. "items[2].quantity".
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every invalid field of a request.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	parts := make([]string, len(e.Fields))
+	for i, f := range e.Fields {
+		parts[i] = f.Field + ": " + f.Message
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+// Add records an invalid field.
+func (e *ValidationError) Add(field, message string) {
+	e.Fields = append(e.Fields, FieldError{Field: field, Message: message})
+}
+
+// Err returns e when any field was recorded and nil otherwise, so callers
+// can build a ValidationError unconditionally.
+func (e *ValidationError) Err() error {
+	if len(e.Fields) == 0 {
+		return nil
+	}
+	return e
+}
+
+// NewFieldError returns a ValidationError for a single field.
+func NewFieldError(field, message string) *ValidationError {
+	return &ValidationError{Fields: []FieldError{{Field: field, Message: message}}}
+}
+
+func checkName(v *ValidationError, field, name string) {
+	switch {
+	case strings.TrimSpace(name) == "":
+		v.Add(field, "is required")
+	case utf8.RuneCountInString(name) > maxNameLength:
+		v.Add(field, fmt.Sprintf("must be at most %d characters", maxNameLength))
+	}
+}
+
+func checkOptionalCode(v *ValidationError, field string, code *string) {
+	if code == nil {
+		return
+	}
+	switch {
+	case strings.TrimSpace(*code) == "":
+		v.Add(field, "must not be blank; omit it or send null instead")
+	case len(*code) > maxCodeLength:
+		v.Add(field, fmt.Sprintf("must be at most %d characters", maxCodeLength))
+	}
+}
+
+func checkNotNegative(v *ValidationError, field string, value int) {
+	if value < 0 {
+		v.Add(field, "must not be negative")
+	}
+}
+
+// validateProduct checks the fields of a product that do not need the
+// database.
+func validateProduct(product *model.Product) *ValidationError {
+	v := &ValidationError{}
+	checkName(v, "name", product.Name)
+	checkOptionalCode(v, "sku", product.SKU)
+	checkOptionalCode(v, "barcode", product.Barcode)
+	checkNotNegative(v, "price", product.Price)
+	checkNotNegative(v, "cost", product.Cost)
+	checkNotNegative(v, "stock", product.Stock)
+
+	if product.PLUCode != nil {
+		if !isDigits(*product.PLUCode) || len(*product.PLUCode) != pluCodeLength {
+			v.Add("plu_code", fmt.Sprintf("must be %d digits", pluCodeLength))
+		} else if !product.IsWeighted {
+			v.Add("plu_code", "is only allowed on weighted products")
+		}
+	}
+
+	if len(product.Attributes) > maxAttributes {
+		v.Add("attributes", fmt.Sprintf("must have at most %d entries", maxAttributes))
+	}
+	for key, value := range product.Attributes {
+		if strings.TrimSpace(key) == "" || utf8.RuneCountInString(key) > maxAttributeLength ||
+			utf8.RuneCountInString(value) > maxAttributeLength {
+			v.Add("attributes."+key, fmt.Sprintf("keys must be non-empty and keys and values at most %d characters", maxAttributeLength))
+		}
+	}
+	return v
+}
+
+// validateCategory checks the fields of a category that do not need the
+// database.
+func validateCategory(category *model.Category) *ValidationError {
+	v := &ValidationError{}
+	checkName(v, "name", category.Name)
+	return v
+}
+
+// validateCheckout checks the lines and payment of a checkout request.
+// Lines and terminal are not checked for cart checkouts, which sell the
+// cart's lines on the cart's terminal.
+func validateCheckout(req model.CheckoutRequest) *ValidationError {
+	v := &ValidationError{}
+	if req.CartID == 0 {
+		validateCheckoutItems(v, req.Items)
+		checkTerminalID(v, req.TerminalID)
+	} else {
+		validateCartAdjustments(v, req.Items)
+	}
+
+	if req.PaymentMethod != "" && !model.IsValidPaymentMethod(req.PaymentMethod) {
+		v.Add("payment_method", "must be one of cash, card, qris, transfer")
+	}
+	checkNotNegative(v, "paid_amount", req.PaidAmount)
+	if req.Receipt != nil {
+		if strings.TrimSpace(req.Receipt.Channel) == "" {
+			v.Add("receipt.channel", "is required")
+		}
+		if strings.TrimSpace(req.Receipt.Recipient) == "" {
+			v.Add("receipt.recipient", "is required")
+		}
+	}
+	return v
+}
+
+// checkPage checks the limit and offset of a paged list and returns the
+// limit to use: the default for 0, and at most maxPageLimit.
+func checkPage(v *ValidationError, limit, offset int) int {
+	checkNotNegative(v, "limit", limit)
+	checkNotNegative(v, "offset", offset)
+	switch {
+	case limit == 0:
+		return defaultPageLimit
+	case limit > maxPageLimit:
+		return maxPageLimit
+	}
+	return limit
+}
+
+func checkTerminalID(v *ValidationError, terminalID string) {
+	switch {
+	case strings.TrimSpace(terminalID) == "":
+		v.Add("terminal_id", "is required")
+	case len(terminalID) > maxTerminalIDLength:
+		v.Add("terminal_id", fmt.Sprintf("must be at most %d characters", maxTerminalIDLength))
+	}
+}
+
+func validateCheckoutItems(v *ValidationError, items []model.CheckoutItem) {
+	if len(items) == 0 {
+		v.Add("items", "must not be empty")
+		return
+	}
+	if len(items) > maxCheckoutItems {
+		v.Add("items", fmt.Sprintf("must have at most %d lines", maxCheckoutItems))
+		return
+	}
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d]", i)
+		if item.Barcode != "" {
+			// Scale labels carry their own quantity; other barcodes default to 1.
+			if len(item.Barcode) > maxCodeLength {
+				v.Add(field+".barcode", fmt.Sprintf("must be at most %d characters", maxCodeLength))
+			}
+			checkNotNegative(v, field+".quantity", item.Quantity)
+		} else {
+			if item.ProductID <= 0 {
+				v.Add(field+".product_id", "must be a valid product ID")
+			}
+			if item.Quantity <= 0 {
+				v.Add(field+".quantity", "must be greater than 0")
+			}
+		}
+		checkItemPricing(v, field, item)
+	}
+}
+
+// validateCartAdjustments checks the items of a cart's checkout, which only
+// adjust the prices of the cart's lines.
+func validateCartAdjustments(v *ValidationError, items []model.CheckoutItem) {
+	seen := map[int]bool{}
+	for i, item := range items {
+		field := fmt.Sprintf("items[%d]", i)
+		switch {
+		case item.ProductID <= 0:
+			v.Add(field+".product_id", "must be a valid product ID")
+		case seen[item.ProductID]:
+			v.Add(field+".product_id", "must not repeat an earlier item's")
+		}
+		seen[item.ProductID] = true
+		if item.Quantity != 0 {
+			v.Add(field+".quantity", "is taken from the cart")
+		}
+		if item.Barcode != "" {
+			v.Add(field+".barcode", "is not accepted for a cart")
+		}
+		checkItemPricing(v, field, item)
+	}
+}
+
+// checkItemPricing checks an item's price override and discount.
+func checkItemPricing(v *ValidationError, field string, item model.CheckoutItem) {
+	if item.Price != nil {
+		checkNotNegative(v, field+".price", *item.Price)
+	}
+	checkNotNegative(v, field+".discount", item.Discount)
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}